@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"time"
 
@@ -98,3 +99,16 @@ func getService(ctx *ctl.CmdCtx, p *project.Project, path string) *project.Servi
 	}
 	return service
 }
+
+// getAdmin requests one of the admin routes of a local service, which are served behind the
+// admin token from the foldctl config.
+func getAdmin(ctx *ctl.CmdCtx, url string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Config.AdminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+ctx.Config.AdminToken)
+	}
+	return http.DefaultClient.Do(req)
+}
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/foldsh/fold/ctl"
+	"github.com/foldsh/fold/ctl/output"
+	"github.com/foldsh/fold/ctl/project"
+	"github.com/foldsh/fold/runtime/supervisor"
+)
+
+var (
+	// Flags
+	crashesPort int
+
+	crashesExampleText = trimf(`
+# Show the recent crashes of every service in your project
+foldctl crashes
+
+# Show the crashes of a single service
+foldctl crashes ./service-one/
+`)
+
+	crashesLongText = trimf(`
+Shows why your services crashed recently. The fold runtime keeps a report of the
+last few crashes of each service, with the exit code or signal, how long it had
+been running and the last lines it wrote to stderr. For Go services the panic
+which caused the crash is shown instead, when there is one. The services must be
+running, i.e. you must have run foldctl up first.
+`)
+)
+
+func NewCrashesCmd(ctx *ctl.CmdCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "crashes [service]",
+		Short:   "Show the recent crashes of your services",
+		Long:    crashesLongText,
+		Example: crashesExampleText,
+		Args:    cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			proj := loadProject(ctx)
+			services := proj.Services
+			if len(args) == 1 {
+				services = []*project.Service{getService(ctx, proj, args[0])}
+			}
+			gatewayURL := fmt.Sprintf("http://localhost:%d", crashesPort)
+			for _, service := range services {
+				url := fmt.Sprintf("%s/%s/_foldadmin/crashes", gatewayURL, service.Name)
+				reports, err := fetchCrashReports(ctx, url)
+				if err != nil {
+					ctx.Inform(output.Error("failed to fetch the crash reports."))
+					ctx.Inform(output.Line("Please check that your services are up with foldctl up."))
+					ctx.Logger.Debugf("%v", err)
+					os.Exit(1)
+				}
+				displayCrashReports(ctx, service.Name, reports)
+			}
+		},
+	}
+	cmd.Flags().IntVarP(&crashesPort, "port", "p", 6123, "development server port")
+	return cmd
+}
+
+func fetchCrashReports(ctx *ctl.CmdCtx, url string) ([]supervisor.CrashReport, error) {
+	res, err := getAdmin(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("%s returned %d: %s", url, res.StatusCode, body)
+	}
+	var reports []supervisor.CrashReport
+	if err := json.Unmarshal(body, &reports); err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+func displayCrashReports(ctx *ctl.CmdCtx, name string, reports []supervisor.CrashReport) {
+	if len(reports) == 0 {
+		ctx.Informf("%s %s hasn't crashed", output.Green("✓"), name)
+		return
+	}
+	for _, report := range reports {
+		reason := fmt.Sprintf("exit code %d", report.ExitCode)
+		if report.Signal != "" {
+			reason = fmt.Sprintf("signal %q", report.Signal)
+		}
+		ctx.InformHeader(
+			"%s crashed with %s at %s after running for %.1fs (pid %d)",
+			name,
+			reason,
+			report.Time.Local().Format("2006-01-02 15:04:05"),
+			report.Uptime,
+			report.PID,
+		)
+		lines := report.Stderr
+		if report.Panic != "" {
+			lines = strings.Split(report.Panic, "\n")
+		} else if len(lines) == 0 {
+			lines = report.Stdout
+		}
+		for _, line := range lines {
+			ctx.Informf("    %s", line)
+		}
+		ctx.Informf("")
+	}
+}
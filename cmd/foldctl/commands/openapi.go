@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/foldsh/fold/ctl"
+	"github.com/foldsh/fold/ctl/gateway"
+	"github.com/foldsh/fold/ctl/output"
+)
+
+var (
+	// Flags
+	openAPIPort   int
+	openAPIOutput string
+
+	openAPIExampleText = trimf(`
+# Write a document describing every service to openapi.json
+foldctl openapi
+
+# Write the document for a single service to a different file
+foldctl openapi ./service-one/ --output service-one.json
+
+# Print the document instead of writing it to a file
+foldctl openapi --output -
+`)
+
+	openAPILongText = trimf(`
+Generates an OpenAPI 3 document from the routes your services register.
+The services must be running, i.e. you must have run foldctl up first. Without a
+service the document describes every service in the project, with their paths
+prefixed by the service name just as they are on the gateway.
+`)
+)
+
+func NewOpenAPICmd(ctx *ctl.CmdCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "openapi [service]",
+		Short:   "Generate an OpenAPI document for your services",
+		Long:    openAPILongText,
+		Example: openAPIExampleText,
+		Args:    cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			gatewayURL := fmt.Sprintf("http://localhost:%d", openAPIPort)
+			url := fmt.Sprintf("%s/%s/openapi.json", gatewayURL, gateway.Prefix)
+			if len(args) == 1 {
+				proj := loadProject(ctx)
+				service := getService(ctx, proj, args[0])
+				url = fmt.Sprintf("%s/%s/_foldadmin/openapi.json", gatewayURL, service.Name)
+			}
+			doc, err := fetchOpenAPI(ctx, url)
+			if err != nil {
+				ctx.Inform(output.Error("failed to fetch the OpenAPI document."))
+				ctx.Inform(output.Line("Please check that your services are up with foldctl up."))
+				ctx.Logger.Debugf("%v", err)
+				os.Exit(1)
+			}
+			if openAPIOutput == "-" {
+				os.Stdout.Write(doc)
+				return
+			}
+			if err := ioutil.WriteFile(openAPIOutput, doc, 0644); err != nil {
+				ctx.InformError(err)
+				os.Exit(1)
+			}
+			ctx.Informf("Wrote the OpenAPI document to %s", openAPIOutput)
+		},
+	}
+	cmd.Flags().IntVarP(&openAPIPort, "port", "p", 6123, "development server port")
+	cmd.Flags().StringVarP(&openAPIOutput, "output", "o", "openapi.json", "file to write to")
+	return cmd
+}
+
+// fetchOpenAPI gets the document and indents it to make it easier to read and diff.
+func fetchOpenAPI(ctx *ctl.CmdCtx, url string) ([]byte, error) {
+	res, err := getAdmin(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("%s returned %d: %s", url, res.StatusCode, body)
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, body, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/foldsh/fold/ctl"
+	"github.com/foldsh/fold/ctl/output"
+	"github.com/foldsh/fold/ctl/replay"
+	"github.com/foldsh/fold/runtime/recorder"
+)
+
+var (
+	// Flags
+	replayPort    int
+	replayService string
+
+	replayExampleText = trimf(`
+# Replay the requests recorded by a service
+foldctl replay requests.jsonl
+
+# Replay them against a different service in your project
+foldctl replay requests.jsonl --service ./service-two/
+`)
+
+	replayLongText = trimf(`
+Sends the requests in a recording made by the fold runtime to your services and
+compares the responses with the recorded ones. Requests are recorded when the
+record.file option is set in foldrt.yaml. The services must be running, i.e. you
+must have run foldctl up first.
+
+JSON bodies are compared by value, other bodies must match exactly. Redacted
+headers are not sent, so requests which relied on them are likely to differ.
+`)
+)
+
+func NewReplayCmd(ctx *ctl.CmdCtx) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "replay <file>",
+		Short:   "Replay recorded requests against your services",
+		Long:    replayLongText,
+		Example: replayExampleText,
+		Args:    cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			entries, err := recorder.Read(args[0])
+			if err != nil {
+				ctx.Inform(output.Error(fmt.Sprintf("failed to read the recording: %v", err)))
+				os.Exit(1)
+			}
+			var options []replay.Option
+			if replayService != "" {
+				proj := loadProject(ctx)
+				service := getService(ctx, proj, replayService)
+				options = append(options, replay.Service(service.Name))
+			}
+			gatewayURL := fmt.Sprintf("http://localhost:%d", replayPort)
+			results := replay.NewReplayer(gatewayURL, options...).Replay(entries)
+
+			matched := 0
+			for _, result := range results {
+				req := result.Entry.Request
+				name := fmt.Sprintf("%s /%s%s", req.HTTPMethod, result.Service, req.Path)
+				switch {
+				case result.Err != nil:
+					ctx.Informf("%s %s: %v", output.Red("✗"), name, result.Err)
+				case result.Matched():
+					matched++
+					ctx.Informf("%s %s %d", output.Green("✓"), name, result.Status)
+				default:
+					ctx.Informf("%s %s %d", output.Red("✗"), name, result.Status)
+					ctx.Informf("%s", result.Diff)
+				}
+			}
+			ctx.Informf("\n%d of %d responses matched the recording", matched, len(results))
+			if matched != len(results) {
+				os.Exit(1)
+			}
+		},
+	}
+	cmd.Flags().IntVarP(&replayPort, "port", "p", 6123, "development server port")
+	cmd.Flags().StringVarP(
+		&replayService,
+		"service",
+		"s",
+		"",
+		"the service to send the requests to, instead of the one they were recorded by",
+	)
+	return cmd
+}
@@ -43,6 +43,9 @@ func NewRootCmd(ctx *ctl.CmdCtx) *cobra.Command {
 	cmd.AddCommand(NewDeployCmd(ctx))
 	cmd.AddCommand(NewDownCmd(ctx))
 	cmd.AddCommand(NewNewCmd(ctx))
+	cmd.AddCommand(NewOpenAPICmd(ctx))
+	cmd.AddCommand(NewReplayCmd(ctx))
+	cmd.AddCommand(NewCrashesCmd(ctx))
 
 	return cmd
 }
@@ -18,8 +18,10 @@ import (
 
 var (
 	// Flags
-	port   int
-	detach bool
+	port          int
+	detach        bool
+	debugServices []string
+	debugPort     int
 
 	// Help text
 	exampleText = trimf(`
@@ -31,6 +33,9 @@ foldctl up ./service-one/
 
 # Start multiple services
 foldctl up ./service-one/ ./service-two/"
+
+# Start a service under a debugger listening on localhost:2345
+foldctl up --debug ./service-one/
 `)
 
 	longText = trimf(`
@@ -52,7 +57,7 @@ func NewUpCmd(ctx *ctl.CmdCtx) *cobra.Command {
 			proj := loadProjectWithRuntime(ctx, out)
 			proj.ConfigureGatewayPort(port)
 
-			if services, err := proj.GetServices(args...); err == nil {
+			if services, err := debugging(proj, args); err == nil {
 				if err := proj.Up(out, services...); err != nil {
 					ctx.InformError(err)
 					os.Exit(1)
@@ -78,9 +83,50 @@ func NewUpCmd(ctx *ctl.CmdCtx) *cobra.Command {
 	}
 	cmd.PersistentFlags().IntVarP(&port, "port", "p", 6123, "development server port")
 	cmd.PersistentFlags().BoolVarP(&detach, "detach", "d", false, "run in the background")
+	cmd.PersistentFlags().StringSliceVar(
+		&debugServices,
+		"debug",
+		nil,
+		"start the service under a debugger, it is started even if it isn't listed",
+	)
+	cmd.PersistentFlags().IntVar(
+		&debugPort,
+		"debug-port",
+		2345,
+		"the port of the first debugger, each further one gets the next port",
+	)
 	return cmd
 }
 
+// debugging returns the services to start, which includes every service being debugged. Each of
+// those gets its own debugger port.
+func debugging(proj *project.Project, args []string) ([]*project.Service, error) {
+	services, err := proj.GetServices(args...)
+	if err != nil {
+		return nil, err
+	}
+	debugged, err := proj.GetServices(debugServices...)
+	if err != nil {
+		return nil, err
+	}
+	for i, service := range debugged {
+		service.DebugPort = debugPort + i
+		if !containsService(services, service) {
+			services = append(services, service)
+		}
+	}
+	return services, nil
+}
+
+func containsService(services []*project.Service, service *project.Service) bool {
+	for _, s := range services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
 func runInForeground(
 	ctx *ctl.CmdCtx,
 	services []*project.Service,
@@ -120,7 +166,7 @@ func displayServiceSummary(ctx *ctl.CmdCtx, port int, services []*project.Servic
 		serviceURL := fmt.Sprintf("%s/%s", gatewayURL, service.Name)
 		waitForHealthz(ctx, serviceURL)
 		ctx.Informf("")
-		resp, err := http.Get(fmt.Sprintf("%s/_foldadmin/manifest", serviceURL))
+		resp, err := getAdmin(ctx, fmt.Sprintf("%s/_foldadmin/manifest", serviceURL))
 		if err != nil {
 			ctx.InformError(err)
 		}
@@ -131,6 +177,9 @@ func displayServiceSummary(ctx *ctl.CmdCtx, port int, services []*project.Servic
 			ctx.InformError(err)
 		}
 		ctx.Informf("    %s is available at %s", service.Name, serviceURL)
+		if service.DebugPort != 0 {
+			ctx.Informf("    %s debugger is listening on localhost:%d", service.Name, service.DebugPort)
+		}
 		ctx.Informf("    %s routes:", service.Name)
 		for _, route := range m.Routes {
 			ctx.Informf("        %s %s%s", route.HttpMethod, serviceURL, route.Route)
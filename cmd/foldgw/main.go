@@ -1,9 +1,15 @@
 package main
 
 import (
+	"log"
+
 	"github.com/foldsh/fold/ctl/gateway"
 )
 
 func main() {
-	gateway.Serve()
+	gw, err := gateway.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	gateway.Serve(gw)
 }
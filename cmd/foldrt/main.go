@@ -2,16 +2,105 @@ package main
 
 import (
 	"context"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/runtime"
+	"github.com/foldsh/fold/runtime/auth"
+	"github.com/foldsh/fold/runtime/concurrency"
+	"github.com/foldsh/fold/runtime/config"
+	"github.com/foldsh/fold/runtime/debugger"
 	handlerImpl "github.com/foldsh/fold/runtime/handler"
+	"github.com/foldsh/fold/runtime/idempotency"
+	"github.com/foldsh/fold/runtime/recorder"
+	"github.com/foldsh/fold/runtime/transport"
+	"github.com/foldsh/fold/runtime/watcher"
 )
 
+var debuggers = map[string]debugger.DebuggerT{
+	config.AUTO: debugger.AUTO,
+	config.DLV:  debugger.DLV,
+	config.NODE: debugger.NODE,
+}
+
+var watchModes = map[string]watcher.ModeT{
+	config.AUTO:   watcher.AUTO,
+	config.NOTIFY: watcher.NOTIFY,
+	config.POLL:   watcher.POLL,
+}
+
+// concurrencyLimit builds the runtime option for the concurrency limits. The queue settings of
+// the service apply to any route which doesn't set its own.
+func concurrencyLimit(cfg config.ConcurrencyConfig) runtime.Option {
+	settings := concurrency.Settings{
+		MaxInFlight: cfg.MaxInFlight,
+		MaxQueue:    cfg.MaxQueue,
+		MaxWait:     cfg.MaxWait,
+	}
+	options := []concurrency.Option{concurrency.RetryAfter(cfg.RetryAfter)}
+	for _, route := range cfg.Routes {
+		routeSettings := concurrency.Settings{
+			MaxInFlight: route.MaxInFlight,
+			MaxQueue:    route.MaxQueue,
+			MaxWait:     route.MaxWait,
+		}
+		if routeSettings.MaxQueue == 0 {
+			routeSettings.MaxQueue = settings.MaxQueue
+		}
+		if routeSettings.MaxWait == 0 {
+			routeSettings.MaxWait = settings.MaxWait
+		}
+		options = append(options, concurrency.Route(route.Method, route.Route, routeSettings))
+	}
+	if cfg.Adaptive.Enabled {
+		options = append(
+			options,
+			concurrency.Adaptive(cfg.Adaptive.TargetLatency, cfg.Adaptive.MinInFlight),
+		)
+	}
+	return runtime.ConcurrencyLimit(settings, options...)
+}
+
+// adapter builds the runtime options which put it in front of a plain HTTP server. A new proxy
+// is created for every process, just as a new client would be.
+func adapter(logger logging.Logger, cfg config.AdapterConfig) []runtime.Option {
+	proxyOptions := []transport.ProxyOption{
+		transport.ProxyManifest(cfg.Manifest),
+		transport.ProxyHealthPath(cfg.HealthPath),
+		transport.ProxyStartTimeout(cfg.StartTimeout),
+	}
+	options := []runtime.Option{}
+	if cfg.Port != 0 {
+		proxyOptions = append(proxyOptions, transport.ProxyPort(cfg.Port))
+		options = append(options, runtime.WithEnv(map[string]string{"PORT": fmt.Sprint(cfg.Port)}))
+	}
+	newProxy := func() runtime.Client { return transport.NewProxy(logger, proxyOptions...) }
+	return append(options, runtime.WithClient(newProxy()), runtime.WithClientFactory(newProxy))
+}
+
+// ingress builds the runtime options for the protocol the SDK serves. Unless it is detected, the
+// SDK is told which one to serve through FOLD_INGRESS.
+func ingress(logger logging.Logger, protocol string) []runtime.Option {
+	var newClient func() runtime.Client
+	switch protocol {
+	case config.GRPC:
+		newClient = func() runtime.Client { return transport.NewIngress(logger) }
+	case config.HTTP:
+		newClient = func() runtime.Client { return transport.NewHTTPIngress(logger) }
+	default:
+		newClient = func() runtime.Client { return transport.NewAutoIngress(logger) }
+	}
+	options := []runtime.Option{runtime.WithClient(newClient()), runtime.WithClientFactory(newClient)}
+	if protocol != config.AUTO {
+		options = append(options, runtime.WithEnv(map[string]string{"FOLD_INGRESS": protocol}))
+	}
+	return options
+}
+
 type Handler interface {
 	Serve() error
 	Shutdown(context.Context, chan struct{})
@@ -25,55 +114,201 @@ func main() {
 		handler Handler
 	)
 
-	// TEST, PROD
-	stage := os.Getenv("FOLD_STAGE")
-	// LAMBDA, HTTP
-	env := os.Getenv("FOLD_ENV")
-	watchDir := os.Getenv("FOLD_WATCH_DIR")
-
-	switch stage {
-	case "DEBUG":
-		logger, err = logging.NewLogger(logging.Debug, true)
-	case "TEST":
-		logger, err = logging.NewLogger(logging.Info, true)
-	case "PROD":
-		logger, err = logging.NewLogger(logging.Error, true)
-	default:
-		// Local development mode
-		logger, err = logging.NewLogger(logging.Info, false)
-		options = append(options, runtime.CrashPolicy(runtime.KEEP_ALIVE))
-		if watchDir != "" {
-			options = append(options, runtime.WatchDir(100*time.Millisecond, watchDir))
-		}
+	// Only the flags before the command belong to foldrt. Parsing stops at the first argument
+	// which isn't a flag, or after --, and the rest are passed to the command untouched, so that
+	// e.g. `foldrt node --inspect index.js` runs node with --inspect.
+	flags := flag.NewFlagSet("foldrt", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: foldrt [-config foldrt.yaml] [--] command [args...]")
+		flags.PrintDefaults()
+	}
+	configPath := flags.String(
+		"config",
+		os.Getenv("FOLD_CONFIG"),
+		"path to a foldrt.yaml config file, can also be set with FOLD_CONFIG",
+	)
+	flags.Parse(os.Args[1:])
+	args := flags.Args()
+	if len(args) < 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "foldrt: invalid configuration: %v\n", err)
+		os.Exit(1)
 	}
 
+	logger, err = logging.NewLogger(cfg.LogLevel(), cfg.Log.Format == config.JSON)
 	if err != nil {
 		panic("failed to start logger")
 	}
 
-	logger.Debug("Starting fold runtime for stage: ", stage)
+	logger.Debug("Starting fold runtime for stage: ", cfg.Stage)
+
+	options = append(options, runtime.ServiceName(cfg.ServiceName), runtime.Stage(cfg.Stage))
+	options = append(options, runtime.ManifestTimeout(cfg.ManifestTimeout))
+	options = append(options, runtime.CacheSize(cfg.Cache.Size))
+	if cfg.ReloadStrategy == config.BLUE_GREEN {
+		options = append(options, runtime.ReloadStrategy(runtime.BLUE_GREEN))
+		options = append(options, runtime.DrainTimeout(cfg.DrainTimeout))
+	}
+	if cfg.HealthCheck.Enabled {
+		options = append(options, runtime.HealthCheck(
+			cfg.HealthCheck.Interval,
+			cfg.HealthCheck.Timeout,
+			cfg.HealthCheck.FailureThreshold,
+		))
+	}
+	if cfg.CrashPolicy == config.KEEP_ALIVE {
+		options = append(options, runtime.CrashPolicy(runtime.KEEP_ALIVE))
+	}
+	// Hot reloading is only for local development, the watch dirs are ignored in other stages.
+	if roots := cfg.Watch.Roots(); len(roots) > 0 && cfg.Stage == config.LOCAL {
+		options = append(options, runtime.WatchDirs(
+			cfg.Watch.Debounce,
+			roots,
+			watcher.Ignore(cfg.Watch.Ignore...),
+			watcher.Ignore(cfg.BuildOutputs...),
+			watcher.GitIgnore(cfg.Watch.GitIgnore),
+			watcher.Extensions(cfg.Watch.Extensions...),
+			watcher.Mode(watchModes[cfg.Watch.Mode]),
+			watcher.Interval(cfg.Watch.Interval),
+			watcher.Hash(cfg.Watch.Hash),
+		))
+		if cfg.BuildCmd != "" {
+			options = append(options, runtime.BuildCommand(cfg.BuildCmd))
+		}
+	}
+	if cfg.Admin.Token != "" {
+		options = append(options, runtime.AdminToken(cfg.Admin.Token))
+	}
+	// The networks have already been validated by config.Load.
+	if networks, _ := cfg.HTTP.TrustedNetworks(); len(networks) > 0 {
+		options = append(options, runtime.TrustedProxies(networks...))
+	}
+	if cfg.Auth.JWKS != "" {
+		authOptions := []auth.Option{
+			auth.Issuer(cfg.Auth.Issuer),
+			auth.Audience(cfg.Auth.Audience),
+			auth.Leeway(cfg.Auth.Leeway),
+		}
+		if cfg.Auth.AllowMissingExp {
+			authOptions = append(authOptions, auth.AllowMissingExpiry())
+		}
+		authenticator := auth.NewAuthenticator(
+			auth.NewJWKS(cfg.Auth.JWKS, cfg.Auth.Refresh),
+			authOptions...,
+		)
+		options = append(options, runtime.Authenticator(authenticator))
+	}
+	if cfg.Concurrency.Enabled() {
+		options = append(options, concurrencyLimit(cfg.Concurrency))
+	}
+	if cfg.Record.File != "" {
+		rec, err := recorder.NewRecorder(
+			logger,
+			cfg.Record.File,
+			recorder.Service(cfg.ServiceName),
+			recorder.MaxSize(cfg.Record.MaxSize),
+			recorder.MaxFiles(cfg.Record.MaxFiles),
+			recorder.RedactHeaders(cfg.Record.RedactHeaders...),
+			recorder.RedactFields(cfg.Record.RedactFields...),
+		)
+		if err != nil {
+			logger.Fatalf("Failed to start recording requests: %v", err)
+		}
+		options = append(options, runtime.RecordRequests(rec))
+	}
+	if cfg.Idempotency.Dir != "" {
+		store, err := idempotency.NewFileStore(cfg.Idempotency.Dir)
+		if err != nil {
+			logger.Fatalf("Failed to open the idempotency store: %v", err)
+		}
+		options = append(options, runtime.IdempotencyStore(store))
+	}
+	if cfg.Adapter.Enabled {
+		options = append(options, adapter(logger, cfg.Adapter)...)
+	} else {
+		options = append(options, ingress(logger, cfg.Ingress)...)
+	}
+	if cfg.Debug.Enabled {
+		d, err := debugger.NewDebugger(debuggers[cfg.Debug.Debugger], cfg.Debug.Port, args[0])
+		if err != nil {
+			logger.Fatalf("Failed to set up the debugger: %v", err)
+		}
+		logger.Infof("Running the service under a debugger listening on port %d", d.Port())
+		options = append(options, runtime.Debug(d))
+	}
+	// The admin routes are either served alongside the service or on their own listener. When
+	// they have their own listener, only the health checks can be reached through the service.
+	// Otherwise the rest are only served alongside the service if they are protected by a token
+	// or they have been made public explicitly.
+	switch {
+	case !cfg.Admin.Enabled:
+		options = append(options, runtime.PublicAdmin(runtime.NO_ADMIN))
+	case cfg.Admin.Addr != "" && cfg.Admin.PublicHealth:
+		options = append(options, runtime.PublicAdmin(runtime.HEALTH_ONLY))
+	case cfg.Admin.Addr != "":
+		options = append(options, runtime.PublicAdmin(runtime.NO_ADMIN))
+	case cfg.Admin.Token != "" || cfg.Admin.Public:
+		options = append(options, runtime.PublicAdmin(runtime.ALL_ADMIN))
+	default:
+		options = append(options, runtime.PublicAdmin(runtime.HEALTH_ONLY))
+	}
 
 	runtimeStopped := make(chan struct{})
-	rt := runtime.NewRuntime(logger, os.Args[1], os.Args[2:], runtimeStopped, options...)
+	rt := runtime.NewRuntime(logger, args[0], args[1:], runtimeStopped, options...)
+
+	var adminHandler Handler
+	if cfg.Admin.Enabled && cfg.Admin.Addr != "" {
+		var httpOptions []handlerImpl.HTTPOption
+		if cfg.Admin.TLS.Cert != "" {
+			tlsConfig, err := handlerImpl.LoadTLSConfig(
+				cfg.Admin.TLS.Cert,
+				cfg.Admin.TLS.Key,
+				cfg.Admin.TLS.ClientCA,
+			)
+			if err != nil {
+				logger.Fatalf("Failed to load admin TLS config: %v", err)
+			}
+			httpOptions = append(httpOptions, handlerImpl.WithTLS(tlsConfig))
+		}
+		adminHandler = handlerImpl.NewHTTP(logger, rt.Admin(), cfg.Admin.Addr, httpOptions...)
+	}
 	rt.Start()
 
-	switch env {
-	case "LAMBDA":
+	switch cfg.Handler {
+	case config.LAMBDA:
 		handler = handlerImpl.NewLambda(logger, rt)
 	default:
-		handler = handlerImpl.NewHTTP(logger, rt, ":6123")
+		handler = handlerImpl.NewHTTP(logger, rt, cfg.HTTP.Addr)
 	}
 
 	handlerShutdown := make(chan struct{})
+	adminShutdown := make(chan struct{})
+	if adminHandler != nil {
+		go func() {
+			if err := adminHandler.Serve(); err != nil {
+				logger.Fatalf("Admin server stopped unexpectedly: %v", err)
+			}
+		}()
+	} else {
+		close(adminShutdown)
+	}
 	go func() {
 		signals := make(chan os.Signal, 1)
 		signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGINT)
 		s := <-signals
 
 		// Ok we got a signal to kill the application. First we shutdown the server gracefully:
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 		defer cancel()
 		handler.Shutdown(ctx, handlerShutdown)
+		if adminHandler != nil {
+			adminHandler.Shutdown(ctx, adminShutdown)
+		}
 		// Now we can signal the runtime.
 		rt.Signal(s)
 	}()
@@ -83,6 +318,7 @@ func main() {
 	}
 	logger.Debugf("waiting for handler shutdown")
 	<-handlerShutdown
+	<-adminShutdown
 	logger.Debugf("waiting for runtime to stop")
 	<-runtimeStopped
 }
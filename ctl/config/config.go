@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
@@ -18,6 +20,9 @@ var (
 type Config struct {
 	AccessToken string `mapstructure:"access-token"`
 	Version     string `mapstructure:"version"`
+	// The token the local services are started with. foldctl and the local gateway send it to
+	// read the admin routes of the services, e.g. their crash reports.
+	AdminToken string `mapstructure:"admin-token"`
 
 	FoldHome      string
 	FoldTemplates string
@@ -52,9 +57,21 @@ func Load(path string) (*Config, error) {
 			return nil, ReadConfigError
 		}
 	}
+	// Configs written by older versions of foldctl don't have an admin token yet.
+	if viper.GetString("admin-token") == "" {
+		token, err := newAdminToken()
+		if err != nil {
+			return nil, CreateConfigError
+		}
+		viper.Set("admin-token", token)
+		// Saving the token can fail if FOLD_HOME is read only. That shouldn't stop foldctl from
+		// working, it only means the services will get a new token each time they are started.
+		_ = viper.WriteConfig()
+	}
 	return &Config{
 		AccessToken:   viper.GetString("access-token"),
 		Version:       viper.GetString("version"),
+		AdminToken:    viper.GetString("admin-token"),
 		FoldHome:      path,
 		FoldTemplates: fs.FoldTemplates(path),
 	}, nil
@@ -71,9 +88,22 @@ func writeDefaultConfig(path string) error {
 	writer.SetConfigType("yaml")
 	writer.Set("access-token", "")
 	writer.Set("version", version.FoldVersion.String())
-	err := writer.SafeWriteConfig()
+	token, err := newAdminToken()
+	if err != nil {
+		return err
+	}
+	writer.Set("admin-token", token)
+	err = writer.SafeWriteConfig()
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+func newAdminToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/spf13/viper"
@@ -21,6 +22,7 @@ func TestLoadCtlConfig(t *testing.T) {
 
 	assert.Equal(t, "1.2.3", cfg.Version, "version should equal 1.2.3")
 	assert.Equal(t, "ABCDEF123456", cfg.AccessToken, "access token should equal ABCDEF123456")
+	assert.Equal(t, "123456ABCDEF", cfg.AdminToken, "admin token should equal 123456ABCDEF")
 }
 
 func TestConfigCreatedIfNotPresent(t *testing.T) {
@@ -35,4 +37,54 @@ func TestConfigCreatedIfNotPresent(t *testing.T) {
 	v := version.FoldVersion.String()
 	assert.Equal(t, v, cfg.Version, fmt.Sprintf("version should equal %s", v))
 	assert.Equal(t, "", cfg.AccessToken, "access token should be empty string")
+	assert.Len(t, cfg.AdminToken, 64, "admin token should be generated")
+
+	viper.Reset()
+	reloaded, err := config.Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, cfg.AdminToken, reloaded.AdminToken, "admin token should be kept")
+}
+
+func TestAdminTokenAddedToExistingConfig(t *testing.T) {
+	viper.Reset()
+	dir, err := ioutil.TempDir("", "fold.ctl.config.test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(
+		filepath.Join(dir, "config.yaml"),
+		[]byte("version: 1.2.3\naccess-token: ABCDEF123456\n"),
+		0644,
+	)
+	require.Nil(t, err)
+
+	cfg, err := config.Load(dir)
+	require.Nil(t, err)
+	assert.Len(t, cfg.AdminToken, 64, "admin token should be generated")
+
+	viper.Reset()
+	reloaded, err := config.Load(dir)
+	require.Nil(t, err)
+	assert.Equal(t, cfg.AdminToken, reloaded.AdminToken, "admin token should be saved")
+}
+
+func TestAdminTokenWithReadOnlyConfig(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read only directories")
+	}
+	viper.Reset()
+	dir, err := ioutil.TempDir("", "fold.ctl.config.test")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(
+		filepath.Join(dir, "config.yaml"),
+		[]byte("version: 1.2.3\naccess-token: ABCDEF123456\n"),
+		0444,
+	)
+	require.Nil(t, err)
+	require.Nil(t, os.Chmod(dir, 0555))
+	defer os.Chmod(dir, 0755)
+
+	cfg, err := config.Load(dir)
+	require.Nil(t, err)
+	assert.Len(t, cfg.AdminToken, 64, "admin token should be generated")
 }
@@ -38,9 +38,13 @@ type Container struct {
 	Name         string
 	NetworkAlias string
 	Image        Image
-	Ports        []int
-	Mounts       []Mount
-	Environment  map[string]string
+	// The host ports the runtime's port is published on.
+	Ports []int
+	// Ports in the container which are published on the same port of the host's loopback
+	// interface, e.g. for a debugger.
+	PublishedPorts []int
+	Mounts         []Mount
+	Environment    map[string]string
 }
 
 type Mount struct {
@@ -85,6 +89,19 @@ func (cr *ContainerRuntime) RunContainer(net *Network, con *Container) error {
 		}
 		portBindings[nat.Port("6123/tcp")] = binding
 	}
+	var exposedPorts nat.PortSet
+	for _, p := range con.PublishedPorts {
+		port := nat.Port(fmt.Sprintf("%d/tcp", p))
+		if exposedPorts == nil {
+			exposedPorts = nat.PortSet{}
+		}
+		exposedPorts[port] = struct{}{}
+		// Debuggers accept unauthenticated connections which can run arbitrary code, so they are
+		// only reachable from this machine.
+		portBindings[port] = []nat.PortBinding{
+			{HostIP: "127.0.0.1", HostPort: fmt.Sprintf("%d", p)},
+		}
+	}
 	var mounts []mount.Mount
 	for _, m := range con.Mounts {
 		err := cr.fs.MkdirAll(m.Src, fs.DIR_PERMISSIONS)
@@ -99,19 +116,30 @@ func (cr *ContainerRuntime) RunContainer(net *Network, con *Container) error {
 			Target: m.Dst,
 		})
 	}
+	// Every mount is watched for changes. FOLD_WATCH_DIR is still set to the first one for
+	// runtimes which don't support FOLD_WATCH_DIRS.
+	var watchDirs []string
+	for _, m := range mounts {
+		watchDirs = append(watchDirs, m.Target)
+	}
 	var watchDir string
-	if len(mounts) > 0 {
-		watchDir = mounts[0].Target
+	if len(watchDirs) > 0 {
+		watchDir = watchDirs[0]
+	}
+	env := []string{
+		"FOLD_STAGE=LOCAL",
+		fmt.Sprintf("FOLD_WATCH_DIR=%s", watchDir),
+		fmt.Sprintf("FOLD_WATCH_DIRS=%s", strings.Join(watchDirs, ",")),
 	}
-	env := []string{"FOLD_STAGE=LOCAL", fmt.Sprintf("FOLD_WATCH_DIR=%s", watchDir)}
 	for key, value := range con.Environment {
 		env = append(env, fmt.Sprintf("%s=%s", key, value))
 	}
 	resp, err := cr.cli.ContainerCreate(
 		cr.ctx,
 		&container.Config{
-			Image: con.Image.Name,
-			Env:   env,
+			Image:        con.Image.Name,
+			Env:          env,
+			ExposedPorts: exposedPorts,
 		},
 		// TODO make auto removing containers configurable
 		&container.HostConfig{PortBindings: portBindings, Mounts: mounts, AutoRemove: true},
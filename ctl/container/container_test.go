@@ -9,6 +9,7 @@ import (
 
 	"github.com/docker/docker/api/types"
 	dockerContainer "github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/stretchr/testify/assert"
@@ -41,6 +42,7 @@ func TestContainerStartAndStop(t *testing.T) {
 			Env: []string{
 				"FOLD_STAGE=LOCAL",
 				fmt.Sprintf("FOLD_WATCH_DIR=%s", c.Mounts[0].Dst),
+				"FOLD_WATCH_DIRS=/dst,/bar",
 				"FOLD_SERVICE_NAME=test",
 			},
 		},
@@ -342,3 +344,37 @@ func diffContainers(t *testing.T, expectation, actual interface{}) {
 		t.Errorf("Expected container lists to be equal(-want +got):\n%s", diff)
 	}
 }
+
+func TestContainerPublishedPorts(t *testing.T) {
+	rt, dc, _ := setup()
+	c := &container.Container{
+		Name:           "test",
+		Image:          container.Image{Name: "fold/test"},
+		Ports:          []int{6124},
+		PublishedPorts: []int{2345},
+	}
+	dc.On(
+		"ContainerCreate",
+		mock.Anything,
+		mock.MatchedBy(func(config *dockerContainer.Config) bool {
+			_, ok := config.ExposedPorts[nat.Port("2345/tcp")]
+			return ok
+		}),
+		mock.MatchedBy(func(host *dockerContainer.HostConfig) bool {
+			return cmp.Equal(host.PortBindings, nat.PortMap{
+				"6123/tcp": {{HostIP: "0.0.0.0", HostPort: "6124"}},
+				"2345/tcp": {{HostIP: "127.0.0.1", HostPort: "2345"}},
+			})
+		}),
+		mock.Anything,
+		mock.Anything,
+		"test",
+	).Return(
+		dockerContainer.ContainerCreateCreatedBody{ID: "testContainerID"},
+		nil,
+	)
+	dc.On("ContainerStart", mock.Anything, "testContainerID", mock.Anything).Return(nil)
+
+	require.Nil(t, rt.RunContainer(&container.Network{}, c))
+	dc.AssertExpectations(t)
+}
@@ -1,15 +1,89 @@
 package gateway
 
 import (
+	"bytes"
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/golang/protobuf/jsonpb"
+
+	"github.com/foldsh/fold/manifest"
 	"github.com/foldsh/fold/version"
 )
 
+const (
+	// The environment variable used to pass the project wide CORS policy to the gateway
+	// container.
+	corsEnv = "FOLD_GATEWAY_CORS"
+	// The environment variables used to tell the gateway about the project and its services, so
+	// that it can merge their OpenAPI documents.
+	projectEnv  = "FOLD_GATEWAY_PROJECT"
+	servicesEnv = "FOLD_GATEWAY_SERVICES"
+	// The environment variable used to pass the token the gateway sends to the admin routes of
+	// the services.
+	adminTokenEnv = "FOLD_GATEWAY_ADMIN_TOKEN"
+)
+
 type Gateway struct {
-	Port int
+	Port     int
+	CORS     *manifest.CorsPolicy
+	Project  string
+	Services []string
+	// The admin token the services were started with, if any.
+	AdminToken string
 }
 
 func (gw *Gateway) ImageName() string {
 	return fmt.Sprintf("foldsh/foldgw:%s", version.FoldVersion.String())
 }
+
+// Env returns the environment the gateway container must be started with.
+func (gw *Gateway) Env() (map[string]string, error) {
+	env := map[string]string{
+		projectEnv:  gw.Project,
+		servicesEnv: strings.Join(gw.Services, ","),
+	}
+	if gw.AdminToken != "" {
+		env[adminTokenEnv] = gw.AdminToken
+	}
+	if gw.CORS != nil {
+		marshaler := &jsonpb.Marshaler{}
+		var buf bytes.Buffer
+		if err := marshaler.Marshal(&buf, gw.CORS); err != nil {
+			return nil, err
+		}
+		env[corsEnv] = buf.String()
+	}
+	return env, nil
+}
+
+// FromEnv reads the gateway configuration set by Env.
+func FromEnv() (*Gateway, error) {
+	policy, err := CORSFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	gw := &Gateway{
+		CORS:       policy,
+		Project:    os.Getenv(projectEnv),
+		AdminToken: os.Getenv(adminTokenEnv),
+	}
+	if services := os.Getenv(servicesEnv); services != "" {
+		gw.Services = strings.Split(services, ",")
+	}
+	return gw, nil
+}
+
+// CORSFromEnv reads the CORS policy set by Env. It returns nil if no policy was set.
+func CORSFromEnv() (*manifest.CorsPolicy, error) {
+	raw := os.Getenv(corsEnv)
+	if raw == "" {
+		return nil, nil
+	}
+	policy := &manifest.CorsPolicy{}
+	if err := jsonpb.Unmarshal(strings.NewReader(raw), policy); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", corsEnv, err)
+	}
+	return policy, nil
+}
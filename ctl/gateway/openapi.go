@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/foldsh/fold/manifest/openapi"
+)
+
+// Prefix is the first path segment of the gateway's own endpoints. It can't clash with a service
+// because service names can't begin with an underscore.
+const Prefix = "_foldgw"
+
+var client = &http.Client{Timeout: 5 * time.Second}
+
+func serveGateway(c *gin.Context, gw *Gateway) {
+	if c.Request.Method == "GET" && c.Param("path") == "/openapi.json" {
+		fetch := func(service string) (*openapi.Document, error) {
+			return fetchOpenAPI(service, gw.AdminToken)
+		}
+		c.JSON(200, mergeOpenAPI(gw.Project, gw.Services, fetch))
+		return
+	}
+	c.JSON(404, gin.H{"title": "Resource not found"})
+}
+
+// mergeOpenAPI combines the OpenAPI documents of the services into one. Services which are not
+// running are left out of the document rather than failing the request.
+func mergeOpenAPI(
+	project string,
+	services []string,
+	fetch func(string) (*openapi.Document, error),
+) *openapi.Document {
+	docs := map[string]*openapi.Document{}
+	for _, service := range services {
+		doc, err := fetch(service)
+		if err != nil {
+			log.Printf("failed to fetch the OpenAPI document for %s: %v", service, err)
+			continue
+		}
+		docs[service] = doc
+	}
+	return openapi.Merge(project, docs)
+}
+
+func fetchOpenAPI(service, token string) (*openapi.Document, error) {
+	url := fmt.Sprintf("http://%s:6123/_foldadmin/openapi.json", service)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status %d", res.StatusCode)
+	}
+	var doc openapi.Document
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
@@ -0,0 +1,31 @@
+package gateway
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/manifest/openapi"
+)
+
+func TestMergeOpenAPISkipsUnavailableServices(t *testing.T) {
+	fetch := func(service string) (*openapi.Document, error) {
+		if service == "down" {
+			return nil, errors.New("connection refused")
+		}
+		return openapi.Generate(&manifest.Manifest{
+			Name:   service,
+			Routes: []*manifest.Route{{HttpMethod: manifest.FoldHTTPMethod_GET, Route: "/"}},
+		}), nil
+	}
+	doc := mergeOpenAPI("project", []string{"up", "down"}, fetch)
+	if doc.Info.Title != "project" {
+		t.Errorf("Expected the document to be titled project but found %s", doc.Info.Title)
+	}
+	if _, ok := doc.Paths["/up/"]; !ok {
+		t.Errorf("Expected the paths of up to be included but found %v", doc.Paths)
+	}
+	if len(doc.Paths) != 1 {
+		t.Errorf("Expected only the paths of up but found %v", doc.Paths)
+	}
+}
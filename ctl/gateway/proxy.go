@@ -9,17 +9,37 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/foldsh/fold/runtime/cors"
 )
 
-func Serve() {
+// The gateway doesn't know which methods each service supports, so preflight requests are
+// checked against all of them unless the policy restricts them.
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"}
+
+// Serve runs the gateway. If a CORS policy is given then the gateway answers preflight requests
+// itself and applies the policy to every response in place of the services' own policies.
+func Serve(gw *Gateway) {
+	var policy *cors.Policy
+	if gw.CORS != nil {
+		policy = cors.NewPolicy(gw.CORS)
+	}
 	r := gin.Default()
 	r.Any("/:service/*path", func(c *gin.Context) {
-		proxy(c)
+		if c.Param("service") == Prefix {
+			serveGateway(c, gw)
+			return
+		}
+		if policy != nil && cors.IsPreflight(c.Request) {
+			policy.Preflight(c.Writer, c.Request, httpMethods)
+			return
+		}
+		proxy(c, policy)
 	})
 	r.Run(":6123")
 }
 
-func proxy(c *gin.Context) error {
+func proxy(c *gin.Context, policy *cors.Policy) error {
 	service := c.Param("service")
 	urlStr := fmt.Sprintf("http://%s:6123", service)
 	log.Println(urlStr)
@@ -38,6 +58,13 @@ func proxy(c *gin.Context) error {
 		req.URL.Host = remote.Host
 		req.URL.Path = c.Param("path")
 	}
+	proxy.ModifyResponse = func(res *http.Response) error {
+		if policy != nil {
+			cors.Strip(res.Header)
+			policy.Decorate(res.Header, c.Request)
+		}
+		return nil
+	}
 	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 		if strings.Contains(err.Error(), "no such host") {
 			// This means that the the host wasn't found and therefore that the service name
@@ -14,6 +14,7 @@ type onDiskProject struct {
 	Email      string          `mapstructure:"email"`
 	Repository string          `mapstructure:"repository"`
 	Services   []onDiskService `mapstructure:"services"`
+	CORS       *CORS           `mapstructure:"cors"`
 }
 
 type onDiskService struct {
@@ -28,6 +29,7 @@ func (odp *onDiskProject) unmarshal(ctx *ctl.CmdCtx) *Project {
 		Maintainer: odp.Maintainer,
 		Email:      odp.Email,
 		Repository: odp.Repository,
+		CORS:       odp.CORS,
 		ctx:        ctx,
 	}
 	for _, ods := range odp.Services {
@@ -49,6 +51,7 @@ func marshalProject(p *Project) *onDiskProject {
 		Maintainer: p.Maintainer,
 		Email:      p.Email,
 		Repository: p.Repository,
+		CORS:       p.CORS,
 	}
 	for _, s := range p.Services {
 		ods := onDiskService{Name: s.Name, Path: s.Path, Mounts: s.Mounts}
@@ -92,6 +95,9 @@ func saveConfig(p *Project, to string) error {
 	v.Set("email", odp.Email)
 	v.Set("repository", odp.Repository)
 	v.Set("services", odp.Services)
+	if odp.CORS != nil {
+		v.Set("cors", odp.CORS)
+	}
 	if err := v.WriteConfigAs(filepath.Join(to, "fold.yaml")); err != nil {
 		p.ctx.Logger.Debugf("Failed to write config %+v", err)
 		return CantWriteConfig
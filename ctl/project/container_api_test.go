@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -83,7 +84,13 @@ func TestProjectUp(t *testing.T) {
 						ID:           fmt.Sprintf("%d", 0),
 						Name:         gwContainerName,
 						NetworkAlias: gwSvc.Name,
-						Environment:  map[string]string{"FOLD_SERVICE_NAME": gwSvc.Name},
+						Environment: map[string]string{
+							"FOLD_SERVICE_NAME":        gwSvc.Name,
+							"FOLD_ADMIN_TOKEN":         "admintoken",
+							"FOLD_GATEWAY_PROJECT":     proj.Name,
+							"FOLD_GATEWAY_SERVICES":    serviceNames(proj),
+							"FOLD_GATEWAY_ADMIN_TOKEN": "admintoken",
+						},
 					},
 				).
 				Return(nil)
@@ -112,7 +119,10 @@ func TestProjectUp(t *testing.T) {
 					ID:           fmt.Sprintf("%d", i),
 					Name:         containerName,
 					NetworkAlias: svc.Name,
-					Environment:  map[string]string{"FOLD_SERVICE_NAME": svc.Name},
+					Environment: map[string]string{
+						"FOLD_SERVICE_NAME": svc.Name,
+						"FOLD_ADMIN_TOKEN":  "admintoken",
+					},
 				}
 				api.
 					On("RunContainer", net, modifiedCon).
@@ -224,7 +234,7 @@ func makeProject(name string, services ...*project.Service) *project.Project {
 		ctl.NewCmdCtx(
 			context.Background(),
 			logging.NewTestLogger(),
-			&config.Config{},
+			&config.Config{AdminToken: "admintoken"},
 			output.NewColorOutput(),
 		),
 	)
@@ -235,3 +245,11 @@ func makeProject(name string, services ...*project.Service) *project.Project {
 	}
 	return p
 }
+
+func serviceNames(proj *project.Project) string {
+	names := make([]string, len(proj.Services))
+	for i, svc := range proj.Services {
+		names[i] = svc.Name
+	}
+	return strings.Join(names, ",")
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/foldsh/fold/ctl/container"
 	"github.com/foldsh/fold/ctl/gateway"
 	"github.com/foldsh/fold/ctl/output"
+	"github.com/foldsh/fold/manifest"
 )
 
 var (
@@ -28,6 +29,7 @@ type Project struct {
 	Email      string
 	Repository string
 	Services   []*Service
+	CORS       *CORS
 
 	gatewayPort int
 	ctx         *ctl.CmdCtx
@@ -155,6 +157,14 @@ func (p *Project) Up(out io.Writer, services ...*Service) error {
 			)
 			return err
 		}
+		if container != nil && service.DebugPort != 0 {
+			p.ctx.Informf(
+				"Service %s is already up, run foldctl down first to start it under a debugger",
+				service.Name,
+			)
+			service.container = container
+			continue
+		}
 		if container != nil {
 			p.ctx.Informf("Service %s is already up, no need to do anything", service.Name)
 			service.container = container
@@ -219,7 +229,17 @@ func (p *Project) network() *container.Network {
 }
 
 func (p *Project) gateway() *gateway.Gateway {
-	return &gateway.Gateway{Port: p.gatewayPort}
+	services := make([]string, len(p.Services))
+	for i, service := range p.Services {
+		services[i] = service.Name
+	}
+	return &gateway.Gateway{
+		Port:       p.gatewayPort,
+		CORS:       p.CORS.policy(),
+		Project:    p.Name,
+		Services:   services,
+		AdminToken: p.ctx.Config.AdminToken,
+	}
 }
 
 func (p *Project) startGateway(net *container.Network) error {
@@ -238,6 +258,11 @@ func (p *Project) startGateway(net *container.Network) error {
 		return fmt.Errorf("failed to pull image %s", imgName)
 	}
 	gwService := p.gatewayService(gw)
+	gwService.env, err = gw.Env()
+	if err != nil {
+		p.ctx.Logger.Debugf("failed to prepare the gateway environment: %v", err)
+		return err
+	}
 	err = gwService.Start(img, net)
 	if err != nil {
 		return err
@@ -283,6 +308,32 @@ func (p *Project) gatewayService(gw *gateway.Gateway) *Service {
 	return svc
 }
 
+// CORS is a project wide CORS policy which is applied by the local gateway. See
+// manifest.CorsPolicy for the meaning of each field.
+type CORS struct {
+	AllowedOrigins   []string `mapstructure:"allowed-origins" yaml:"allowed-origins,omitempty"`
+	AllowedMethods   []string `mapstructure:"allowed-methods" yaml:"allowed-methods,omitempty"`
+	AllowedHeaders   []string `mapstructure:"allowed-headers" yaml:"allowed-headers,omitempty"`
+	ExposedHeaders   []string `mapstructure:"exposed-headers" yaml:"exposed-headers,omitempty"`
+	AllowCredentials bool     `mapstructure:"allow-credentials" yaml:"allow-credentials,omitempty"`
+	// In seconds.
+	MaxAge int `mapstructure:"max-age" yaml:"max-age,omitempty"`
+}
+
+func (c *CORS) policy() *manifest.CorsPolicy {
+	if c == nil {
+		return nil
+	}
+	return &manifest.CorsPolicy{
+		AllowedOrigins:   c.AllowedOrigins,
+		AllowedMethods:   c.AllowedMethods,
+		AllowedHeaders:   c.AllowedHeaders,
+		ExposedHeaders:   c.ExposedHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           int32(c.MaxAge),
+	}
+}
+
 func (p *Project) pullImageIfRequired(image string) (*container.Image, error) {
 	img, err := p.api.GetImage(image)
 	if err != nil {
@@ -108,6 +108,12 @@ func TestProjectLoadingAndSaving(t *testing.T) {
 				Mounts: []string{"./foo", "./baz"},
 			},
 		},
+		CORS: &project.CORS{
+			AllowedOrigins:   []string{"https://*.fold.sh"},
+			AllowedHeaders:   []string{"Authorization"},
+			AllowCredentials: true,
+			MaxAge:           600,
+		},
 	}
 	p.SaveConfig(dir)
 	ctx := newCmdCtx(logger, dir)
@@ -25,9 +25,12 @@ type Service struct {
 	Path   string
 	Mounts []string
 	Port   int
+	// When set the service is run under a debugger listening on this port.
+	DebugPort int
 
 	project *Project
 	ctx     *ctl.CmdCtx
+	env     map[string]string
 
 	// Assigned dynamically
 	container *container.Container
@@ -89,7 +92,22 @@ func (s *Service) Start(img *container.Image, net *container.Network) error {
 		mounts = append(mounts, container.Mount{Src: src, Dst: dst})
 	}
 	con.Mounts = mounts
+	// This configures the runtime's service-name, which it passes on to the sdk in the handshake.
 	con.Environment = map[string]string{"FOLD_SERVICE_NAME": s.Name}
+	// foldctl reads the manifest, OpenAPI document and crash reports of the local services
+	// through the gateway, so every admin route is served alongside the service behind the
+	// token from the foldctl config.
+	if token := s.ctx.Config.AdminToken; token != "" {
+		con.Environment["FOLD_ADMIN_TOKEN"] = token
+	}
+	for key, value := range s.env {
+		con.Environment[key] = value
+	}
+	if s.DebugPort != 0 {
+		con.PublishedPorts = []int{s.DebugPort}
+		con.Environment["FOLD_DEBUG_ENABLED"] = "true"
+		con.Environment["FOLD_DEBUG_PORT"] = fmt.Sprintf("%d", s.DebugPort)
+	}
 
 	err = s.project.api.RunContainer(net, con)
 	if err != nil {
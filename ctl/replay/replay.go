@@ -0,0 +1,174 @@
+// Package replay sends the requests in a recording made by the runtime to a running local
+// project and compares the responses with the recorded ones.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/foldsh/fold/runtime/recorder"
+)
+
+var UnknownService = errors.New("the recording doesn't name a service, please choose one")
+
+// These headers describe the connection the request was recorded on rather than the request.
+// The recorded response is the one the service sent, before the runtime compressed it or replied
+// with a 304, so the headers which would make it do either are left out too.
+var skippedHeaders = map[string]struct{}{
+	"Accept-Encoding":   {},
+	"Connection":        {},
+	"Content-Length":    {},
+	"Host":              {},
+	"If-Modified-Since": {},
+	"If-None-Match":     {},
+	"Transfer-Encoding": {},
+}
+
+// Result is the outcome of replaying a single entry.
+type Result struct {
+	Entry   *recorder.Entry
+	Service string
+	// The status of the replayed response, zero if the request failed.
+	Status int
+	// Describes how the replayed response differs from the recorded one, empty if it matches.
+	Diff string
+	Err  error
+}
+
+// Matched returns true if the replayed response is the same as the recorded one.
+func (r *Result) Matched() bool {
+	return r.Err == nil && r.Diff == ""
+}
+
+type Option func(*Replayer)
+
+// Service sends every request to the service, rather than the one it was recorded for.
+func Service(name string) Option {
+	return func(r *Replayer) {
+		r.service = name
+	}
+}
+
+// Replayer sends recorded requests to the services behind the local gateway.
+type Replayer struct {
+	gatewayURL string
+	service    string
+	client     *http.Client
+}
+
+func NewReplayer(gatewayURL string, options ...Option) *Replayer {
+	r := &Replayer{gatewayURL: gatewayURL, client: &http.Client{Timeout: 30 * time.Second}}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// Replay sends the requests one at a time, in the order they were recorded.
+func (r *Replayer) Replay(entries []*recorder.Entry) []*Result {
+	results := make([]*Result, len(entries))
+	for i, entry := range entries {
+		results[i] = r.replay(entry)
+	}
+	return results
+}
+
+func (r *Replayer) replay(entry *recorder.Entry) *Result {
+	result := &Result{Entry: entry, Service: r.service}
+	if result.Service == "" {
+		result.Service = entry.Service
+	}
+	if result.Service == "" {
+		result.Err = UnknownService
+		return result
+	}
+	req, err := r.newRequest(result.Service, entry)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	result.Status = res.StatusCode
+	result.Diff = diff(entry, res.StatusCode, body)
+	return result
+}
+
+func (r *Replayer) newRequest(service string, entry *recorder.Entry) (*http.Request, error) {
+	recorded := entry.Request
+	u, err := url.Parse(r.gatewayURL)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("%s/%s", strings.TrimSuffix(u.Path, "/"), service)
+	u.Path = prefix + recorded.Path
+	u.RawQuery = recorded.RawQuery
+	// The recorded path is decoded, so the path as it was sent is used when there is one in
+	// order to keep e.g. an escaped slash.
+	if uri, err := url.ParseRequestURI(recorded.RequestURI); err == nil && uri.Path == recorded.Path {
+		u.RawPath = (&url.URL{Path: prefix}).EscapedPath() + uri.EscapedPath()
+	}
+	req, err := http.NewRequest(recorded.HTTPMethod, u.String(), bytes.NewReader(recorded.Body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range recorded.Headers {
+		if _, ok := skippedHeaders[http.CanonicalHeaderKey(key)]; ok {
+			continue
+		}
+		for _, value := range values {
+			// Redacted credentials can't be replayed so the request is sent without them.
+			if value != recorder.Redacted {
+				req.Header.Add(key, value)
+			}
+		}
+	}
+	return req, nil
+}
+
+// diff compares the replayed response with the recorded one. JSON bodies are compared by value,
+// so that the order of the fields and the formatting don't matter.
+func diff(entry *recorder.Entry, status int, body []byte) string {
+	if entry.Response == nil {
+		return fmt.Sprintf("no response was recorded: %s", entry.Error)
+	}
+	var diffs []string
+	if entry.Response.Status != status {
+		diffs = append(diffs, fmt.Sprintf("status: %d != %d", entry.Response.Status, status))
+	}
+	recorded, replayed, ok := decodeJSON(entry.Response.Body, body)
+	if ok {
+		if d := cmp.Diff(recorded, replayed); d != "" {
+			diffs = append(diffs, fmt.Sprintf("body (-recorded +replayed):\n%s", d))
+		}
+	} else if !bytes.Equal(entry.Response.Body, body) {
+		diffs = append(diffs, fmt.Sprintf("body:\n-%s\n+%s", entry.Response.Body, body))
+	}
+	return strings.Join(diffs, "\n")
+}
+
+func decodeJSON(recorded, replayed []byte) (interface{}, interface{}, bool) {
+	var a, b interface{}
+	if json.Unmarshal(recorded, &a) != nil || json.Unmarshal(replayed, &b) != nil {
+		return nil, nil, false
+	}
+	return a, b, true
+}
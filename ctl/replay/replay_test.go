@@ -0,0 +1,131 @@
+package replay_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/foldsh/fold/ctl/replay"
+	"github.com/foldsh/fold/runtime/recorder"
+	"github.com/foldsh/fold/runtime/transport"
+)
+
+func TestReplay(t *testing.T) {
+	var received []*http.Request
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r)
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/orders/items":
+			w.Write([]byte(`{"b": 2, "a": 1}`))
+		case "/orders/echo":
+			w.Write(body)
+		default:
+			w.WriteHeader(404)
+			w.Write([]byte("not found"))
+		}
+	}))
+	defer gateway.Close()
+
+	entries := []*recorder.Entry{
+		entry("GET", "/items", "", 200, `{"a":1,"b":2}`),
+		entry("POST", "/echo", "hello", 200, "hello"),
+		entry("GET", "/items", "", 200, `{"a":1,"b":3}`),
+		entry("GET", "/missing", "", 200, "ok"),
+	}
+	entries[0].Request.RawQuery = "page=2"
+	entries[0].Request.Headers = map[string][]string{
+		"Authorization":   {recorder.Redacted},
+		"Accept":          {"application/json"},
+		"Accept-Encoding": {"br"},
+		"If-None-Match":   {`"abc"`},
+	}
+	results := replay.NewReplayer(gateway.URL).Replay(entries)
+
+	for i, matched := range []bool{true, true, false, false} {
+		if results[i].Err != nil {
+			t.Fatalf("%+v", results[i].Err)
+		}
+		if results[i].Matched() != matched {
+			t.Errorf("Expected entry %d to match: %v, diff: %s", i, matched, results[i].Diff)
+		}
+	}
+	if !strings.Contains(results[2].Diff, "body") {
+		t.Errorf("Expected a body diff but found %q", results[2].Diff)
+	}
+	if !strings.Contains(results[3].Diff, "status: 200 != 404") {
+		t.Errorf("Expected a status diff but found %q", results[3].Diff)
+	}
+	first := received[0]
+	if first.URL.RawQuery != "page=2" || first.Header.Get("Accept") != "application/json" {
+		t.Errorf("Expected the query and headers to be replayed")
+	}
+	if _, ok := first.Header["Authorization"]; ok {
+		t.Errorf("Expected redacted headers to be left out")
+	}
+	if _, ok := first.Header["If-None-Match"]; ok {
+		t.Errorf("Expected conditional headers to be left out")
+	}
+	if encoding := first.Header.Get("Accept-Encoding"); strings.Contains(encoding, "br") {
+		t.Errorf("Expected the recorded Accept-Encoding to be left out")
+	}
+}
+
+func TestReplayEscapedPaths(t *testing.T) {
+	var received []*http.Request
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r)
+		w.Write([]byte("ok"))
+	}))
+	defer gateway.Close()
+
+	entries := []*recorder.Entry{
+		entry("GET", "/a?b", "", 200, "ok"),
+		entry("GET", "/100%", "", 200, "ok"),
+		entry("GET", "/a/b", "", 200, "ok"),
+	}
+	entries[2].Request.RequestURI = "/a%2Fb"
+	results := replay.NewReplayer(gateway.URL).Replay(entries)
+
+	for i, expected := range []string{"/orders/a%3Fb", "/orders/100%25", "/orders/a%2Fb"} {
+		if results[i].Err != nil {
+			t.Fatalf("%+v", results[i].Err)
+		}
+		if path := received[i].URL.EscapedPath(); path != expected {
+			t.Errorf("Expected the path %s but found %s", expected, path)
+		}
+		if received[i].URL.RawQuery != "" {
+			t.Errorf("Expected no query but found %s", received[i].URL.RawQuery)
+		}
+	}
+}
+
+func TestReplayToAnotherService(t *testing.T) {
+	var path string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+	}))
+	defer gateway.Close()
+
+	e := entry("GET", "/items", "", 200, "")
+	e.Service = ""
+	result := replay.NewReplayer(gateway.URL).Replay([]*recorder.Entry{e})[0]
+	if !errors.Is(result.Err, replay.UnknownService) {
+		t.Errorf("Expected an error when the service is unknown but got %v", result.Err)
+	}
+	replay.NewReplayer(gateway.URL, replay.Service("stock")).Replay([]*recorder.Entry{e})
+	if path != "/stock/items" {
+		t.Errorf("Expected the request to be sent to the stock service but got %s", path)
+	}
+}
+
+func entry(method, path, body string, status int, response string) *recorder.Entry {
+	return &recorder.Entry{
+		Service:  "orders",
+		Request:  &transport.Request{HTTPMethod: method, Path: path, Body: []byte(body)},
+		Response: &transport.Response{Status: status, Body: []byte(response)},
+	}
+}
@@ -0,0 +1,84 @@
+package grpctest
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/transport"
+	"github.com/foldsh/fold/runtime/transport/pb"
+)
+
+// StartHTTP serves the server over the HTTP ingress protocol instead of gRPC, so that it behaves
+// like an SDK which can't use gRPC.
+func (s *Server) StartHTTP() {
+	lis, err := net.Listen("unix", s.socket)
+	if err != nil {
+		s.t.Fatalf("%+v", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(transport.HTTPInitializePath, s.handleInitialize)
+	mux.HandleFunc(transport.HTTPManifestPath, s.handleManifest)
+	mux.HandleFunc(transport.HTTPRequestPath, s.handleRequest)
+	mux.HandleFunc(transport.HTTPHealthPath, s.handleHealth)
+	server := &http.Server{Handler: mux}
+	s.mu.Lock()
+	s.http = server
+	s.mu.Unlock()
+	if err := server.Serve(lis); err != nil && err != http.ErrServerClosed {
+		s.t.Fatalf("%+v", err)
+	}
+}
+
+func (s *Server) handleInitialize(w http.ResponseWriter, r *http.Request) {
+	in := &pb.InitializeReq{}
+	if err := jsonpb.Unmarshal(r.Body, in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.LastInitialize = in
+	if s.InitializeRes == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.writeJSON(w, s.InitializeRes)
+}
+
+func (s *Server) handleManifest(w http.ResponseWriter, r *http.Request) {
+	res, _ := s.GetManifest(r.Context(), &pb.ManifestReq{})
+	s.writeJSON(w, res)
+}
+
+func (s *Server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	in := &manifest.FoldHTTPRequest{}
+	if err := jsonpb.Unmarshal(r.Body, in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res, _ := s.DoRequest(r.Context(), in)
+	s.writeJSON(w, res)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if s.Health == nil {
+		http.NotFound(w, r)
+		return
+	}
+	res, err := s.Health.Check(r.Context(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, res)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, m proto.Message) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := (&jsonpb.Marshaler{}).Marshal(w, m); err != nil {
+		s.t.Errorf("%+v", err)
+	}
+}
@@ -3,10 +3,14 @@ package grpctest
 import (
 	"context"
 	"net"
+	"net/http"
 	"os"
+	"sync"
 	"testing"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
@@ -15,8 +19,11 @@ import (
 
 type Server struct {
 	pb.UnimplementedFoldIngressServer
-	socket   string
+	socket string
+	// Guards the servers, which are created by Start but may be stopped by another goroutine.
+	mu       sync.Mutex
 	server   *grpc.Server
+	http     *http.Server
 	manifest *manifest.Manifest
 	t        *testing.T
 	logger   logging.Logger
@@ -24,13 +31,32 @@ type Server struct {
 	ManifestCalls  int
 	DoRequestCalls int
 	LastRequest    *manifest.FoldHTTPRequest
+	LastInitialize *pb.InitializeReq
+	// The response to Initialize. If it is nil then the server behaves like an SDK from before
+	// the handshake was added.
+	InitializeRes *pb.InitializeRes
+	// Serves the gRPC health protocol. If it is nil when the server is started then the server
+	// behaves like an SDK without health checks.
+	Health *health.Server
 }
 
 func NewServer(t *testing.T, logger logging.Logger, foldSockAddr string) *Server {
 	manifest := &manifest.Manifest{
 		Version: &manifest.Version{Major: 1, Minor: 0, Patch: 0},
 	}
-	return &Server{socket: foldSockAddr, manifest: manifest, t: t, logger: logger}
+	return &Server{
+		socket:   foldSockAddr,
+		manifest: manifest,
+		t:        t,
+		logger:   logger,
+		InitializeRes: &pb.InitializeRes{
+			SdkName:            "grpctest",
+			SdkVersion:         "v0.0.0",
+			MinProtocolVersion: 1,
+			MaxProtocolVersion: 1,
+		},
+		Health: health.NewServer(),
+	}
 }
 
 func (s *Server) Start() {
@@ -38,16 +64,41 @@ func (s *Server) Start() {
 	if err != nil {
 		s.t.Fatalf("%+v", err)
 	}
-	s.server = grpc.NewServer()
-	pb.RegisterFoldIngressServer(s.server, s)
-	if err := s.server.Serve(lis); err != nil {
+	server := grpc.NewServer()
+	pb.RegisterFoldIngressServer(server, s)
+	if s.Health != nil {
+		healthpb.RegisterHealthServer(server, s.Health)
+	}
+	s.mu.Lock()
+	s.server = server
+	s.mu.Unlock()
+	if err := server.Serve(lis); err != nil {
 		s.t.Fatalf("%+v", err)
 	}
 }
 
 func (s *Server) Stop() {
 	os.Remove(s.socket)
-	s.server.Stop()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.server != nil {
+		s.server.Stop()
+	}
+	if s.http != nil {
+		s.http.Close()
+	}
+}
+
+func (s *Server) Initialize(
+	ctx context.Context,
+	in *pb.InitializeReq,
+) (*pb.InitializeRes, error) {
+	s.logger.Debugf("Handling Initialize")
+	s.LastInitialize = in
+	if s.InitializeRes == nil {
+		return s.UnimplementedFoldIngressServer.Initialize(ctx, in)
+	}
+	return s.InitializeRes, nil
 }
 
 func (s *Server) GetManifest(
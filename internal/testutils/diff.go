@@ -18,7 +18,13 @@ func DiffManifest(t *testing.T, expectation, actual *manifest.Manifest) {
 	if diff := cmp.Diff(
 		expectation,
 		actual,
-		cmpopts.IgnoreUnexported(manifest.Manifest{}, manifest.Version{}, manifest.BuildInfo{}, manifest.Route{}),
+		cmpopts.IgnoreUnexported(
+			manifest.Manifest{},
+			manifest.Version{},
+			manifest.BuildInfo{},
+			manifest.Route{},
+			manifest.CorsPolicy{},
+		),
 	); diff != "" {
 		t.Errorf("Manifest does not match exepctation(-want +got):\n%s", diff)
 	}
@@ -0,0 +1,283 @@
+// Package openapi generates OpenAPI 3 documents from service manifests.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+const Version = "3.0.3"
+
+// The name of the security scheme used by routes which require authentication.
+const bearerAuth = "bearerAuth"
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components *Components          `json:"components,omitempty"`
+	Tags       []Tag                `json:"tags,omitempty"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// PathItem holds the operations for a single path, keyed by the lower case HTTP method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string          `json:"name"`
+	In       string          `json:"in"`
+	Required bool            `json:"required"`
+	Schema   json.RawMessage `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema json.RawMessage `json:"schema"`
+}
+
+type Response struct {
+	Description string `json:"description"`
+}
+
+var stringSchema = json.RawMessage(`{"type":"string"}`)
+
+// Generate creates the document describing a single service.
+func Generate(m *manifest.Manifest) *Document {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: m.Name, Version: version(m.Version)},
+		Paths:   map[string]*PathItem{},
+	}
+	tags := map[string]struct{}{}
+	for _, route := range m.Routes {
+		path, params := convertPath(route.Route)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		method := strings.ToLower(route.HttpMethod.String())
+		(*item)[method] = operation(route, method, params)
+		for _, tag := range route.Tags {
+			tags[tag] = struct{}{}
+		}
+		if route.Auth != nil {
+			doc.Components = &Components{
+				SecuritySchemes: map[string]SecurityScheme{
+					bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			}
+		}
+	}
+	doc.Tags = sortedTags(tags)
+	return doc
+}
+
+// Merge combines the documents of several services into one. Each service's paths are prefixed
+// with its name, matching the way the gateway routes requests. The operations of the documents
+// are modified in place.
+func Merge(title string, docs map[string]*Document) *Document {
+	merged := &Document{
+		OpenAPI: Version,
+		Info:    Info{Title: title, Version: "0.0.0"},
+		Paths:   map[string]*PathItem{},
+	}
+	tags := map[string]struct{}{}
+	names := make([]string, 0, len(docs))
+	for name := range docs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc := docs[name]
+		for path, item := range doc.Paths {
+			for _, op := range *item {
+				op.OperationID = name + upperFirst(op.OperationID)
+				if len(op.Tags) == 0 {
+					// Untagged operations are grouped by their service.
+					op.Tags = []string{name}
+				}
+				for _, tag := range op.Tags {
+					tags[tag] = struct{}{}
+				}
+			}
+			merged.Paths["/"+name+path] = item
+		}
+		if doc.Components != nil {
+			merged.Components = doc.Components
+		}
+	}
+	merged.Tags = sortedTags(tags)
+	return merged
+}
+
+func operation(route *manifest.Route, method string, params []string) *Operation {
+	op := &Operation{
+		OperationID: operationID(method, route.Route),
+		Summary:     route.Summary,
+		Description: route.Description,
+		Tags:        route.Tags,
+		Responses:   map[string]Response{"200": {Description: "OK"}},
+	}
+	for _, param := range params {
+		op.Parameters = append(
+			op.Parameters,
+			Parameter{Name: param, In: "path", Required: true, Schema: stringSchema},
+		)
+	}
+	if route.QuerySchema != "" {
+		op.Parameters = append(op.Parameters, queryParameters(route.QuerySchema)...)
+	}
+	if route.BodySchema != "" {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]MediaType{
+				"application/json": {Schema: json.RawMessage(route.BodySchema)},
+			},
+		}
+	}
+	if route.BodySchema != "" || route.QuerySchema != "" {
+		op.Responses["400"] = Response{Description: "The request is invalid"}
+	}
+	if route.Auth != nil {
+		scopes := route.Auth.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		op.Security = []map[string][]string{{bearerAuth: scopes}}
+		op.Responses["401"] = Response{Description: "A valid bearer token is required"}
+		if len(route.Auth.Scopes) > 0 {
+			op.Responses["403"] = Response{
+				Description: "The token does not grant the required scopes",
+			}
+		}
+	}
+	if route.RateLimit != nil {
+		op.Responses["429"] = Response{Description: "Too many requests"}
+	}
+	return op
+}
+
+// queryParameters describes each property of the query schema as a parameter.
+func queryParameters(raw string) []Parameter {
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}
+	if err := json.Unmarshal([]byte(raw), &schema); err != nil {
+		return nil
+	}
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var params []Parameter
+	for _, name := range names {
+		params = append(params, Parameter{
+			Name:     name,
+			In:       "query",
+			Required: required[name],
+			Schema:   schema.Properties[name],
+		})
+	}
+	return params
+}
+
+// convertPath turns a route specification such as /items/:id into an OpenAPI path such as
+// /items/{id}, returning the names of the path parameters.
+func convertPath(route string) (string, []string) {
+	var params []string
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			name := segment[1:]
+			params = append(params, name)
+			segments[i] = fmt.Sprintf("{%s}", name)
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID builds an identifier such as getItemsById from the method and route.
+func operationID(method, route string) string {
+	var b strings.Builder
+	b.WriteString(method)
+	for _, segment := range strings.Split(route, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			b.WriteString("By")
+			segment = segment[1:]
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			b.WriteString(upperFirst(word))
+		}
+	}
+	return b.String()
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func version(v *manifest.Version) string {
+	if v == nil {
+		return "0.0.0"
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+func sortedTags(tags map[string]struct{}) []Tag {
+	var result []Tag
+	for name := range tags {
+		result = append(result, Tag{Name: name})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
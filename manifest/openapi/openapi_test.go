@@ -0,0 +1,78 @@
+package openapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/manifest"
+)
+
+func TestGenerate(t *testing.T) {
+	m := &manifest.Manifest{
+		Name:    "items",
+		Version: &manifest.Version{Major: 1, Minor: 2, Patch: 3},
+		Routes: []*manifest.Route{
+			{
+				HttpMethod:  manifest.FoldHTTPMethod_GET,
+				Route:       "/items",
+				Summary:     "List items",
+				Tags:        []string{"items"},
+				QuerySchema: `{"type":"object","required":["page"],"properties":{"page":{"type":"integer"}}}`,
+				RateLimit:   &manifest.RateLimit{Requests: 10, Period: 60},
+			},
+			{
+				HttpMethod:  manifest.FoldHTTPMethod_PUT,
+				Route:       "/items/:id",
+				Description: "Replaces an item.",
+				BodySchema:  `{"type":"object"}`,
+				Auth:        &manifest.AuthPolicy{Scopes: []string{"items:write"}},
+			},
+			{HttpMethod: manifest.FoldHTTPMethod_GET, Route: "/files/*path"},
+		},
+	}
+	assertMatchesGolden(t, Generate(m), "testdata/items.json")
+}
+
+func TestMerge(t *testing.T) {
+	docs := map[string]*Document{
+		"items": Generate(&manifest.Manifest{
+			Name:   "items",
+			Routes: []*manifest.Route{{HttpMethod: manifest.FoldHTTPMethod_GET, Route: "/"}},
+		}),
+		"users": Generate(&manifest.Manifest{
+			Name: "users",
+			Routes: []*manifest.Route{
+				{HttpMethod: manifest.FoldHTTPMethod_POST, Route: "/users", Tags: []string{"admin"}},
+			},
+		}),
+	}
+	assertMatchesGolden(t, Merge("project", docs), "testdata/merged.json")
+}
+
+func TestOperationID(t *testing.T) {
+	cases := []struct {
+		method, route, expected string
+	}{
+		{"get", "/", "get"},
+		{"get", "/items", "getItems"},
+		{"put", "/items/:id", "putItemsById"},
+		{"get", "/user-profiles/:user_id/*path", "getUserProfilesByUserIdByPath"},
+	}
+	for _, tc := range cases {
+		testutils.Diff(t, tc.expected, operationID(tc.method, tc.route), "operationId did not match")
+	}
+}
+
+func assertMatchesGolden(t *testing.T, doc *Document, golden string) {
+	expected, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	actual, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	testutils.Diff(t, string(expected), string(actual)+"\n", "Generated document did not match")
+}
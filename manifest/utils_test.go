@@ -42,15 +42,36 @@ var (
 			"path":       "./build/path",
 		},
 		"routes": []interface{}{
-			map[string]interface{}{"httpMethod": "GET", "route": "/get/:var"},
-			map[string]interface{}{"httpMethod": "PUT", "route": "/put/:var"},
-			map[string]interface{}{"httpMethod": "POST", "route": "/post/:var"},
-			map[string]interface{}{"httpMethod": "DELETE", "route": "/delete/:var"},
-			map[string]interface{}{"httpMethod": "PATCH", "route": "/patch/:var"},
+			route("GET", "/get/:var"),
+			route("PUT", "/put/:var"),
+			route("POST", "/post/:var"),
+			route("DELETE", "/delete/:var"),
+			route("PATCH", "/patch/:var"),
 		},
+		"cors":        nil,
+		"compression": nil,
+		"static":      []interface{}{},
 	}
 )
 
+// route is the JSON for a route with only its method and path set. The manifest is written with
+// every field, so the rest have their default values.
+func route(method, path string) map[string]interface{} {
+	return map[string]interface{}{
+		"httpMethod":  method,
+		"route":       path,
+		"rateLimit":   nil,
+		"auth":        nil,
+		"cache":       nil,
+		"bodySchema":  "",
+		"querySchema": "",
+		"summary":     "",
+		"description": "",
+		"tags":        []interface{}{},
+		"idempotency": nil,
+	}
+}
+
 func TestWriteJSON(t *testing.T) {
 	buf := &bytes.Buffer{}
 	manifest.WriteJSON(buf, m)
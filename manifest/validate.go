@@ -0,0 +1,128 @@
+package manifest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// AdminPrefix is reserved for the runtime's admin routes. Services can not register routes
+// underneath it.
+const AdminPrefix = "/_foldadmin"
+
+// RouteError describes why a route in a manifest is invalid.
+type RouteError struct {
+	Route  string `json:"route"`
+	Method string `json:"method"`
+	Reason string `json:"reason"`
+}
+
+func (re RouteError) Error() string {
+	return fmt.Sprintf("invalid route %s %s: %s", re.Method, re.Route, re.Reason)
+}
+
+// InvalidManifest is returned by Validate and lists every invalid route in the manifest.
+type InvalidManifest struct {
+	Errors []RouteError `json:"errors"`
+}
+
+func (im InvalidManifest) Error() string {
+	reasons := make([]string, len(im.Errors))
+	for i, err := range im.Errors {
+		reasons[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid manifest: %s", strings.Join(reasons, "; "))
+}
+
+// Validate checks that every route in the manifest can be served by the runtime. Routes must be
+// unique, must not be under the admin prefix and must not conflict with each other, e.g.
+// /items/:id and /items/:name can't both be registered for the same method. Static mounts are
+// checked in the same way, they are reported as GET routes for their prefix.
+func Validate(m *Manifest) error {
+	var errs []RouteError
+	// The runtime uses a separate tree for each method so routes only conflict when they have
+	// the same method.
+	routers := map[FoldHTTPMethod]*httprouter.Router{}
+	seen := map[FoldHTTPMethod]map[string]bool{}
+	for _, route := range m.Routes {
+		method := route.HttpMethod.String()
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, RouteError{route.Route, method, fmt.Sprintf(format, args...)})
+		}
+		if _, ok := FoldHTTPMethod_name[int32(route.HttpMethod)]; !ok {
+			fail("unknown HTTP method")
+			continue
+		}
+		if !strings.HasPrefix(route.Route, "/") {
+			fail("the path must begin with /")
+			continue
+		}
+		if route.Route == AdminPrefix || strings.HasPrefix(route.Route, AdminPrefix+"/") {
+			fail("paths under %s are reserved for the runtime", AdminPrefix)
+			continue
+		}
+		idempotent := route.HttpMethod == FoldHTTPMethod_POST ||
+			route.HttpMethod == FoldHTTPMethod_PATCH
+		if route.Idempotency != nil && !idempotent {
+			fail("idempotency keys are only supported on POST and PATCH routes")
+			continue
+		}
+		if seen[route.HttpMethod] == nil {
+			seen[route.HttpMethod] = map[string]bool{}
+			routers[route.HttpMethod] = httprouter.New()
+		}
+		if seen[route.HttpMethod][route.Route] {
+			fail("the route is registered more than once")
+			continue
+		}
+		seen[route.HttpMethod][route.Route] = true
+		if reason := register(routers[route.HttpMethod], method, route.Route); reason != "" {
+			fail(reason)
+		}
+	}
+	errs = append(errs, validateStatic(m.Static)...)
+	if len(errs) > 0 {
+		return InvalidManifest{errs}
+	}
+	return nil
+}
+
+func validateStatic(mounts []*StaticMount) []RouteError {
+	var errs []RouteError
+	prefixes := map[string]bool{}
+	for _, mount := range mounts {
+		fail := func(format string, args ...interface{}) {
+			errs = append(errs, RouteError{mount.Prefix, "GET", fmt.Sprintf(format, args...)})
+		}
+		prefix := strings.TrimSuffix(mount.Prefix, "/")
+		switch {
+		case !strings.HasPrefix(mount.Prefix, "/"):
+			fail("the prefix must begin with /")
+		case prefix == AdminPrefix || strings.HasPrefix(prefix, AdminPrefix+"/"):
+			fail("paths under %s are reserved for the runtime", AdminPrefix)
+		case strings.ContainsAny(prefix, ":*"):
+			fail("the prefix can't contain wildcards")
+		case mount.Dir == "":
+			fail("the directory must be set")
+		case prefixes[prefix]:
+			fail("the prefix is mounted more than once")
+		}
+		prefixes[prefix] = true
+	}
+	return errs
+}
+
+// register adds the route to the router and returns the reason it was rejected, if it was.
+// httprouter panics when a route conflicts with one that has already been registered, so this
+// is the only way to find out exactly what the runtime will accept.
+func register(router *httprouter.Router, method, path string) (reason string) {
+	defer func() {
+		if r := recover(); r != nil {
+			reason = fmt.Sprint(r)
+		}
+	}()
+	router.Handler(method, path, http.NotFoundHandler())
+	return ""
+}
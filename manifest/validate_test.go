@@ -0,0 +1,124 @@
+package manifest_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/manifest"
+)
+
+func TestValidate(t *testing.T) {
+	get := manifest.FoldHTTPMethod_GET
+	put := manifest.FoldHTTPMethod_PUT
+	cases := []struct {
+		name     string
+		routes   []*manifest.Route
+		expected []manifest.RouteError
+	}{
+		{
+			"Valid routes",
+			[]*manifest.Route{
+				{HttpMethod: get, Route: "/items"},
+				{HttpMethod: get, Route: "/items/:id"},
+				{HttpMethod: put, Route: "/items/:id"},
+				{HttpMethod: get, Route: "/files/*path"},
+			},
+			nil,
+		},
+		{
+			"Duplicate routes",
+			[]*manifest.Route{
+				{HttpMethod: get, Route: "/items"},
+				{HttpMethod: get, Route: "/items"},
+			},
+			[]manifest.RouteError{{"/items", "GET", "the route is registered more than once"}},
+		},
+		{
+			"Reserved paths",
+			[]*manifest.Route{{HttpMethod: get, Route: "/_foldadmin/healthz"}},
+			[]manifest.RouteError{
+				{"/_foldadmin/healthz", "GET", "paths under /_foldadmin are reserved for the runtime"},
+			},
+		},
+		{
+			"Relative paths",
+			[]*manifest.Route{{HttpMethod: get, Route: "items"}},
+			[]manifest.RouteError{{"items", "GET", "the path must begin with /"}},
+		},
+		{
+			"Unknown methods",
+			[]*manifest.Route{{HttpMethod: manifest.FoldHTTPMethod(100), Route: "/items"}},
+			[]manifest.RouteError{{"/items", "100", "unknown HTTP method"}},
+		},
+		{
+			"Idempotency on safe methods",
+			[]*manifest.Route{
+				{HttpMethod: get, Route: "/items", Idempotency: &manifest.IdempotencyPolicy{}},
+				{
+					HttpMethod:  manifest.FoldHTTPMethod_POST,
+					Route:       "/items",
+					Idempotency: &manifest.IdempotencyPolicy{},
+				},
+			},
+			[]manifest.RouteError{
+				{"/items", "GET", "idempotency keys are only supported on POST and PATCH routes"},
+			},
+		},
+		{
+			"Conflicting wildcards",
+			[]*manifest.Route{
+				{HttpMethod: get, Route: "/items/:id"},
+				{HttpMethod: get, Route: "/items/:name"},
+			},
+			[]manifest.RouteError{{
+				"/items/:name",
+				"GET",
+				"':name' in new path '/items/:name' conflicts with existing wildcard ':id' " +
+					"in existing prefix '/items/:id'",
+			}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := manifest.Validate(&manifest.Manifest{Routes: tc.routes})
+			if tc.expected == nil {
+				if err != nil {
+					t.Fatalf("Expected the manifest to be valid but got %v", err)
+				}
+				return
+			}
+			var invalid manifest.InvalidManifest
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Expected an InvalidManifest error but got %v", err)
+			}
+			testutils.Diff(t, tc.expected, invalid.Errors, "Route errors did not match")
+		})
+	}
+}
+
+func TestValidateStatic(t *testing.T) {
+	err := manifest.Validate(&manifest.Manifest{
+		Static: []*manifest.StaticMount{
+			{Prefix: "/", Dir: "./dist", Fallback: "index.html"},
+			{Prefix: "/assets/", Dir: "./assets"},
+			{Prefix: "/assets", Dir: "./public"},
+			{Prefix: "docs", Dir: "./docs"},
+			{Prefix: "/_foldadmin/files", Dir: "./files"},
+			{Prefix: "/files/:name", Dir: "./files"},
+			{Prefix: "/images"},
+		},
+	})
+	var invalid manifest.InvalidManifest
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected an InvalidManifest error but got %v", err)
+	}
+	expected := []manifest.RouteError{
+		{"/assets", "GET", "the prefix is mounted more than once"},
+		{"docs", "GET", "the prefix must begin with /"},
+		{"/_foldadmin/files", "GET", "paths under /_foldadmin are reserved for the runtime"},
+		{"/files/:name", "GET", "the prefix can't contain wildcards"},
+		{"/images", "GET", "the directory must be set"},
+	}
+	testutils.Diff(t, expected, invalid.Errors, "Static mount errors did not match")
+}
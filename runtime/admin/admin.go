@@ -0,0 +1,313 @@
+// Package admin implements the /_foldadmin endpoints exposed by the runtime. These are kept apart
+// from the service's own routes so that they can be served on a separate listener and protected
+// with a bearer token, while the health endpoints can optionally remain public.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/manifest/openapi"
+)
+
+// Prefix is reserved for the admin routes. Services can not register routes underneath it.
+const Prefix = manifest.AdminPrefix
+
+// IsAdminPath returns true if the path falls under the reserved admin prefix.
+func IsAdminPath(path string) bool {
+	return path == Prefix || strings.HasPrefix(path, Prefix+"/")
+}
+
+type Admin struct {
+	logger logging.Logger
+	router *httprouter.Router
+	shared *httprouter.Router
+	public *httprouter.Router
+	token  string
+	health func() bool
+	probe  func() ProbeStatus
+
+	mutex    sync.RWMutex
+	manifest *manifest.Manifest
+	err      error
+}
+
+type Option func(*Admin)
+
+// WithToken requires every request to a protected admin route to carry the given bearer token.
+func WithToken(token string) Option {
+	return func(a *Admin) {
+		a.token = token
+	}
+}
+
+// HealthCheck sets the function used to determine whether or not the service is healthy.
+func HealthCheck(health func() bool) Option {
+	return func(a *Admin) {
+		a.health = health
+	}
+}
+
+// ProbeStatus is the result of the runtime's most recent health checks of the process.
+type ProbeStatus struct {
+	// The number of consecutive checks which have failed.
+	Failures int `json:"failures"`
+	// The error returned by the most recent failed check.
+	Error string `json:"error,omitempty"`
+	// Whether the failures have reached the threshold at which the process is unhealthy.
+	Unhealthy bool `json:"-"`
+}
+
+// Probe sets the function which reports the result of the health checks of the process. By
+// default the process is assumed to be healthy.
+func Probe(probe func() ProbeStatus) Option {
+	return func(a *Admin) {
+		a.probe = probe
+	}
+}
+
+func NewAdmin(logger logging.Logger, options ...Option) *Admin {
+	a := &Admin{
+		logger: logger,
+		router: newRouter(),
+		shared: newRouter(),
+		public: newRouter(),
+		health: func() bool { return true },
+		probe:  func() ProbeStatus { return ProbeStatus{} },
+	}
+	for _, option := range options {
+		option(a)
+	}
+	a.HandlePublic("GET", "/healthz", a.healthz)
+	a.HandlePublic("GET", "/readyz", a.readyz)
+	a.Handle("GET", "/manifest", a.getManifest)
+	a.Handle("GET", "/openapi.json", a.getOpenAPI)
+	// Profiles of the runtime itself, rather than of the service.
+	a.HandleSensitive("GET", "/debug/pprof/*profile", profile)
+	a.HandleSensitive("POST", "/debug/pprof/*profile", profile)
+	return a
+}
+
+// Handle registers a protected admin route. The path is relative to the admin prefix.
+func (a *Admin) Handle(method, path string, handler http.HandlerFunc) {
+	a.router.Handler(method, Prefix+path, a.authenticate(handler))
+	a.shared.Handler(method, Prefix+path, a.authenticate(handler))
+}
+
+// HandleSensitive registers a protected admin route which exposes more than the state of the
+// runtime, such as the output of the service or profiles of the runtime. It is only served
+// alongside the service when a token is required, see Shared.
+func (a *Admin) HandleSensitive(method, path string, handler http.HandlerFunc) {
+	a.router.Handler(method, Prefix+path, a.authenticate(handler))
+	a.shared.Handler(method, Prefix+path, a.requireToken(a.authenticate(handler)))
+}
+
+// HandlePublic registers an admin route which does not require authentication, such as the
+// health checks. The path is relative to the admin prefix.
+func (a *Admin) HandlePublic(method, path string, handler http.HandlerFunc) {
+	a.router.Handler(method, Prefix+path, handler)
+	a.shared.Handler(method, Prefix+path, handler)
+	a.public.Handler(method, Prefix+path, handler)
+}
+
+// SetManifest updates the manifest served by the admin endpoints.
+func (a *Admin) SetManifest(m *manifest.Manifest) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.manifest = m
+}
+
+// SetError records a problem with the service, such as an invalid manifest, which is reported by
+// the health check until it is cleared with a nil error.
+func (a *Admin) SetError(err error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.err = err
+}
+
+func (a *Admin) Error() error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.err
+}
+
+// Manifest returns the manifest of the currently running service, it is nil when the service
+// is not up.
+func (a *Admin) Manifest() *manifest.Manifest {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.manifest
+}
+
+// ServeHTTP serves all of the admin routes. It is meant for a dedicated admin listener.
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.router.ServeHTTP(w, r)
+}
+
+// Shared returns a handler which serves the admin routes alongside the service. Unlike
+// ServeHTTP, the sensitive routes receive a 404 unless a token is required.
+func (a *Admin) Shared() http.Handler {
+	return a.shared
+}
+
+// Public returns a handler which only serves the public admin routes, i.e. the health checks.
+// Requests for any other admin route will receive a 404.
+func (a *Admin) Public() http.Handler {
+	return a.public
+}
+
+func (a *Admin) requireToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.token == "" {
+			notFound(w, r)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func (a *Admin) authenticate(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.token == "" {
+			handler(w, r)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="foldadmin"`)
+			httpError(w, http.StatusUnauthorized, `{"title":"Unauthorized"}`)
+			return
+		}
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) != 1 {
+			httpError(w, http.StatusForbidden, `{"title":"Forbidden"}`)
+			return
+		}
+		handler(w, r)
+	}
+}
+
+type health struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	// The routes which were rejected when the error is an invalid manifest.
+	Routes []manifest.RouteError `json:"routes,omitempty"`
+	// The health checks of the process, only included when they are failing.
+	Probe *ProbeStatus `json:"probe,omitempty"`
+}
+
+func (a *Admin) healthz(w http.ResponseWriter, r *http.Request) {
+	h, code := health{Status: "OK"}, http.StatusOK
+	if err := a.Error(); err != nil {
+		h.Error = err.Error()
+		var invalid manifest.InvalidManifest
+		if errors.As(err, &invalid) {
+			h.Routes = invalid.Errors
+		}
+	}
+	if probe := a.probe(); probe.Failures > 0 {
+		h.Probe = &probe
+		if probe.Unhealthy {
+			h.Status, code = "UNHEALTHY", http.StatusServiceUnavailable
+		}
+	}
+	if !a.health() {
+		h.Status, code = "DOWN", http.StatusServiceUnavailable
+	}
+	writeStatus(w, code, h)
+}
+
+type readiness struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// readyz reports whether the service can take traffic right now. Unlike healthz, a single failed
+// health check is enough for the service not to be ready.
+func (a *Admin) readyz(w http.ResponseWriter, r *http.Request) {
+	rd, code := readiness{Status: "READY"}, http.StatusOK
+	if !a.health() {
+		rd.Reason = "the service is down"
+	} else if a.Manifest() == nil {
+		rd.Reason = "the service has not been routed yet"
+	} else if probe := a.probe(); probe.Failures > 0 {
+		rd.Reason = fmt.Sprintf("the last health check failed: %s", probe.Error)
+	}
+	if rd.Reason != "" {
+		rd.Status, code = "NOT_READY", http.StatusServiceUnavailable
+	}
+	writeStatus(w, code, rd)
+}
+
+func writeStatus(w http.ResponseWriter, code int, status interface{}) {
+	bs, err := json.Marshal(status)
+	if err != nil {
+		httpError(w, 500, `{"title":"Failed to marshal status to JSON"}`)
+		return
+	}
+	if code != http.StatusOK {
+		httpError(w, code, string(bs))
+		return
+	}
+	w.WriteHeader(code)
+	w.Write(bs)
+}
+
+func (a *Admin) getManifest(w http.ResponseWriter, r *http.Request) {
+	m := a.Manifest()
+	if m == nil {
+		httpError(w, http.StatusServiceUnavailable, `{"title":"Service is down"}`)
+		return
+	}
+	// If successful Write implicity sets the 200 response on the ResponseWriter
+	if err := manifest.WriteJSON(w, m); err != nil {
+		httpError(w, 500, `{"title":"Failed to marshal manifest to JSON"}`)
+		return
+	}
+}
+
+func (a *Admin) getOpenAPI(w http.ResponseWriter, r *http.Request) {
+	m := a.Manifest()
+	if m == nil {
+		httpError(w, http.StatusServiceUnavailable, `{"title":"Service is down"}`)
+		return
+	}
+	bs, err := json.Marshal(openapi.Generate(m))
+	if err != nil {
+		httpError(w, 500, `{"title":"Failed to marshal OpenAPI document to JSON"}`)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(bs)
+}
+
+func newRouter() *httprouter.Router {
+	router := httprouter.New()
+	router.NotFound = http.HandlerFunc(notFound)
+	router.MethodNotAllowed = http.HandlerFunc(notAllowed)
+	return router
+}
+
+func httpError(w http.ResponseWriter, code int, e string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	w.Write([]byte(e))
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	httpError(w, http.StatusNotFound, `{"title":"Resource not found"}`)
+}
+
+func notAllowed(w http.ResponseWriter, r *http.Request) {
+	httpError(w, http.StatusMethodNotAllowed, `{"title":"Method not allowed"}`)
+}
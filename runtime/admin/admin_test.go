@@ -0,0 +1,268 @@
+package admin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/manifest/openapi"
+	"github.com/foldsh/fold/runtime/admin"
+)
+
+func TestHealthz(t *testing.T) {
+	healthy := false
+	a := admin.NewAdmin(logging.NewTestLogger(), admin.HealthCheck(func() bool { return healthy }))
+
+	status, body := get(t, a, "/_foldadmin/healthz", "")
+	if status != 503 {
+		t.Errorf("Expected 503 response from healthz but found %d", status)
+	}
+	testutils.Diff(t, `{"status":"DOWN"}`, body, "/_foldadmin/healthz body did not match expectation")
+
+	healthy = true
+	status, body = get(t, a, "/_foldadmin/healthz", "")
+	if status != 200 {
+		t.Errorf("Expected 200 response from healthz but found %d", status)
+	}
+	testutils.Diff(t, `{"status":"OK"}`, body, "/_foldadmin/healthz body did not match expectation")
+}
+
+func TestHealthzReportsErrors(t *testing.T) {
+	a := admin.NewAdmin(logging.NewTestLogger())
+	a.SetError(manifest.InvalidManifest{Errors: []manifest.RouteError{
+		{Route: "/foo", Method: "GET", Reason: "the route is registered more than once"},
+	}})
+
+	_, body := get(t, a, "/_foldadmin/healthz", "")
+	testutils.Diff(
+		t,
+		`{"status":"OK","error":"invalid manifest: invalid route GET /foo: the route is `+
+			`registered more than once","routes":[{"route":"/foo","method":"GET","reason":`+
+			`"the route is registered more than once"}]}`,
+		body,
+		"/_foldadmin/healthz body did not match expectation",
+	)
+
+	a.SetError(nil)
+	_, body = get(t, a, "/_foldadmin/healthz", "")
+	testutils.Diff(t, `{"status":"OK"}`, body, "/_foldadmin/healthz body did not match expectation")
+}
+
+func TestHealthzReportsFailedProbes(t *testing.T) {
+	probe := admin.ProbeStatus{Failures: 1, Error: "deadline exceeded"}
+	a := admin.NewAdmin(
+		logging.NewTestLogger(),
+		admin.Probe(func() admin.ProbeStatus { return probe }),
+	)
+
+	status, body := get(t, a, "/_foldadmin/healthz", "")
+	if status != 200 {
+		t.Errorf("Expected 200 response from healthz but found %d", status)
+	}
+	testutils.Diff(
+		t,
+		`{"status":"OK","probe":{"failures":1,"error":"deadline exceeded"}}`,
+		body,
+		"/_foldadmin/healthz body did not match expectation",
+	)
+
+	probe = admin.ProbeStatus{Failures: 3, Error: "deadline exceeded", Unhealthy: true}
+	status, body = get(t, a, "/_foldadmin/healthz", "")
+	if status != 503 {
+		t.Errorf("Expected 503 response from healthz but found %d", status)
+	}
+	testutils.Diff(
+		t,
+		`{"status":"UNHEALTHY","probe":{"failures":3,"error":"deadline exceeded"}}`,
+		body,
+		"/_foldadmin/healthz body did not match expectation",
+	)
+}
+
+func TestReadyz(t *testing.T) {
+	var (
+		up    = false
+		probe = admin.ProbeStatus{}
+	)
+	a := admin.NewAdmin(
+		logging.NewTestLogger(),
+		admin.HealthCheck(func() bool { return up }),
+		admin.Probe(func() admin.ProbeStatus { return probe }),
+	)
+
+	expectReadyz := func(expectedStatus int, expectedBody string) {
+		t.Helper()
+		status, body := get(t, a.Public(), "/_foldadmin/readyz", "")
+		if status != expectedStatus {
+			t.Errorf("Expected %d response from readyz but found %d", expectedStatus, status)
+		}
+		testutils.Diff(t, expectedBody, body, "/_foldadmin/readyz body did not match expectation")
+	}
+
+	expectReadyz(503, `{"status":"NOT_READY","reason":"the service is down"}`)
+
+	up = true
+	expectReadyz(503, `{"status":"NOT_READY","reason":"the service has not been routed yet"}`)
+
+	a.SetManifest(&manifest.Manifest{Name: "TEST"})
+	expectReadyz(200, `{"status":"READY"}`)
+
+	probe = admin.ProbeStatus{Failures: 1, Error: "deadline exceeded"}
+	expectReadyz(
+		503,
+		`{"status":"NOT_READY","reason":"the last health check failed: deadline exceeded"}`,
+	)
+}
+
+func TestManifest(t *testing.T) {
+	a := admin.NewAdmin(logging.NewTestLogger())
+
+	status, _ := get(t, a, "/_foldadmin/manifest", "")
+	if status != 503 {
+		t.Errorf("Expected 503 response from manifest before it is set but found %d", status)
+	}
+
+	m := &manifest.Manifest{Name: "TEST"}
+	a.SetManifest(m)
+	expectation := &bytes.Buffer{}
+	manifest.WriteJSON(expectation, m)
+	status, body := get(t, a, "/_foldadmin/manifest", "")
+	if status != 200 {
+		t.Errorf("Expected 200 response from manifest but found %d", status)
+	}
+	testutils.Diff(
+		t,
+		expectation.String(),
+		body,
+		"/_foldadmin/manifest did not return the expected manifest",
+	)
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	a := admin.NewAdmin(logging.NewTestLogger(), admin.WithToken("secret"))
+	a.SetManifest(&manifest.Manifest{Name: "TEST"})
+
+	cases := []struct {
+		path           string
+		token          string
+		expectedStatus int
+	}{
+		{"/_foldadmin/manifest", "", 401},
+		{"/_foldadmin/manifest", "wrong", 403},
+		{"/_foldadmin/manifest", "secret", 200},
+		// The health checks never require a token.
+		{"/_foldadmin/healthz", "", 200},
+	}
+	for _, tc := range cases {
+		status, _ := get(t, a, tc.path, tc.token)
+		if status != tc.expectedStatus {
+			t.Errorf(
+				"%s with token '%s': expected %d but found %d",
+				tc.path,
+				tc.token,
+				tc.expectedStatus,
+				status,
+			)
+		}
+	}
+}
+
+func TestPublicOnlyServesHealthChecks(t *testing.T) {
+	a := admin.NewAdmin(logging.NewTestLogger())
+	a.SetManifest(&manifest.Manifest{Name: "TEST"})
+
+	if status, _ := get(t, a.Public(), "/_foldadmin/healthz", ""); status != 200 {
+		t.Errorf("Expected 200 response from healthz but found %d", status)
+	}
+	if status, _ := get(t, a.Public(), "/_foldadmin/manifest", ""); status != 404 {
+		t.Errorf("Expected 404 response from manifest but found %d", status)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	a := admin.NewAdmin(logging.NewTestLogger(), admin.WithToken("secret"))
+
+	if status, _ := get(t, a, "/_foldadmin/debug/pprof/", ""); status != 401 {
+		t.Errorf("Expected the profiles to require the token but found %d", status)
+	}
+	status, body := get(t, a, "/_foldadmin/debug/pprof/", "secret")
+	if status != 200 || !strings.Contains(body, "goroutine") {
+		t.Errorf("Expected the profile index but found %d: %s", status, body)
+	}
+	status, body = get(t, a, "/_foldadmin/debug/pprof/goroutine?debug=1", "secret")
+	if status != 200 || !strings.Contains(body, "goroutine profile") {
+		t.Errorf("Expected the goroutine profile but found %d: %s", status, body)
+	}
+	// With a token they are served alongside the service too.
+	if status, _ := get(t, a.Shared(), "/_foldadmin/debug/pprof/", "secret"); status != 200 {
+		t.Errorf("Expected the profiles to be shared with a token but found %d", status)
+	}
+}
+
+func TestProfilesWithoutAToken(t *testing.T) {
+	a := admin.NewAdmin(logging.NewTestLogger())
+
+	// They are only served by a dedicated listener.
+	if status, _ := get(t, a, "/_foldadmin/debug/pprof/", ""); status != 200 {
+		t.Errorf("Expected the profiles on the admin listener but found %d", status)
+	}
+	if status, _ := get(t, a.Shared(), "/_foldadmin/debug/pprof/", ""); status != 404 {
+		t.Errorf("Expected the profiles not to be shared but found %d", status)
+	}
+	if status, _ := get(t, a.Shared(), "/_foldadmin/manifest", ""); status != 503 {
+		t.Errorf("Expected the other routes to be shared but found %d", status)
+	}
+}
+
+func get(t *testing.T, handler http.Handler, path, token string) (int, string) {
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	req, err := http.NewRequest("GET", server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return res.StatusCode, string(body)
+}
+
+func TestOpenAPI(t *testing.T) {
+	a := admin.NewAdmin(logging.NewTestLogger())
+
+	if status, _ := get(t, a, "/_foldadmin/openapi.json", ""); status != 503 {
+		t.Errorf("Expected 503 response from openapi.json before it is set but found %d", status)
+	}
+
+	a.SetManifest(&manifest.Manifest{
+		Name:   "TEST",
+		Routes: []*manifest.Route{{HttpMethod: manifest.FoldHTTPMethod_GET, Route: "/items/:id"}},
+	})
+	status, body := get(t, a, "/_foldadmin/openapi.json", "")
+	if status != 200 {
+		t.Errorf("Expected 200 response from openapi.json but found %d", status)
+	}
+	var doc openapi.Document
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if _, ok := doc.Paths["/items/{id}"]; !ok {
+		t.Errorf("Expected the document to describe /items/{id} but found %v", doc.Paths)
+	}
+}
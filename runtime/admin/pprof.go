@@ -0,0 +1,28 @@
+package admin
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// profile serves the runtime's own profiles under /debug/pprof, in the same way as
+// net/http/pprof does for the default mux. pprof.Index only recognises profile names under
+// /debug/pprof/ so they are dispatched here instead.
+func profile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, Prefix+"/debug/pprof/")
+	switch name {
+	case "":
+		pprof.Index(w, r)
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		pprof.Handler(name).ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,290 @@
+// Package auth verifies the bearer tokens sent to routes which require authentication. Tokens
+// are JWTs signed with one of the keys in a JWKS, the verified claims are forwarded to the
+// service along with the request.
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+var (
+	MissingToken = errors.New("no bearer token in the request")
+	InvalidToken = errors.New("invalid token")
+)
+
+// InsufficientScope is returned when a valid token does not grant every scope the route
+// requires.
+type InsufficientScope struct {
+	Missing []string
+}
+
+func (is InsufficientScope) Error() string {
+	return fmt.Sprintf("token is missing the scopes: %s", strings.Join(is.Missing, " "))
+}
+
+// KeySet provides the keys used to verify token signatures.
+type KeySet interface {
+	Key(kid string) (PublicKey, error)
+}
+
+// Claims are the verified claims from a token.
+type Claims map[string]interface{}
+
+func NewAuthenticator(keys KeySet, options ...Option) *Authenticator {
+	a := &Authenticator{keys: keys, now: time.Now}
+	for _, option := range options {
+		option(a)
+	}
+	return a
+}
+
+type Option func(*Authenticator)
+
+// Issuer requires the iss claim of every token to match.
+func Issuer(issuer string) Option {
+	return func(a *Authenticator) {
+		a.issuer = issuer
+	}
+}
+
+// Audience requires the aud claim of every token to contain the audience.
+func Audience(audience string) Option {
+	return func(a *Authenticator) {
+		a.audience = audience
+	}
+}
+
+// Leeway allows for clock skew when checking the exp, nbf and iat claims.
+func Leeway(leeway time.Duration) Option {
+	return func(a *Authenticator) {
+		a.leeway = leeway
+	}
+}
+
+// AllowMissingExpiry accepts tokens without an exp claim, which are otherwise rejected as they
+// never expire.
+func AllowMissingExpiry() Option {
+	return func(a *Authenticator) {
+		a.allowMissingExpiry = true
+	}
+}
+
+type Authenticator struct {
+	keys               KeySet
+	issuer             string
+	audience           string
+	leeway             time.Duration
+	allowMissingExpiry bool
+	now                func() time.Time
+}
+
+// Authenticate verifies the bearer token in the request and checks that it grants the scopes
+// required by the policy.
+func (a *Authenticator) Authenticate(r *http.Request, policy *manifest.AuthPolicy) (Claims, error) {
+	header := r.Header.Get("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return nil, MissingToken
+	}
+	claims, err := a.Verify(strings.TrimSpace(header[7:]))
+	if err != nil {
+		return nil, err
+	}
+	if missing := claims.missingScopes(policy.Scopes); len(missing) > 0 {
+		return nil, InsufficientScope{Missing: missing}
+	}
+	return claims, nil
+}
+
+// Verify checks the signature of the token and validates its registered claims.
+func (a *Authenticator) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", InvalidToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", InvalidToken)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", InvalidToken)
+	}
+	key, err := a.keys.Key(header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidToken, err)
+	}
+	if key.Alg != "" && key.Alg != header.Alg {
+		return nil, fmt.Errorf("%w: the key can't be used with %s", InvalidToken, header.Alg)
+	}
+	if err := verifySignature(header.Alg, key.Key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidToken, err)
+	}
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: malformed claims", InvalidToken)
+	}
+	if err := a.validate(claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", InvalidToken, err)
+	}
+	return claims, nil
+}
+
+func (a *Authenticator) validate(claims Claims) error {
+	now := a.now()
+	exp, ok := claims.time("exp")
+	if !ok && !a.allowMissingExpiry {
+		return errors.New("token has no expiry")
+	}
+	if ok && now.After(exp.Add(a.leeway)) {
+		return errors.New("token has expired")
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(a.leeway).Before(nbf) {
+		return errors.New("token is not valid yet")
+	}
+	if iat, ok := claims.time("iat"); ok && now.Add(a.leeway).Before(iat) {
+		return errors.New("token was issued in the future")
+	}
+	if a.issuer != "" && claims["iss"] != a.issuer {
+		return errors.New("unexpected issuer")
+	}
+	if a.audience != "" && !claims.hasAudience(a.audience) {
+		return errors.New("unexpected audience")
+	}
+	return nil
+}
+
+func (c Claims) time(name string) (time.Time, bool) {
+	value, ok := c[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(value), 0), true
+}
+
+func (c Claims) hasAudience(audience string) bool {
+	switch aud := c["aud"].(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Scopes are read from either the space separated scope claim or the scp claim, which some
+// providers use instead and may be a list.
+func (c Claims) scopes() map[string]struct{} {
+	scopes := make(map[string]struct{})
+	for _, name := range []string{"scope", "scp"} {
+		switch value := c[name].(type) {
+		case string:
+			for _, scope := range strings.Fields(value) {
+				scopes[scope] = struct{}{}
+			}
+		case []interface{}:
+			for _, scope := range value {
+				if s, ok := scope.(string); ok {
+					scopes[s] = struct{}{}
+				}
+			}
+		}
+	}
+	return scopes
+}
+
+func (c Claims) missingScopes(required []string) []string {
+	if len(required) == 0 {
+		return nil
+	}
+	granted := c.scopes()
+	var missing []string
+	for _, scope := range required {
+		if _, ok := granted[scope]; !ok {
+			missing = append(missing, scope)
+		}
+	}
+	return missing
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(bs, v)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	var h hash.Hash
+	var hashFunc crypto.Hash
+	// ES algorithms also fix the curve, e.g. ES256 must use P-256.
+	var curve elliptic.Curve
+	switch alg[2:] {
+	case "256":
+		h, hashFunc, curve = sha256.New(), crypto.SHA256, elliptic.P256()
+	case "384":
+		h, hashFunc, curve = sha512.New384(), crypto.SHA384, elliptic.P384()
+	case "512":
+		h, hashFunc, curve = sha512.New(), crypto.SHA512, elliptic.P521()
+	}
+	if h == nil {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch alg[:2] {
+	case "RS":
+		if k, ok := key.(*rsa.PublicKey); ok {
+			return rsa.VerifyPKCS1v15(k, hashFunc, digest, signature)
+		}
+	case "PS":
+		if k, ok := key.(*rsa.PublicKey); ok {
+			return rsa.VerifyPSS(k, hashFunc, digest, signature, nil)
+		}
+	case "ES":
+		if k, ok := key.(*ecdsa.PublicKey); ok {
+			if k.Curve != curve {
+				return fmt.Errorf("%s requires the %s curve", alg, curve.Params().Name)
+			}
+			size := (k.Curve.Params().BitSize + 7) / 8
+			if len(signature) != 2*size {
+				return errors.New("invalid signature")
+			}
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+			if !ecdsa.Verify(k, digest, r, s) {
+				return errors.New("invalid signature")
+			}
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+	return fmt.Errorf("key can not be used with %s", alg)
+}
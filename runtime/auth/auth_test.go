@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+func TestVerify(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	jwks := writeJWKS(t, map[string]crypto.PublicKey{
+		"rsa":  &rsaKey.PublicKey,
+		"ec":   &ecKey.PublicKey,
+		"p384": &p384Key.PublicKey,
+		"pss":  PublicKey{Key: &rsaKey.PublicKey, Alg: "PS256"},
+	})
+	authenticator := NewAuthenticator(
+		NewJWKS(jwks, time.Hour),
+		Issuer("https://auth.fold.sh"),
+		Audience("fold"),
+	)
+	now := time.Now().Unix()
+	valid := map[string]interface{}{
+		"iss": "https://auth.fold.sh",
+		"aud": []string{"fold", "other"},
+		"sub": "user",
+		"exp": now + 60,
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := map[string]interface{}{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+	cases := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"RS256 tokens are verified", sign(t, "RS256", "rsa", rsaKey, valid), true},
+		{"ES256 tokens are verified", sign(t, "ES256", "ec", ecKey, valid), true},
+		{"Unknown keys are rejected", sign(t, "RS256", "foo", rsaKey, valid), false},
+		{"Mismatched keys are rejected", sign(t, "RS256", "ec", rsaKey, valid), false},
+		{"Curves must match the algorithm", sign(t, "ES256", "p384", p384Key, valid), false},
+		{"Algorithms must match the key", sign(t, "RS256", "pss", rsaKey, valid), false},
+		{"Unsigned tokens are rejected", sign(t, "none", "rsa", nil, valid), false},
+		{"Expired tokens are rejected", sign(t, "RS256", "rsa", rsaKey, with("exp", now-60)), false},
+		{"Tokens must expire", sign(t, "RS256", "rsa", rsaKey, with("exp", nil)), false},
+		{"Future tokens are rejected", sign(t, "RS256", "rsa", rsaKey, with("nbf", now+60)), false},
+		{"The issuer is checked", sign(t, "RS256", "rsa", rsaKey, with("iss", "foo")), false},
+		{"The audience is checked", sign(t, "RS256", "rsa", rsaKey, with("aud", "foo")), false},
+		{"Malformed tokens are rejected", "foo.bar", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims, err := authenticator.Verify(tc.token)
+			if tc.valid {
+				if err != nil {
+					t.Fatalf("Expected the token to be valid but got %v", err)
+				}
+				if claims["sub"] != "user" {
+					t.Errorf("Expected the sub claim to be user but found %v", claims["sub"])
+				}
+			} else if !errors.Is(err, InvalidToken) {
+				t.Errorf("Expected an InvalidToken error but got %v", err)
+			}
+		})
+	}
+
+	t.Run("Tokens without an expiry can be allowed", func(t *testing.T) {
+		authenticator := NewAuthenticator(NewJWKS(jwks, time.Hour), AllowMissingExpiry())
+		if _, err := authenticator.Verify(sign(t, "RS256", "rsa", rsaKey, with("exp", nil))); err != nil {
+			t.Errorf("Expected the token to be valid but got %v", err)
+		}
+	})
+}
+
+func TestAuthenticate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	authenticator := NewAuthenticator(
+		NewJWKS(writeJWKS(t, map[string]crypto.PublicKey{"key": &key.PublicKey}), time.Hour),
+	)
+	policy := &manifest.AuthPolicy{Scopes: []string{"read", "write"}}
+	request := func(claims map[string]interface{}) *http.Request {
+		r := httptest.NewRequest("GET", "/", nil)
+		if claims != nil {
+			r.Header.Set("Authorization", "Bearer "+sign(t, "RS256", "key", key, claims))
+		}
+		return r
+	}
+
+	exp := time.Now().Add(time.Minute).Unix()
+	if _, err := authenticator.Authenticate(request(nil), policy); !errors.Is(err, MissingToken) {
+		t.Errorf("Expected a MissingToken error but got %v", err)
+	}
+	_, err = authenticator.Authenticate(request(map[string]interface{}{"scope": "read", "exp": exp}), policy)
+	var insufficientScope InsufficientScope
+	if !errors.As(err, &insufficientScope) {
+		t.Fatalf("Expected an InsufficientScope error but got %v", err)
+	}
+	if len(insufficientScope.Missing) != 1 || insufficientScope.Missing[0] != "write" {
+		t.Errorf("Expected the write scope to be missing but found %v", insufficientScope.Missing)
+	}
+	claims := map[string]interface{}{"scp": []string{"read", "write"}, "exp": exp}
+	if _, err := authenticator.Authenticate(request(claims), policy); err != nil {
+		t.Errorf("Expected the request to be authenticated but got %v", err)
+	}
+}
+
+func TestJWKSFromURL(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(encodeJWKS(t, map[string]crypto.PublicKey{"key": &key.PublicKey}))
+	}))
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL, time.Hour)
+	now := time.Now()
+	jwks.now = func() time.Time { return now }
+	if _, err := jwks.Key("key"); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if _, err := jwks.Key("key"); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("Expected the JWKS to be cached but it was fetched %d times", fetches)
+	}
+	// Unknown keys cause the set to be fetched again, but not too often.
+	if _, err := jwks.Key("other"); !errors.Is(err, UnknownKey) {
+		t.Errorf("Expected an UnknownKey error but got %v", err)
+	}
+	if fetches != 1 {
+		t.Errorf("Expected the JWKS not to be fetched again yet but it was")
+	}
+	now = now.Add(2 * minRefetchInterval)
+	jwks.Key("other")
+	if fetches != 2 {
+		t.Errorf("Expected the JWKS to be fetched again for an unknown key")
+	}
+	now = now.Add(2 * time.Hour)
+	jwks.Key("key")
+	if fetches != 3 {
+		t.Errorf("Expected the JWKS to be refreshed")
+	}
+}
+
+func TestJWKSFailuresAreNotRetriedStraightAway(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.WriteHeader(503)
+	}))
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL, time.Hour)
+	now := time.Now()
+	jwks.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		if _, err := jwks.Key("key"); !errors.Is(err, FailedToFetchJWKS) {
+			t.Errorf("Expected a FailedToFetchJWKS error but got %v", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("Expected the JWKS to be fetched once but it was fetched %d times", fetches)
+	}
+	now = now.Add(2 * minRefetchInterval)
+	jwks.Key("key")
+	if fetches != 2 {
+		t.Errorf("Expected the JWKS to be fetched again once the interval had passed")
+	}
+}
+
+func TestJWKSIsFetchedOnceByConcurrentCallers(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var fetches int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		<-release
+		w.Write(encodeJWKS(t, map[string]crypto.PublicKey{"key": &key.PublicKey}))
+	}))
+	defer server.Close()
+
+	jwks := NewJWKS(server.URL, time.Hour)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := jwks.Key("key"); err != nil {
+				t.Errorf("%+v", err)
+			}
+		}()
+	}
+	// The lock must not be held while the keys are fetched.
+	for atomic.LoadInt32(&fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	jwks.current()
+	close(release)
+	wg.Wait()
+	if fetches != 1 {
+		t.Errorf("Expected the JWKS to be fetched once but it was fetched %d times", fetches)
+	}
+}
+
+func writeJWKS(t *testing.T, keys map[string]crypto.PublicKey) string {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, encodeJWKS(t, keys), 0644); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return path
+}
+
+func encodeJWKS(t *testing.T, keys map[string]crypto.PublicKey) []byte {
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	var set []map[string]string
+	for kid, key := range keys {
+		// Keys can be wrapped in a PublicKey to declare their algorithm.
+		alg := ""
+		if pk, ok := key.(PublicKey); ok {
+			key, alg = pk.Key, pk.Alg
+		}
+		var jwk map[string]string
+		switch k := key.(type) {
+		case *rsa.PublicKey:
+			jwk = map[string]string{
+				"kid": kid, "kty": "RSA", "n": b64(k.N), "e": b64(big.NewInt(int64(k.E))),
+			}
+		case *ecdsa.PublicKey:
+			jwk = map[string]string{
+				"kid": kid, "kty": "EC", "crv": k.Curve.Params().Name, "x": b64(k.X), "y": b64(k.Y),
+			}
+		}
+		if alg != "" {
+			jwk["alg"] = alg
+		}
+		set = append(set, jwk)
+	}
+	bs, err := json.Marshal(map[string]interface{}{"keys": set})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return bs
+}
+
+func sign(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		bs, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(bs)
+	}
+	signed := fmt.Sprintf(
+		"%s.%s",
+		encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}),
+		encode(claims),
+	)
+	if key == nil {
+		return signed + "."
+	}
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		signature = sig
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
@@ -0,0 +1,267 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	FailedToFetchJWKS = errors.New("failed to fetch the JWKS")
+	UnknownKey        = errors.New("no key in the JWKS matches the token")
+)
+
+// minRefetchInterval limits how often a token with an unknown key id can cause the key set to be
+// fetched again. Without it, every such token would result in a request to the JWKS endpoint.
+const minRefetchInterval = time.Minute
+
+// NewJWKS creates a key set which is loaded from the given source. The source is either a URL or
+// the path to a file. The keys are cached and loaded again once the refresh interval has passed,
+// or sooner if a token refers to a key which isn't in the cache.
+func NewJWKS(source string, refresh time.Duration) *JWKS {
+	return &JWKS{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+}
+
+// PublicKey is a key from a JWKS. When the set declares the algorithm the key is for, tokens
+// signed with any other algorithm are rejected.
+type PublicKey struct {
+	Key crypto.PublicKey
+	Alg string
+}
+
+type JWKS struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	// The mutex isn't held while the keys are fetched. The map is replaced rather than modified,
+	// so it can be read once it has been taken from the struct.
+	mutex   sync.Mutex
+	keys    map[string]PublicKey
+	fetched time.Time
+	// The error from the last load if it failed.
+	failed error
+	// The load in progress, if any, which concurrent callers wait for rather than fetching the
+	// keys again.
+	loading *load
+}
+
+type load struct {
+	done chan struct{}
+	err  error
+}
+
+// Key returns the public key with the given id. If the id is empty and the set holds a single
+// key then that key is returned.
+func (j *JWKS) Key(kid string) (PublicKey, error) {
+	now := j.now()
+	keys, fetched, failed := j.current()
+	if keys == nil && failed != nil && now.Sub(fetched) <= minRefetchInterval {
+		// The keys have never been loaded but trying again on every request would just put more
+		// load on the source while it is failing.
+		return PublicKey{}, failed
+	}
+	if keys == nil || now.Sub(fetched) > j.refresh {
+		err := j.load(now)
+		// If the keys can't be loaded again the previous ones are still used.
+		if keys, fetched, _ = j.current(); err != nil && keys == nil {
+			return PublicKey{}, err
+		}
+	}
+	if key, ok := lookup(keys, kid); ok {
+		return key, nil
+	}
+	// The keys may have been rotated since we last loaded them.
+	if now.Sub(fetched) > minRefetchInterval {
+		if err := j.load(now); err != nil {
+			return PublicKey{}, err
+		}
+		keys, _, _ = j.current()
+		if key, ok := lookup(keys, kid); ok {
+			return key, nil
+		}
+	}
+	return PublicKey{}, UnknownKey
+}
+
+func (j *JWKS) current() (map[string]PublicKey, time.Time, error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.keys, j.fetched, j.failed
+}
+
+func lookup(keys map[string]PublicKey, kid string) (PublicKey, bool) {
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, true
+		}
+	}
+	key, ok := keys[kid]
+	return key, ok
+}
+
+// load fetches the keys, or waits for the fetch which is already in progress.
+func (j *JWKS) load(now time.Time) error {
+	j.mutex.Lock()
+	if l := j.loading; l != nil {
+		j.mutex.Unlock()
+		<-l.done
+		return l.err
+	}
+	l := &load{done: make(chan struct{})}
+	j.loading = l
+	// Whether or not this succeeds we don't want to try again straight away.
+	j.fetched = now
+	j.mutex.Unlock()
+
+	keys, err := j.fetch()
+	j.mutex.Lock()
+	if err == nil {
+		j.keys = keys
+	}
+	j.failed = err
+	j.loading = nil
+	j.mutex.Unlock()
+	l.err = err
+	close(l.done)
+	return err
+}
+
+func (j *JWKS) fetch() (map[string]PublicKey, error) {
+	r, err := j.open()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", FailedToFetchJWKS, err)
+	}
+	defer r.Close()
+	keys, err := parseJWKS(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", FailedToFetchJWKS, err)
+	}
+	return keys, nil
+}
+
+func (j *JWKS) open() (io.ReadCloser, error) {
+	if !strings.HasPrefix(j.source, "http://") && !strings.HasPrefix(j.source, "https://") {
+		return os.Open(j.source)
+	}
+	res, err := j.client.Get(j.source)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func parseJWKS(r io.Reader) (map[string]PublicKey, error) {
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(bs, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]PublicKey)
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %v", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = PublicKey{Key: key, Alg: k.Alg}
+		}
+	}
+	return keys, nil
+}
+
+// publicKey returns nil for key types we don't support so that they are skipped rather than
+// preventing the whole set from being used.
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(bs), nil
+}
@@ -0,0 +1,203 @@
+// Package cache implements HTTP caching in the runtime. It computes ETags to answer conditional
+// requests, and holds an in memory LRU cache for the responses of routes which opt in to it
+// through their manifest.
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+// Entry is a cached response.
+type Entry struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+	Created time.Time
+}
+
+// NewLRU creates a cache holding up to capacity responses. Once it is full the least recently
+// used responses are evicted.
+func NewLRU(capacity int) *LRU {
+	return &LRU{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+type LRU struct {
+	capacity int
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type item struct {
+	key     string
+	route   string
+	entry   *Entry
+	expires time.Time
+}
+
+// Key identifies the cached response for a request to a route. The values of the headers the
+// policy varies by are part of the key.
+func Key(route *manifest.Route, r *http.Request) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s?%s", r.Method, r.URL.Path, r.URL.RawQuery)
+	varyBy := append([]string{}, route.Cache.VaryBy...)
+	sort.Strings(varyBy)
+	for _, header := range varyBy {
+		fmt.Fprintf(&b, "|%s=%s", http.CanonicalHeaderKey(header), r.Header.Values(header))
+	}
+	return b.String()
+}
+
+// Get returns the cached response for the key, if there is one which hasn't expired.
+func (c *LRU) Get(key string) (*Entry, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	it := el.Value.(*item)
+	if c.now().After(it.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return it.entry, true
+}
+
+// Set caches the response for the route under the key for the given time.
+func (c *LRU) Set(route, key string, entry *Entry, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	it := &item{key: key, route: route, entry: entry, expires: c.now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = it
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(it)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+// Purge removes every cached response.
+func (c *LRU) Purge() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	n := c.order.Len()
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+	return n
+}
+
+// PurgeRoute removes the cached responses for a single route, identified by its route
+// specification, e.g. /items/:id. It returns the number of responses removed.
+func (c *LRU) PurgeRoute(route string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	n := 0
+	for el := c.order.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*item).route == route {
+			c.remove(el)
+			n++
+		}
+		el = next
+	}
+	return n
+}
+
+// Len returns the number of cached responses, including any which have expired but not yet been
+// evicted.
+func (c *LRU) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*item).key)
+}
+
+// Cacheable returns true if the response to the request may be stored in the cache.
+func Cacheable(route *manifest.Route, r *http.Request, status int, headers http.Header) bool {
+	if route.Cache == nil || route.Cache.Ttl <= 0 || r.Method != "GET" || status != http.StatusOK {
+		return false
+	}
+	if headers.Get("Set-Cookie") != "" {
+		return false
+	}
+	cacheControl := strings.ToLower(headers.Get("Cache-Control"))
+	if strings.Contains(cacheControl, "no-store") || strings.Contains(cacheControl, "private") {
+		return false
+	}
+	// Responses to authenticated requests are personal unless the cache varies by the token.
+	if r.Header.Get("Authorization") != "" && !varies(route, "Authorization") {
+		return false
+	}
+	return true
+}
+
+// Lookup returns true if the cache should be checked for the request.
+func Lookup(route *manifest.Route, r *http.Request) bool {
+	if route.Cache == nil || route.Cache.Ttl <= 0 || r.Method != "GET" {
+		return false
+	}
+	if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+		return false
+	}
+	return r.Header.Get("Authorization") == "" || varies(route, "Authorization")
+}
+
+func varies(route *manifest.Route, header string) bool {
+	for _, h := range route.Cache.VaryBy {
+		if strings.EqualFold(h, header) {
+			return true
+		}
+	}
+	return false
+}
+
+// ETag computes a strong ETag for a response body.
+func ETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+}
+
+// NotModified returns true if the If-None-Match header of the request matches the ETag. As
+// required for GET and HEAD requests, the weak comparison is used.
+func NotModified(r *http.Request, etag string) bool {
+	if etag == "" || (r.Method != "GET" && r.Method != "HEAD") {
+		return false
+	}
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
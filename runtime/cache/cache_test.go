@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+func TestLRU(t *testing.T) {
+	c := NewLRU(2)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	c.Set("/a", "a", &Entry{Body: []byte("a")}, time.Minute)
+	c.Set("/b", "b", &Entry{Body: []byte("b")}, time.Minute)
+	// Reading a makes b the least recently used.
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Expected a to be cached")
+	}
+	c.Set("/c", "c", &Entry{Body: []byte("c")}, time.Minute)
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("Expected b to have been evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Errorf("Expected a to still be cached")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("Expected a to have expired")
+	}
+	if c.Len() != 1 {
+		t.Errorf("Expected the expired entry to be removed but found %d entries", c.Len())
+	}
+}
+
+func TestPurge(t *testing.T) {
+	c := NewLRU(10)
+	c.Set("/items/:id", "1", &Entry{}, time.Minute)
+	c.Set("/items/:id", "2", &Entry{}, time.Minute)
+	c.Set("/other", "3", &Entry{}, time.Minute)
+	if n := c.PurgeRoute("/items/:id"); n != 2 {
+		t.Errorf("Expected 2 entries to be purged but found %d", n)
+	}
+	if _, ok := c.Get("3"); !ok {
+		t.Errorf("Expected other routes to remain cached")
+	}
+	if n := c.Purge(); n != 1 {
+		t.Errorf("Expected 1 entry to be purged but found %d", n)
+	}
+}
+
+func TestKey(t *testing.T) {
+	route := &manifest.Route{Route: "/items", Cache: &manifest.CachePolicy{
+		Ttl:    60,
+		VaryBy: []string{"Accept-Language"},
+	}}
+	mkreq := func(path, language string) *http.Request {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Accept-Language", language)
+		r.Header.Set("User-Agent", path+language)
+		return r
+	}
+	if Key(route, mkreq("/items?a=b", "en")) != Key(route, mkreq("/items?a=b", "en")) {
+		t.Errorf("Expected headers we don't vary by to be ignored")
+	}
+	if Key(route, mkreq("/items?a=b", "en")) == Key(route, mkreq("/items?a=c", "en")) {
+		t.Errorf("Expected the query to be part of the key")
+	}
+	if Key(route, mkreq("/items", "en")) == Key(route, mkreq("/items", "fr")) {
+		t.Errorf("Expected the vary headers to be part of the key")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	route := &manifest.Route{Route: "/items", Cache: &manifest.CachePolicy{Ttl: 60}}
+	get := httptest.NewRequest("GET", "/items", nil)
+	post := httptest.NewRequest("POST", "/items", nil)
+	authenticated := httptest.NewRequest("GET", "/items", nil)
+	authenticated.Header.Set("Authorization", "Bearer foo")
+	cases := []struct {
+		name     string
+		r        *http.Request
+		status   int
+		headers  http.Header
+		expected bool
+	}{
+		{"Successful responses are cached", get, 200, http.Header{}, true},
+		{"Errors are not cached", get, 500, http.Header{}, false},
+		{"POST requests are not cached", post, 200, http.Header{}, false},
+		{"Cookies are not cached", get, 200, http.Header{"Set-Cookie": {"a=b"}}, false},
+		{"no-store is respected", get, 200, http.Header{"Cache-Control": {"no-store"}}, false},
+		{"Authenticated requests are not cached", authenticated, 200, http.Header{}, false},
+	}
+	for _, tc := range cases {
+		if actual := Cacheable(route, tc.r, tc.status, tc.headers); actual != tc.expected {
+			t.Errorf("%s: expected %t but found %t", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := ETag([]byte("foo"))
+	cases := []struct {
+		ifNoneMatch string
+		expected    bool
+	}{
+		{"", false},
+		{etag, true},
+		{"W/" + etag, true},
+		{`"other", ` + etag, true},
+		{`"other"`, false},
+		{"*", true},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("If-None-Match", tc.ifNoneMatch)
+		if actual := NotModified(r, etag); actual != tc.expected {
+			t.Errorf("Expected %q to be %t but found %t", tc.ifNoneMatch, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package compression negotiates and applies the content encoding of responses sent by the
+// runtime, according to the policy declared in the service manifest.
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+// DefaultMinSize is the smallest response which is compressed when the policy doesn't set one.
+// Below this the overhead of compression generally outweighs the saving.
+const DefaultMinSize = 1024
+
+// These content types are already compressed, compressing them again wastes CPU for no gain.
+var defaultSkip = []string{
+	"image/*",
+	"audio/*",
+	"video/*",
+	"font/woff",
+	"font/woff2",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-bzip2",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/zstd",
+	"application/pdf",
+	"application/octet-stream",
+}
+
+// The encodings we support, in order of preference when the client has no preference.
+var encodings = []string{"gzip", "deflate"}
+
+type Policy struct {
+	disabled bool
+	minSize  int
+	skip     []string
+}
+
+// NewPolicy creates a policy from the manifest. A nil policy results in the defaults.
+func NewPolicy(policy *manifest.CompressionPolicy) *Policy {
+	p := &Policy{minSize: DefaultMinSize, skip: append([]string{}, defaultSkip...)}
+	if policy == nil {
+		return p
+	}
+	p.disabled = policy.Disabled
+	if policy.MinSize > 0 {
+		p.minSize = int(policy.MinSize)
+	}
+	for _, contentType := range policy.SkipContentTypes {
+		p.skip = append(p.skip, strings.ToLower(contentType))
+	}
+	return p
+}
+
+// Compress returns the body to send in response to the request, compressing it if the client
+// accepts a supported encoding and the policy allows it. The response headers are updated to
+// match, so this must be called before they are written.
+func (p *Policy) Compress(headers http.Header, r *http.Request, status int, body []byte) []byte {
+	if p.disabled || !p.compressible(headers, status) {
+		return body
+	}
+	// From here on whether or not we compress depends only on the request, so caches must know
+	// to store a representation per encoding.
+	headers.Add("Vary", "Accept-Encoding")
+	if len(body) < p.minSize || r.Method == "HEAD" {
+		return body
+	}
+	encoding := Negotiate(r.Header.Get("Accept-Encoding"))
+	if encoding == "" {
+		return body
+	}
+	compressed, err := encode(encoding, body)
+	if err != nil || len(compressed) >= len(body) {
+		return body
+	}
+	headers.Set("Content-Encoding", encoding)
+	headers.Del("Content-Length")
+	return compressed
+}
+
+func (p *Policy) compressible(headers http.Header, status int) bool {
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	if headers.Get("Content-Encoding") != "" {
+		// The service has already encoded the response.
+		return false
+	}
+	contentType := headers.Get("Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, skip := range p.skip {
+		if strings.HasSuffix(skip, "*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(skip, "*")) {
+				return false
+			}
+		} else if mediaType == skip {
+			return false
+		}
+	}
+	return true
+}
+
+// Negotiate picks the encoding to use from an Accept-Encoding header. It returns an empty string
+// if none of the supported encodings are acceptable.
+func Negotiate(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := parseQualities(acceptEncoding)
+	best, bestQ := "", 0.0
+	for _, encoding := range encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// Accepts returns true if the encoding is acceptable according to an Accept-Encoding header,
+// whether or not the client prefers another one.
+func Accepts(acceptEncoding, encoding string) bool {
+	qualities := parseQualities(acceptEncoding)
+	q, ok := qualities[encoding]
+	if !ok {
+		q, ok = qualities["*"]
+	}
+	return ok && q > 0
+}
+
+func parseQualities(acceptEncoding string) map[string]float64 {
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qualities[coding] = q
+	}
+	return qualities
+}
+
+func encode(encoding string, body []byte) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		// Despite the name, the deflate content coding is the zlib format.
+		w = zlib.NewWriter(&buf)
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,156 @@
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		acceptEncoding string
+		expected       string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"gzip, deflate, br", "gzip"},
+		{"deflate;q=1.0, gzip;q=0.5", "deflate"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"*", "gzip"},
+		{"*;q=0", ""},
+		{"br", ""},
+	}
+	for _, tc := range cases {
+		if actual := Negotiate(tc.acceptEncoding); actual != tc.expected {
+			t.Errorf("Expected %q to negotiate %q but found %q", tc.acceptEncoding, tc.expected, actual)
+		}
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	cases := []struct {
+		acceptEncoding string
+		expected       bool
+	}{
+		{"", false},
+		{"gzip", true},
+		{"deflate;q=1.0, gzip;q=0.5", true},
+		{"gzip;q=0, deflate", false},
+		{"*", true},
+		{"br", false},
+	}
+	for _, tc := range cases {
+		if actual := Accepts(tc.acceptEncoding, "gzip"); actual != tc.expected {
+			t.Errorf("Expected %q to accept gzip: %v", tc.acceptEncoding, tc.expected)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	large := []byte(strings.Repeat(`{"foo":"bar"}`, 200))
+	cases := []struct {
+		name           string
+		policy         *manifest.CompressionPolicy
+		acceptEncoding string
+		contentType    string
+		body           []byte
+		expected       string
+		vary           bool
+	}{
+		{"Large bodies are compressed", nil, "gzip", "application/json", large, "gzip", true},
+		{"Deflate is supported", nil, "deflate", "application/json", large, "deflate", true},
+		{"Small bodies are not compressed", nil, "gzip", "application/json", []byte("{}"), "", true},
+		{"The client must accept an encoding", nil, "", "application/json", large, "", true},
+		{"Images are skipped", nil, "gzip", "image/png", large, "", false},
+		{
+			"Content types can be skipped",
+			&manifest.CompressionPolicy{SkipContentTypes: []string{"application/*"}},
+			"gzip",
+			"application/json; charset=utf-8",
+			large,
+			"",
+			false,
+		},
+		{
+			"The minimum size can be changed",
+			&manifest.CompressionPolicy{MinSize: 10},
+			"gzip",
+			"text/plain",
+			[]byte(strings.Repeat("a", 100)),
+			"gzip",
+			true,
+		},
+		{
+			"Compression can be disabled",
+			&manifest.CompressionPolicy{Disabled: true},
+			"gzip",
+			"application/json",
+			large,
+			"",
+			false,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			headers := http.Header{}
+			headers.Set("Content-Type", tc.contentType)
+			body := NewPolicy(tc.policy).Compress(headers, r, 200, tc.body)
+
+			if encoding := headers.Get("Content-Encoding"); encoding != tc.expected {
+				t.Fatalf("Expected the encoding to be %q but found %q", tc.expected, encoding)
+			}
+			if vary := headers.Get("Vary") == "Accept-Encoding"; vary != tc.vary {
+				t.Errorf("Expected Vary: Accept-Encoding to be %t but found %t", tc.vary, vary)
+			}
+			if decoded := decode(t, tc.expected, body); !bytes.Equal(decoded, tc.body) {
+				t.Errorf("Expected the decoded body to match the original")
+			}
+		})
+	}
+}
+
+func TestAlreadyEncodedResponsesAreSkipped(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	headers := http.Header{}
+	headers.Set("Content-Encoding", "br")
+	body := []byte(strings.Repeat("a", 2000))
+	if result := NewPolicy(nil).Compress(headers, r, 200, body); !bytes.Equal(result, body) {
+		t.Errorf("Expected the body to be unchanged")
+	}
+}
+
+func decode(t *testing.T, encoding string, body []byte) []byte {
+	var (
+		r   io.Reader
+		err error
+	)
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return body
+	}
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	bs, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return bs
+}
@@ -0,0 +1,287 @@
+// Package concurrency limits the number of requests the runtime sends to the service at once.
+// Requests over the limit wait in a bounded queue and are rejected when it is full, so that a
+// spike in traffic is turned away at the runtime rather than overwhelming the service.
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+var (
+	QueueFull    = errors.New("too many requests are waiting for the service")
+	QueueTimeout = errors.New("timed out waiting for the service")
+)
+
+// Settings describe the limit for the whole service or for a single route.
+type Settings struct {
+	// The maximum number of requests in flight. Zero means there is no limit.
+	MaxInFlight int
+	// The maximum number of requests waiting for one of those in flight to complete.
+	MaxQueue int
+	// How long a request can wait in the queue before it is rejected. Zero means it waits until
+	// the client gives up.
+	MaxWait time.Duration
+}
+
+type Option func(*Limiter)
+
+// Route sets a limit for a single route, e.g. Route("POST", "/reports", ...), which applies on top
+// of the limit for the whole service.
+func Route(method, route string, settings Settings) Option {
+	return func(l *Limiter) {
+		l.routes[routeName(method, route)] = newGate(settings)
+	}
+}
+
+// RetryAfter sets how long clients are told to wait before retrying a rejected request. It
+// defaults to one second.
+func RetryAfter(d time.Duration) Option {
+	return func(l *Limiter) {
+		l.retryAfter = d
+	}
+}
+
+// Adaptive lowers the limits, down to min, while requests take longer than the target latency
+// and raises them again, up to the configured maximum, while they don't.
+func Adaptive(target time.Duration, min int) Option {
+	return func(l *Limiter) {
+		l.adaptive = &adaptive{target: target, min: min}
+	}
+}
+
+// Limiter enforces the limits for the service and its routes. It is shared between routers so
+// that requests to a process that is being replaced still count.
+type Limiter struct {
+	service    *gate
+	routes     map[string]*gate
+	adaptive   *adaptive
+	retryAfter time.Duration
+}
+
+func NewLimiter(service Settings, options ...Option) *Limiter {
+	l := &Limiter{service: newGate(service), routes: map[string]*gate{}, retryAfter: time.Second}
+	for _, option := range options {
+		option(l)
+	}
+	if l.adaptive != nil {
+		l.service.adaptive = l.adaptive
+		for _, g := range l.routes {
+			g.adaptive = l.adaptive
+		}
+	}
+	return l
+}
+
+// Acquire waits until the request can be sent to the service. The returned function must be
+// called once the service has responded. If the request can't be sent then it returns QueueFull,
+// QueueTimeout or the context's error.
+func (l *Limiter) Acquire(ctx context.Context, route *manifest.Route) (func(), error) {
+	var releaseRoute func()
+	if g, ok := l.routes[routeName(route.HttpMethod.String(), route.Route)]; ok {
+		var err error
+		if releaseRoute, err = g.acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+	releaseService, err := l.service.acquire(ctx)
+	if err != nil {
+		if releaseRoute != nil {
+			releaseRoute()
+		}
+		return nil, err
+	}
+	return func() {
+		releaseService()
+		if releaseRoute != nil {
+			releaseRoute()
+		}
+	}, nil
+}
+
+// RetryAfter returns how long clients should wait before retrying a rejected request.
+func (l *Limiter) RetryAfter() time.Duration {
+	return l.retryAfter
+}
+
+// Stats describes the state of the limit for the service, or for a single route.
+type Stats struct {
+	// The route the limit applies to, or * for the whole service.
+	Route    string `json:"route"`
+	Limit    int    `json:"limit"`
+	InFlight int    `json:"inflight"`
+	Queued   int    `json:"queued"`
+	Admitted uint64 `json:"admitted"`
+	Rejected uint64 `json:"rejected"`
+}
+
+// Stats returns the state of the limit for the service followed by each of the routes.
+func (l *Limiter) Stats() []Stats {
+	stats := []Stats{l.service.stats("*")}
+	var routes []Stats
+	for name, g := range l.routes {
+		routes = append(routes, g.stats(name))
+	}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Route < routes[j].Route })
+	return append(stats, routes...)
+}
+
+// gate admits requests up to its limit and queues the rest in the order they arrived.
+type gate struct {
+	settings Settings
+	adaptive *adaptive
+
+	mutex        sync.Mutex
+	limit        int
+	inflight     int
+	queue        []chan struct{}
+	admitted     uint64
+	rejected     uint64
+	lastDecrease time.Time
+}
+
+func newGate(settings Settings) *gate {
+	return &gate{settings: settings, limit: settings.MaxInFlight}
+}
+
+func (g *gate) acquire(ctx context.Context) (func(), error) {
+	if g.settings.MaxInFlight <= 0 {
+		return func() {}, nil
+	}
+	g.mutex.Lock()
+	if g.inflight < g.limit && len(g.queue) == 0 {
+		g.inflight++
+		g.admitted++
+		g.mutex.Unlock()
+		return g.releaser(), nil
+	}
+	if len(g.queue) >= g.settings.MaxQueue {
+		g.rejected++
+		g.mutex.Unlock()
+		return nil, QueueFull
+	}
+	ready := make(chan struct{})
+	g.queue = append(g.queue, ready)
+	g.mutex.Unlock()
+
+	// Without a maximum wait the request waits for as long as the client does.
+	var timeout <-chan time.Time
+	if g.settings.MaxWait > 0 {
+		timer := time.NewTimer(g.settings.MaxWait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var err error
+	select {
+	case <-ready:
+		return g.releaser(), nil
+	case <-timeout:
+		err = QueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.rejected++
+	if !g.dequeue(ready) {
+		// The request was admitted just as it gave up, so its slot goes to the next one.
+		g.admitted--
+		g.inflight--
+		g.admit()
+	}
+	return nil, err
+}
+
+// releaser returns the function which frees up the request's slot when it completes.
+func (g *gate) releaser() func() {
+	start := time.Now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+			if g.adaptive != nil {
+				g.adapt(time.Since(start), time.Now())
+			}
+			g.inflight--
+			g.admit()
+		})
+	}
+}
+
+// admit lets queued requests through while there are free slots. The mutex must be held.
+func (g *gate) admit() {
+	for g.inflight < g.limit && len(g.queue) > 0 {
+		ready := g.queue[0]
+		g.queue = g.queue[1:]
+		g.inflight++
+		g.admitted++
+		close(ready)
+	}
+}
+
+// dequeue removes the request from the queue, returning false if it has already left it.
+func (g *gate) dequeue(ready chan struct{}) bool {
+	for i, queued := range g.queue {
+		if queued == ready {
+			g.queue = append(g.queue[:i], g.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (g *gate) stats(route string) Stats {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return Stats{
+		Route:    route,
+		Limit:    g.limit,
+		InFlight: g.inflight,
+		Queued:   len(g.queue),
+		Admitted: g.admitted,
+		Rejected: g.rejected,
+	}
+}
+
+// adaptive adjusts the limits with additive increase and multiplicative decrease, the same way
+// TCP adjusts its congestion window.
+type adaptive struct {
+	target time.Duration
+	min    int
+}
+
+// adapt updates the limit with the latency of a request which has just completed. The mutex must
+// be held.
+func (g *gate) adapt(latency time.Duration, now time.Time) {
+	if latency > g.adaptive.target {
+		// All of the requests in flight are likely to be slow, so the limit is only lowered once
+		// per target latency rather than once per request.
+		if now.Sub(g.lastDecrease) < g.adaptive.target {
+			return
+		}
+		g.lastDecrease = now
+		g.limit = g.limit * 9 / 10
+		if g.limit < g.adaptive.min {
+			g.limit = g.adaptive.min
+		}
+		if g.limit < 1 {
+			g.limit = 1
+		}
+		return
+	}
+	// There's only any point in raising the limit when it's being reached.
+	if g.inflight >= g.limit && g.limit < g.settings.MaxInFlight {
+		g.limit++
+	}
+}
+
+func routeName(method, route string) string {
+	return fmt.Sprintf("%s %s", method, route)
+}
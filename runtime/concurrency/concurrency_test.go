@@ -0,0 +1,141 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+var route = &manifest.Route{HttpMethod: manifest.FoldHTTPMethod_POST, Route: "/reports"}
+
+func TestQueueAndReject(t *testing.T) {
+	l := NewLimiter(Settings{MaxInFlight: 1, MaxQueue: 1, MaxWait: time.Second})
+
+	release, err := l.Acquire(context.Background(), route)
+	if err != nil {
+		t.Fatalf("Expected the first request to be admitted but got %v", err)
+	}
+	admitted := make(chan func())
+	go func() {
+		release, err := l.Acquire(context.Background(), route)
+		if err != nil {
+			t.Errorf("Expected the queued request to be admitted but got %v", err)
+		}
+		admitted <- release
+	}()
+	waitFor(t, func() bool { return l.Stats()[0].Queued == 1 })
+
+	if _, err := l.Acquire(context.Background(), route); !errors.Is(err, QueueFull) {
+		t.Errorf("Expected the queue to be full but got %v", err)
+	}
+
+	release()
+	(<-admitted)()
+	expectStats(t, l.Stats()[0], Stats{Route: "*", Limit: 1, Admitted: 2, Rejected: 1})
+}
+
+func TestQueueTimeout(t *testing.T) {
+	l := NewLimiter(Settings{MaxInFlight: 1, MaxQueue: 1, MaxWait: 10 * time.Millisecond})
+	release, _ := l.Acquire(context.Background(), route)
+	defer release()
+
+	if _, err := l.Acquire(context.Background(), route); !errors.Is(err, QueueTimeout) {
+		t.Errorf("Expected the request to time out but got %v", err)
+	}
+	expectStats(t, l.Stats()[0], Stats{Route: "*", Limit: 1, InFlight: 1, Admitted: 1, Rejected: 1})
+}
+
+func TestClientGivesUp(t *testing.T) {
+	l := NewLimiter(Settings{MaxInFlight: 1, MaxQueue: 1})
+	release, _ := l.Acquire(context.Background(), route)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := l.Acquire(ctx, route); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the request to be abandoned but got %v", err)
+	}
+	if queued := l.Stats()[0].Queued; queued != 0 {
+		t.Errorf("Expected the request to leave the queue but found %d queued", queued)
+	}
+}
+
+func TestRouteLimits(t *testing.T) {
+	l := NewLimiter(
+		Settings{MaxInFlight: 10},
+		Route("POST", "/reports", Settings{MaxInFlight: 1}),
+	)
+	release, err := l.Acquire(context.Background(), route)
+	if err != nil {
+		t.Fatalf("Expected the first request to be admitted but got %v", err)
+	}
+	if _, err := l.Acquire(context.Background(), route); !errors.Is(err, QueueFull) {
+		t.Errorf("Expected the route to be limited but got %v", err)
+	}
+	// Other routes are only subject to the limit for the service.
+	other := &manifest.Route{HttpMethod: manifest.FoldHTTPMethod_GET, Route: "/reports"}
+	releaseOther, err := l.Acquire(context.Background(), other)
+	if err != nil {
+		t.Errorf("Expected other routes to be admitted but got %v", err)
+	}
+	stats := l.Stats()
+	expectStats(t, stats[0], Stats{Route: "*", Limit: 10, InFlight: 2, Admitted: 2})
+	expectStats(
+		t,
+		stats[1],
+		Stats{Route: "POST /reports", Limit: 1, InFlight: 1, Admitted: 1, Rejected: 1},
+	)
+	release()
+	releaseOther()
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	l := NewLimiter(Settings{MaxInFlight: 10}, Adaptive(5*time.Millisecond, 2))
+	g := l.service
+	now := time.Now()
+
+	// Slow requests lower the limit, but only once per target latency.
+	g.adapt(10*time.Millisecond, now)
+	g.adapt(10*time.Millisecond, now.Add(time.Millisecond))
+	if g.limit != 9 {
+		t.Errorf("Expected the limit to be lowered once but found %d", g.limit)
+	}
+	for i := 1; i < 20; i++ {
+		g.adapt(10*time.Millisecond, now.Add(time.Duration(i)*10*time.Millisecond))
+	}
+	if g.limit != 2 {
+		t.Errorf("Expected the limit to stop at the minimum but found %d", g.limit)
+	}
+
+	// Fast requests raise it again, but only while it is being reached.
+	g.adapt(time.Millisecond, now)
+	if g.limit != 2 {
+		t.Errorf("Expected the limit to stay the same while idle but found %d", g.limit)
+	}
+	g.inflight = 2
+	g.adapt(time.Millisecond, now)
+	if g.limit != 3 {
+		t.Errorf("Expected the limit to be raised but found %d", g.limit)
+	}
+}
+
+func expectStats(t *testing.T, actual, expected Stats) {
+	t.Helper()
+	if actual != expected {
+		t.Errorf("Expected %+v but found %+v", expected, actual)
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Timed out waiting for condition")
+}
@@ -0,0 +1,684 @@
+// Package config loads the configuration for foldrt. Configuration is read from an optional
+// foldrt.yaml file and every value in it can be overridden by an environment variable. The name
+// of the variable is the key in the file, prefixed with FOLD_, upper cased and with '.' and '-'
+// replaced by '_'. For example `watch.dir` is overridden by FOLD_WATCH_DIR.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/foldsh/fold/logging"
+)
+
+var (
+	ConfigNotFound = errors.New("foldrt config file not found")
+	ReadConfigErr  = errors.New("failed to read the foldrt config file")
+)
+
+type InvalidValue struct {
+	Key    string
+	Value  interface{}
+	Reason string
+}
+
+func (iv InvalidValue) Error() string {
+	return fmt.Sprintf("invalid value '%v' for %s: %s", iv.Value, iv.Key, iv.Reason)
+}
+
+const (
+	// Stages
+	LOCAL = "LOCAL"
+	DEBUG = "DEBUG"
+	TEST  = "TEST"
+	PROD  = "PROD"
+
+	// Handlers
+	HTTP   = "HTTP"
+	LAMBDA = "LAMBDA"
+
+	// Crash policies
+	KILL       = "KILL"
+	KEEP_ALIVE = "KEEP_ALIVE"
+
+	// Log formats
+	JSON    = "JSON"
+	CONSOLE = "CONSOLE"
+
+	// Reload strategies
+	RESTART    = "RESTART"
+	BLUE_GREEN = "BLUE_GREEN"
+
+	// Watch modes, AUTO also applies to debuggers
+	AUTO   = "AUTO"
+	NOTIFY = "NOTIFY"
+	POLL   = "POLL"
+
+	// Debuggers
+	DLV  = "DLV"
+	NODE = "NODE"
+
+	// Ingress protocols, along with AUTO and HTTP
+	GRPC = "GRPC"
+)
+
+type Config struct {
+	// The stage the runtime is running in. This selects sensible defaults for the log and crash
+	// settings, it does not change any behaviour directly.
+	Stage string `mapstructure:"stage"`
+	// The name of the service, it is passed on to the SDK when the runtime connects to it.
+	ServiceName string `mapstructure:"service-name"`
+	// Which handler is used to receive traffic, either HTTP or LAMBDA.
+	Handler string `mapstructure:"handler"`
+	// What to do when the process crashes, either KILL or KEEP_ALIVE.
+	CrashPolicy string `mapstructure:"crash-policy"`
+	// How long to wait for the service to return its manifest.
+	ManifestTimeout time.Duration `mapstructure:"manifest-timeout"`
+	// How long to wait for in flight requests to complete when shutting down.
+	ShutdownTimeout time.Duration `mapstructure:"shutdown-timeout"`
+	// How the service is reloaded, either RESTART or BLUE_GREEN.
+	ReloadStrategy string `mapstructure:"reload-strategy"`
+	// How long a BLUE_GREEN reload waits for requests to the previous process to complete.
+	DrainTimeout time.Duration `mapstructure:"drain-timeout"`
+	// A shell command which is run before the process is restarted by hot reloading.
+	BuildCmd string `mapstructure:"build-cmd"`
+	// The files written by BuildCmd, in the same format as a .gitignore. Changes to them don't
+	// trigger a reload, otherwise every build would trigger another one.
+	BuildOutputs []string `mapstructure:"build-outputs"`
+	// The protocol the SDK serves, either GRPC, HTTP or AUTO to detect it when the service starts.
+	Ingress string `mapstructure:"ingress"`
+
+	HTTP  HTTPConfig  `mapstructure:"http"`
+	Watch WatchConfig `mapstructure:"watch"`
+	Log   LogConfig   `mapstructure:"log"`
+	Admin AdminConfig `mapstructure:"admin"`
+	Auth  AuthConfig  `mapstructure:"auth"`
+	Cache CacheConfig `mapstructure:"cache"`
+
+	HealthCheck HealthCheckConfig `mapstructure:"health-check"`
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
+	Record      RecordConfig      `mapstructure:"record"`
+	Debug       DebugConfig       `mapstructure:"debug"`
+	Adapter     AdapterConfig     `mapstructure:"adapter"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+}
+
+type HTTPConfig struct {
+	// The address the HTTP handler listens on.
+	Addr string `mapstructure:"addr"`
+	// The addresses or CIDR ranges of the proxies in front of the runtime. The X-Forwarded-For
+	// header is only used to identify clients for requests which come from them.
+	TrustedProxies []string `mapstructure:"trusted-proxies"`
+}
+
+// TrustedNetworks parses the trusted proxies, a single address is treated as a network which only
+// contains that address.
+func (hc HTTPConfig) TrustedNetworks() ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, proxy := range hc.TrustedProxies {
+		if ip := net.ParseIP(proxy); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 8 * net.IPv6len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, InvalidValue{"http.trusted-proxies", proxy, "must be an address or a CIDR range"}
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+type WatchConfig struct {
+	// A directory to watch for changes. It is watched along with Dirs, hot reloading is disabled
+	// if both are empty.
+	Dir string `mapstructure:"dir"`
+	// The directories to watch for changes.
+	Dirs []string `mapstructure:"dirs"`
+	// How long to wait for changes to settle before reloading.
+	Debounce time.Duration `mapstructure:"debounce"`
+	// How changes are detected, one of AUTO, NOTIFY or POLL. AUTO uses file system events but
+	// switches to polling if it finds they aren't being delivered.
+	Mode string `mapstructure:"mode"`
+	// How often the directories are scanned when polling.
+	Interval time.Duration `mapstructure:"interval"`
+	// Whether polling compares the contents of files rather than their size and modification time.
+	Hash bool `mapstructure:"hash"`
+	// Patterns for paths which don't trigger a reload, in the same format as a .gitignore.
+	Ignore []string `mapstructure:"ignore"`
+	// Whether the patterns in the .gitignore in the watched directory are ignored too.
+	GitIgnore bool `mapstructure:"gitignore"`
+	// When set, only changes to files with one of these extensions trigger a reload.
+	Extensions []string `mapstructure:"extensions"`
+}
+
+// Roots returns every directory that should be watched, without any duplicates.
+func (wc WatchConfig) Roots() []string {
+	var roots []string
+	seen := map[string]bool{}
+	for _, dir := range append([]string{wc.Dir}, wc.Dirs...) {
+		if dir != "" && !seen[dir] {
+			roots = append(roots, dir)
+			seen[dir] = true
+		}
+	}
+	return roots
+}
+
+type LogConfig struct {
+	// One of DEBUG, INFO, WARN or ERROR.
+	Level string `mapstructure:"level"`
+	// Either JSON or CONSOLE.
+	Format string `mapstructure:"format"`
+}
+
+type AdminConfig struct {
+	// Whether or not the /_foldadmin routes are served at all.
+	Enabled bool `mapstructure:"enabled"`
+	// A separate address to serve the admin routes on, either a TCP address or a unix domain
+	// socket such as "unix:/tmp/foldadmin.sock". When empty the admin routes are served
+	// alongside the service.
+	Addr string `mapstructure:"addr"`
+	// A bearer token which must be presented to access any admin route other than the health
+	// checks.
+	Token string `mapstructure:"token"`
+	// Whether the health checks remain available on the service address when a separate admin
+	// address is in use.
+	PublicHealth bool `mapstructure:"public-health"`
+	// Whether every admin route is served on the service address without a token. Otherwise only
+	// the health checks are, unless a token is set. This is meant for local development.
+	Public bool `mapstructure:"public"`
+	// TLS settings for the admin listener.
+	TLS TLSConfig `mapstructure:"tls"`
+}
+
+type AuthConfig struct {
+	// The JWKS used to verify bearer tokens, either a URL or the path to a file. Routes which
+	// require authentication reject every request when this is empty.
+	JWKS string `mapstructure:"jwks"`
+	// How often the JWKS is loaded again.
+	Refresh time.Duration `mapstructure:"refresh"`
+	// When set, the iss claim of every token must match.
+	Issuer string `mapstructure:"issuer"`
+	// When set, the aud claim of every token must contain it.
+	Audience string `mapstructure:"audience"`
+	// The allowance for clock skew when checking the exp, nbf and iat claims.
+	Leeway time.Duration `mapstructure:"leeway"`
+	// Tokens without an exp claim are rejected unless this is set.
+	AllowMissingExp bool `mapstructure:"allow-missing-exp"`
+}
+
+type CacheConfig struct {
+	// The number of responses held in the response cache.
+	Size int `mapstructure:"size"`
+}
+
+type HealthCheckConfig struct {
+	// Whether the runtime checks the health of the process using the gRPC health protocol.
+	Enabled bool `mapstructure:"enabled"`
+	// How often the process is checked.
+	Interval time.Duration `mapstructure:"interval"`
+	// How long a check can take before it counts as a failure.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// The number of consecutive failed checks after which the process is restarted.
+	FailureThreshold int `mapstructure:"failure-threshold"`
+}
+
+type ConcurrencyConfig struct {
+	// The maximum number of requests sent to the service at once. Zero means there is no limit.
+	MaxInFlight int `mapstructure:"max-inflight"`
+	// The maximum number of requests waiting to be sent once the limit is reached.
+	MaxQueue int `mapstructure:"max-queue"`
+	// How long a request can wait before it is rejected.
+	MaxWait time.Duration `mapstructure:"max-wait"`
+	// How long clients are told to wait before retrying a rejected request.
+	RetryAfter time.Duration `mapstructure:"retry-after"`
+	// Limits for individual routes, which apply on top of the limit for the service.
+	Routes []RouteConcurrencyConfig `mapstructure:"routes"`
+	// Adjusts the limits according to how long requests take.
+	Adaptive AdaptiveConfig `mapstructure:"adaptive"`
+}
+
+// Enabled returns true if there is a limit for the service or for any of its routes.
+func (c ConcurrencyConfig) Enabled() bool {
+	return c.MaxInFlight > 0 || len(c.Routes) > 0
+}
+
+type RouteConcurrencyConfig struct {
+	Method string `mapstructure:"method"`
+	// The route as it is registered by the service, e.g. /items/:id.
+	Route       string `mapstructure:"route"`
+	MaxInFlight int    `mapstructure:"max-inflight"`
+	// The queue settings default to those of the service when they are zero.
+	MaxQueue int           `mapstructure:"max-queue"`
+	MaxWait  time.Duration `mapstructure:"max-wait"`
+}
+
+type AdaptiveConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// The limits are lowered while requests take longer than this.
+	TargetLatency time.Duration `mapstructure:"target-latency"`
+	// The limits are never lowered below this.
+	MinInFlight int `mapstructure:"min-inflight"`
+}
+
+type RecordConfig struct {
+	// The JSON lines file every request and response is recorded to. Recording is disabled when
+	// it is empty.
+	File string `mapstructure:"file"`
+	// The size in bytes at which the file is rotated.
+	MaxSize int64 `mapstructure:"max-size"`
+	// How many rotated files are kept.
+	MaxFiles int `mapstructure:"max-files"`
+	// Headers whose values are redacted, in addition to Authorization, Cookie, Set-Cookie and
+	// X-Api-Key.
+	RedactHeaders []string `mapstructure:"redact-headers"`
+	// Fields which are redacted at any depth in JSON bodies.
+	RedactFields []string `mapstructure:"redact-fields"`
+}
+
+type IdempotencyConfig struct {
+	// A directory to keep the responses to requests with an idempotency key in, so that they
+	// survive restarts and can be shared by several instances. They are kept in memory when it is
+	// empty.
+	Dir string `mapstructure:"dir"`
+}
+
+type DebugConfig struct {
+	// Whether the service is run under a debugger.
+	Enabled bool `mapstructure:"enabled"`
+	// One of AUTO, DLV or NODE. AUTO picks NODE when the command is node and DLV otherwise.
+	Debugger string `mapstructure:"debugger"`
+	// The port the debugger listens on. Zero picks 2345 for DLV and 9229 for NODE.
+	Port int `mapstructure:"port"`
+}
+
+type AdapterConfig struct {
+	// Whether the process is a plain HTTP server, rather than one which uses a fold SDK.
+	Enabled bool `mapstructure:"enabled"`
+	// The port on localhost the process listens on, which is passed to it as PORT. When zero it
+	// listens on the unix domain socket in FOLD_SOCK_ADDR instead.
+	Port int `mapstructure:"port"`
+	// A JSON file holding the manifest. When empty every request is routed to the process.
+	Manifest string `mapstructure:"manifest"`
+	// A path which responds with a 2xx status while the process is healthy. When empty the
+	// health checks only ensure the process is accepting connections.
+	HealthPath string `mapstructure:"health-path"`
+	// How long to wait for the process to start listening.
+	StartTimeout time.Duration `mapstructure:"start-timeout"`
+}
+
+type TLSConfig struct {
+	Cert string `mapstructure:"cert"`
+	Key  string `mapstructure:"key"`
+	// When set clients must present a certificate signed by this CA.
+	ClientCA string `mapstructure:"client-ca"`
+}
+
+// Load reads the config file at the given path and applies any overrides from the environment.
+// If path is empty then the config is built from the environment and the defaults alone.
+func Load(path string) (*Config, error) {
+	v := newViper()
+	if path != "" {
+		v.SetConfigFile(path)
+		v.SetConfigType("yaml")
+		if err := v.ReadInConfig(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, ConfigNotFound
+			}
+			return nil, fmt.Errorf("%w: %v", ReadConfigErr, err)
+		}
+	}
+	// FOLD_ENV was used to select the handler before the config file existed, so we still honour
+	// it as long as it hasn't been superseded by FOLD_HANDLER.
+	if env, ok := os.LookupEnv("FOLD_ENV"); ok && os.Getenv("FOLD_HANDLER") == "" {
+		v.Set("handler", env)
+	}
+	// The stage dependent defaults can only be set once we know what the stage is.
+	setStageDefaults(v, strings.ToUpper(v.GetString("stage")))
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ReadConfigErr, err)
+	}
+	cfg.normalise()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func newViper() *viper.Viper {
+	v := viper.New()
+	v.SetEnvPrefix("FOLD")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	v.AutomaticEnv()
+
+	v.SetDefault("stage", LOCAL)
+	v.SetDefault("service-name", "")
+	v.SetDefault("handler", HTTP)
+	v.SetDefault("manifest-timeout", 10*time.Second)
+	v.SetDefault("shutdown-timeout", 30*time.Second)
+	v.SetDefault("http.addr", ":6123")
+	v.SetDefault("http.trusted-proxies", []string{})
+	v.SetDefault("watch.dir", "")
+	v.SetDefault("watch.dirs", []string{})
+	v.SetDefault("watch.debounce", 100*time.Millisecond)
+	v.SetDefault("watch.mode", NOTIFY)
+	v.SetDefault("watch.interval", time.Second)
+	v.SetDefault("watch.hash", false)
+	v.SetDefault("watch.ignore", []string{})
+	v.SetDefault("watch.gitignore", true)
+	v.SetDefault("watch.extensions", []string{})
+	v.SetDefault("build-cmd", "")
+	v.SetDefault("build-outputs", []string{})
+	v.SetDefault("ingress", GRPC)
+	v.SetDefault("reload-strategy", RESTART)
+	v.SetDefault("drain-timeout", 30*time.Second)
+	v.SetDefault("admin.enabled", true)
+	v.SetDefault("admin.addr", "")
+	v.SetDefault("admin.token", "")
+	v.SetDefault("admin.public-health", false)
+	v.SetDefault("admin.public", false)
+	v.SetDefault("admin.tls.cert", "")
+	v.SetDefault("admin.tls.key", "")
+	v.SetDefault("admin.tls.client-ca", "")
+	v.SetDefault("auth.jwks", "")
+	v.SetDefault("auth.refresh", time.Hour)
+	v.SetDefault("auth.issuer", "")
+	v.SetDefault("auth.audience", "")
+	v.SetDefault("auth.leeway", time.Minute)
+	v.SetDefault("auth.allow-missing-exp", false)
+	v.SetDefault("cache.size", 1000)
+	v.SetDefault("health-check.enabled", false)
+	v.SetDefault("health-check.interval", 10*time.Second)
+	v.SetDefault("health-check.timeout", 2*time.Second)
+	v.SetDefault("health-check.failure-threshold", 3)
+	v.SetDefault("concurrency.max-inflight", 0)
+	v.SetDefault("concurrency.max-queue", 100)
+	v.SetDefault("concurrency.max-wait", 10*time.Second)
+	v.SetDefault("concurrency.retry-after", time.Second)
+	v.SetDefault("concurrency.adaptive.enabled", false)
+	v.SetDefault("concurrency.adaptive.target-latency", time.Second)
+	v.SetDefault("concurrency.adaptive.min-inflight", 1)
+	v.SetDefault("record.file", "")
+	v.SetDefault("record.max-size", 10*1024*1024)
+	v.SetDefault("record.max-files", 5)
+	v.SetDefault("record.redact-headers", []string{})
+	v.SetDefault("record.redact-fields", []string{})
+	v.SetDefault("idempotency.dir", "")
+	v.SetDefault("debug.enabled", false)
+	v.SetDefault("debug.debugger", AUTO)
+	v.SetDefault("debug.port", 0)
+	v.SetDefault("adapter.enabled", false)
+	v.SetDefault("adapter.port", 0)
+	v.SetDefault("adapter.manifest", "")
+	v.SetDefault("adapter.health-path", "")
+	v.SetDefault("adapter.start-timeout", 30*time.Second)
+	return v
+}
+
+func setStageDefaults(v *viper.Viper, stage string) {
+	switch stage {
+	case DEBUG:
+		v.SetDefault("log.level", "DEBUG")
+		v.SetDefault("log.format", JSON)
+		v.SetDefault("crash-policy", KILL)
+	case TEST:
+		v.SetDefault("log.level", "INFO")
+		v.SetDefault("log.format", JSON)
+		v.SetDefault("crash-policy", KILL)
+	case PROD:
+		v.SetDefault("log.level", "ERROR")
+		v.SetDefault("log.format", JSON)
+		v.SetDefault("crash-policy", KILL)
+	default:
+		// Local development mode
+		v.SetDefault("log.level", "INFO")
+		v.SetDefault("log.format", CONSOLE)
+		v.SetDefault("crash-policy", KEEP_ALIVE)
+	}
+}
+
+func (c *Config) normalise() {
+	c.Stage = strings.ToUpper(c.Stage)
+	c.Handler = strings.ToUpper(c.Handler)
+	c.CrashPolicy = strings.ToUpper(strings.ReplaceAll(c.CrashPolicy, "-", "_"))
+	c.Log.Level = strings.ToUpper(c.Log.Level)
+	c.Log.Format = strings.ToUpper(c.Log.Format)
+	c.Watch.Mode = strings.ToUpper(c.Watch.Mode)
+	c.ReloadStrategy = strings.ToUpper(strings.ReplaceAll(c.ReloadStrategy, "-", "_"))
+	c.Debug.Debugger = strings.ToUpper(c.Debug.Debugger)
+	c.Ingress = strings.ToUpper(c.Ingress)
+	for i := range c.Concurrency.Routes {
+		c.Concurrency.Routes[i].Method = strings.ToUpper(c.Concurrency.Routes[i].Method)
+	}
+}
+
+// Validate checks that every value in the config is usable and returns an InvalidValue error
+// describing the first one that is not.
+func (c *Config) Validate() error {
+	if err := oneOf("stage", c.Stage, LOCAL, DEBUG, TEST, PROD); err != nil {
+		return err
+	}
+	if err := oneOf("handler", c.Handler, HTTP, LAMBDA); err != nil {
+		return err
+	}
+	if err := oneOf("crash-policy", c.CrashPolicy, KILL, KEEP_ALIVE); err != nil {
+		return err
+	}
+	if err := oneOf("log.level", c.Log.Level, "DEBUG", "INFO", "WARN", "ERROR"); err != nil {
+		return err
+	}
+	if err := oneOf("log.format", c.Log.Format, JSON, CONSOLE); err != nil {
+		return err
+	}
+	if err := positive("manifest-timeout", c.ManifestTimeout); err != nil {
+		return err
+	}
+	if err := positive("shutdown-timeout", c.ShutdownTimeout); err != nil {
+		return err
+	}
+	if err := oneOf("reload-strategy", c.ReloadStrategy, RESTART, BLUE_GREEN); err != nil {
+		return err
+	}
+	if err := positive("drain-timeout", c.DrainTimeout); err != nil {
+		return err
+	}
+	if err := oneOf("ingress", c.Ingress, AUTO, GRPC, HTTP); err != nil {
+		return err
+	}
+	if c.Watch.Debounce < 0 {
+		return InvalidValue{"watch.debounce", c.Watch.Debounce, "must not be negative"}
+	}
+	if err := oneOf("watch.mode", c.Watch.Mode, AUTO, NOTIFY, POLL); err != nil {
+		return err
+	}
+	if err := positive("watch.interval", c.Watch.Interval); err != nil {
+		return err
+	}
+	if c.Handler == HTTP && c.HTTP.Addr == "" {
+		return InvalidValue{"http.addr", c.HTTP.Addr, "must be set when using the HTTP handler"}
+	}
+	if _, err := c.HTTP.TrustedNetworks(); err != nil {
+		return err
+	}
+	if err := c.Admin.validate(); err != nil {
+		return err
+	}
+	if c.Auth.JWKS != "" {
+		if err := positive("auth.refresh", c.Auth.Refresh); err != nil {
+			return err
+		}
+	}
+	if c.Cache.Size <= 0 {
+		return InvalidValue{"cache.size", c.Cache.Size, "must be greater than zero"}
+	}
+	if c.Auth.Leeway < 0 {
+		return InvalidValue{"auth.leeway", c.Auth.Leeway, "must not be negative"}
+	}
+	if c.HealthCheck.Enabled {
+		if err := c.HealthCheck.validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.Concurrency.validate(); err != nil {
+		return err
+	}
+	if c.Record.File != "" {
+		if c.Record.MaxSize <= 0 {
+			return InvalidValue{"record.max-size", c.Record.MaxSize, "must be greater than zero"}
+		}
+		if c.Record.MaxFiles < 0 {
+			return InvalidValue{"record.max-files", c.Record.MaxFiles, "must not be negative"}
+		}
+	}
+	if err := oneOf("debug.debugger", c.Debug.Debugger, AUTO, DLV, NODE); err != nil {
+		return err
+	}
+	if c.Debug.Port < 0 || c.Debug.Port > 65535 {
+		return InvalidValue{"debug.port", c.Debug.Port, "must be a valid port"}
+	}
+	if c.Adapter.Enabled {
+		if err := c.validateAdapter(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateAdapter() error {
+	if c.Adapter.Port < 0 || c.Adapter.Port > 65535 {
+		return InvalidValue{"adapter.port", c.Adapter.Port, "must be a valid port"}
+	}
+	// Both processes would need the same port during a blue/green reload.
+	if c.Adapter.Port != 0 && c.ReloadStrategy == BLUE_GREEN {
+		return InvalidValue{"adapter.port", c.Adapter.Port, "can't be used with BLUE_GREEN reloads"}
+	}
+	if c.Adapter.HealthPath != "" && !strings.HasPrefix(c.Adapter.HealthPath, "/") {
+		return InvalidValue{"adapter.health-path", c.Adapter.HealthPath, "must begin with /"}
+	}
+	return positive("adapter.start-timeout", c.Adapter.StartTimeout)
+}
+
+func (h *HealthCheckConfig) validate() error {
+	if err := positive("health-check.interval", h.Interval); err != nil {
+		return err
+	}
+	if err := positive("health-check.timeout", h.Timeout); err != nil {
+		return err
+	}
+	if h.FailureThreshold <= 0 {
+		return InvalidValue{
+			"health-check.failure-threshold",
+			h.FailureThreshold,
+			"must be greater than zero",
+		}
+	}
+	return nil
+}
+
+func (a *AdminConfig) validate() error {
+	if a.Addr == "" {
+		if a.TLS.Cert != "" || a.TLS.ClientCA != "" {
+			return InvalidValue{"admin.tls", a.TLS.Cert, "requires admin.addr to be set"}
+		}
+		return nil
+	}
+	if a.Addr == "unix:" {
+		return InvalidValue{"admin.addr", a.Addr, "a socket path is required"}
+	}
+	if (a.TLS.Cert == "") != (a.TLS.Key == "") {
+		return InvalidValue{"admin.tls", a.TLS.Cert, "cert and key must be set together"}
+	}
+	if a.TLS.ClientCA != "" && a.TLS.Cert == "" {
+		return InvalidValue{"admin.tls.client-ca", a.TLS.ClientCA, "requires a cert and key"}
+	}
+	return nil
+}
+
+func (c *ConcurrencyConfig) validate() error {
+	if c.MaxInFlight < 0 {
+		return InvalidValue{"concurrency.max-inflight", c.MaxInFlight, "must not be negative"}
+	}
+	if c.MaxQueue < 0 {
+		return InvalidValue{"concurrency.max-queue", c.MaxQueue, "must not be negative"}
+	}
+	if c.MaxWait < 0 {
+		return InvalidValue{"concurrency.max-wait", c.MaxWait, "must not be negative"}
+	}
+	if err := positive("concurrency.retry-after", c.RetryAfter); err != nil {
+		return err
+	}
+	for _, route := range c.Routes {
+		if route.Route == "" {
+			return InvalidValue{"concurrency.routes", route.Method, "every route must be set"}
+		}
+		if err := oneOf("concurrency.routes.method", route.Method, httpMethods...); err != nil {
+			return err
+		}
+		if route.MaxInFlight <= 0 {
+			return InvalidValue{
+				"concurrency.routes.max-inflight",
+				route.MaxInFlight,
+				"must be greater than zero",
+			}
+		}
+	}
+	if c.Adaptive.Enabled {
+		if err := positive("concurrency.adaptive.target-latency", c.Adaptive.TargetLatency); err != nil {
+			return err
+		}
+		if c.Adaptive.MinInFlight <= 0 {
+			return InvalidValue{
+				"concurrency.adaptive.min-inflight",
+				c.Adaptive.MinInFlight,
+				"must be greater than zero",
+			}
+		}
+	}
+	return nil
+}
+
+var httpMethods = []string{
+	"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH",
+}
+
+// LogLevel converts the configured log level to a logging.LogLevel.
+func (c *Config) LogLevel() logging.LogLevel {
+	switch c.Log.Level {
+	case "DEBUG":
+		return logging.Debug
+	case "WARN":
+		return logging.Warn
+	case "ERROR":
+		return logging.Error
+	default:
+		return logging.Info
+	}
+}
+
+func oneOf(key, value string, allowed ...string) error {
+	for _, a := range allowed {
+		if value == a {
+			return nil
+		}
+	}
+	return InvalidValue{key, value, fmt.Sprintf("must be one of %s", strings.Join(allowed, ", "))}
+}
+
+func positive(key string, d time.Duration) error {
+	if d <= 0 {
+		return InvalidValue{key, d, "must be greater than zero"}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package config_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/config"
+)
+
+func TestLoadRuntimeConfig(t *testing.T) {
+	cfg, err := config.Load("./testdata/foldrt.yaml")
+	require.Nil(t, err)
+
+	assert.Equal(t, config.TEST, cfg.Stage)
+	assert.Equal(t, "items", cfg.ServiceName)
+	assert.Equal(t, config.HTTP, cfg.Handler)
+	assert.Equal(t, config.KEEP_ALIVE, cfg.CrashPolicy)
+	assert.Equal(t, 5*time.Second, cfg.ManifestTimeout)
+	assert.Equal(t, time.Minute, cfg.ShutdownTimeout)
+	assert.Equal(t, ":8080", cfg.HTTP.Addr)
+	networks, err := cfg.HTTP.TrustedNetworks()
+	require.NoError(t, err)
+	require.Len(t, networks, 2)
+	assert.Equal(t, "10.0.0.0/8", networks[0].String())
+	assert.Equal(t, "192.168.1.1/32", networks[1].String())
+	assert.Equal(t, "/fold/src", cfg.Watch.Dir)
+	assert.Equal(t, []string{"/fold/src", "/fold/lib"}, cfg.Watch.Roots())
+	assert.Equal(t, 250*time.Millisecond, cfg.Watch.Debounce)
+	assert.Equal(t, []string{"dist/", "*.log"}, cfg.Watch.Ignore)
+	assert.False(t, cfg.Watch.GitIgnore)
+	assert.Equal(t, config.POLL, cfg.Watch.Mode)
+	assert.Equal(t, 2*time.Second, cfg.Watch.Interval)
+	assert.True(t, cfg.Watch.Hash)
+	assert.Equal(t, []string{".go"}, cfg.Watch.Extensions)
+	assert.Equal(t, "go build -o ./bin/service .", cfg.BuildCmd)
+	assert.Equal(t, []string{"bin/"}, cfg.BuildOutputs)
+	assert.Equal(t, config.HTTP, cfg.Ingress)
+	assert.Equal(t, config.BLUE_GREEN, cfg.ReloadStrategy)
+	assert.Equal(t, 5*time.Second, cfg.DrainTimeout)
+	assert.Equal(t, logging.Debug, cfg.LogLevel())
+	assert.Equal(t, config.CONSOLE, cfg.Log.Format)
+	assert.False(t, cfg.Admin.Enabled)
+	assert.Equal(t, "unix:/tmp/foldadmin.sock", cfg.Admin.Addr)
+	assert.Equal(t, "secret", cfg.Admin.Token)
+	assert.True(t, cfg.Admin.PublicHealth)
+	assert.True(t, cfg.Admin.Public)
+	assert.Equal(t, "https://auth.fold.sh/.well-known/jwks.json", cfg.Auth.JWKS)
+	assert.Equal(t, 30*time.Minute, cfg.Auth.Refresh)
+	assert.Equal(t, "https://auth.fold.sh", cfg.Auth.Issuer)
+	assert.Equal(t, "fold", cfg.Auth.Audience)
+	assert.Equal(t, time.Minute, cfg.Auth.Leeway)
+	assert.False(t, cfg.Auth.AllowMissingExp)
+	assert.False(t, cfg.HealthCheck.Enabled)
+	assert.Equal(t, 30*time.Second, cfg.HealthCheck.Interval)
+	assert.Equal(t, 5*time.Second, cfg.HealthCheck.Timeout)
+	assert.Equal(t, 5, cfg.HealthCheck.FailureThreshold)
+	assert.Equal(t, 10, cfg.Concurrency.MaxInFlight)
+	assert.Equal(t, 20, cfg.Concurrency.MaxQueue)
+	assert.Equal(t, 2*time.Second, cfg.Concurrency.MaxWait)
+	assert.Equal(
+		t,
+		[]config.RouteConcurrencyConfig{{Method: "POST", Route: "/reports", MaxInFlight: 2}},
+		cfg.Concurrency.Routes,
+	)
+	assert.True(t, cfg.Concurrency.Adaptive.Enabled)
+	assert.Equal(t, 250*time.Millisecond, cfg.Concurrency.Adaptive.TargetLatency)
+	assert.Equal(t, 1, cfg.Concurrency.Adaptive.MinInFlight)
+	assert.Equal(t, "/tmp/requests.jsonl", cfg.Record.File)
+	assert.Equal(t, int64(1048576), cfg.Record.MaxSize)
+	assert.Equal(t, 2, cfg.Record.MaxFiles)
+	assert.Equal(t, []string{"x-session"}, cfg.Record.RedactHeaders)
+	assert.Equal(t, []string{"password"}, cfg.Record.RedactFields)
+	assert.Equal(t, "/tmp/idempotency", cfg.Idempotency.Dir)
+	assert.True(t, cfg.Debug.Enabled)
+	assert.Equal(t, config.NODE, cfg.Debug.Debugger)
+	assert.Equal(t, 9230, cfg.Debug.Port)
+	assert.True(t, cfg.Adapter.Enabled)
+	assert.Equal(t, 0, cfg.Adapter.Port)
+	assert.Equal(t, "/fold/manifest.json", cfg.Adapter.Manifest)
+	assert.Equal(t, "/health", cfg.Adapter.HealthPath)
+	assert.Equal(t, 30*time.Second, cfg.Adapter.StartTimeout)
+}
+
+func TestDefaultRuntimeConfig(t *testing.T) {
+	cfg, err := config.Load("")
+	require.Nil(t, err)
+
+	assert.Equal(t, config.LOCAL, cfg.Stage)
+	assert.Equal(t, config.HTTP, cfg.Handler)
+	assert.Equal(t, config.KEEP_ALIVE, cfg.CrashPolicy)
+	assert.Equal(t, 10*time.Second, cfg.ManifestTimeout)
+	assert.Equal(t, 30*time.Second, cfg.ShutdownTimeout)
+	assert.Equal(t, ":6123", cfg.HTTP.Addr)
+	assert.Empty(t, cfg.HTTP.TrustedProxies)
+	assert.Equal(t, 100*time.Millisecond, cfg.Watch.Debounce)
+	assert.True(t, cfg.Watch.GitIgnore)
+	assert.Equal(t, config.NOTIFY, cfg.Watch.Mode)
+	assert.Empty(t, cfg.BuildOutputs)
+	assert.Equal(t, config.RESTART, cfg.ReloadStrategy)
+	assert.Equal(t, config.GRPC, cfg.Ingress)
+	assert.Equal(t, time.Second, cfg.Watch.Interval)
+	assert.Equal(t, logging.Info, cfg.LogLevel())
+	assert.True(t, cfg.Admin.Enabled)
+	assert.False(t, cfg.Admin.Public)
+	assert.Equal(t, 1000, cfg.Cache.Size)
+	assert.False(t, cfg.HealthCheck.Enabled)
+	assert.Equal(t, 10*time.Second, cfg.HealthCheck.Interval)
+	assert.Equal(t, 2*time.Second, cfg.HealthCheck.Timeout)
+	assert.Equal(t, 3, cfg.HealthCheck.FailureThreshold)
+	assert.False(t, cfg.Concurrency.Enabled())
+	assert.Equal(t, time.Second, cfg.Concurrency.RetryAfter)
+	assert.Equal(t, "", cfg.Record.File)
+	assert.Equal(t, 5, cfg.Record.MaxFiles)
+	assert.Equal(t, "", cfg.Idempotency.Dir)
+	assert.False(t, cfg.Debug.Enabled)
+	assert.Equal(t, config.AUTO, cfg.Debug.Debugger)
+}
+
+func TestStageDefaults(t *testing.T) {
+	setenv(t, "FOLD_STAGE", "PROD")
+
+	cfg, err := config.Load("")
+	require.Nil(t, err)
+
+	assert.Equal(t, config.KILL, cfg.CrashPolicy)
+	assert.Equal(t, logging.Error, cfg.LogLevel())
+	assert.Equal(t, config.JSON, cfg.Log.Format)
+}
+
+func TestEnvOverridesFile(t *testing.T) {
+	setenv(t, "FOLD_WATCH_DIR", "/somewhere/else")
+	setenv(t, "FOLD_WATCH_DIRS", "/a,/b")
+	setenv(t, "FOLD_ENV", "LAMBDA")
+	setenv(t, "FOLD_MANIFEST_TIMEOUT", "2s")
+	setenv(t, "FOLD_BUILD_CMD", "make")
+	setenv(t, "FOLD_WATCH_EXTENSIONS", ".go,.mod")
+
+	cfg, err := config.Load("./testdata/foldrt.yaml")
+	require.Nil(t, err)
+
+	assert.Equal(t, "/somewhere/else", cfg.Watch.Dir)
+	assert.Equal(t, []string{"/somewhere/else", "/a", "/b"}, cfg.Watch.Roots())
+	assert.Equal(t, config.LAMBDA, cfg.Handler)
+	assert.Equal(t, 2*time.Second, cfg.ManifestTimeout)
+	assert.Equal(t, "make", cfg.BuildCmd)
+	assert.Equal(t, []string{".go", ".mod"}, cfg.Watch.Extensions)
+}
+
+func TestInvalidConfig(t *testing.T) {
+	_, err := config.Load("./testdata/invalid.yaml")
+	var invalid config.InvalidValue
+	require.True(t, errors.As(err, &invalid), "expected an InvalidValue error but got %v", err)
+	assert.Equal(t, "handler", invalid.Key)
+
+	setenv(t, "FOLD_ADMIN_TLS_CERT", "/cert.pem")
+	_, err = config.Load("")
+	require.True(t, errors.As(err, &invalid), "expected an InvalidValue error but got %v", err)
+	assert.Equal(t, "admin.tls", invalid.Key)
+
+	os.Unsetenv("FOLD_ADMIN_TLS_CERT")
+	setenv(t, "FOLD_HEALTH_CHECK_ENABLED", "true")
+	setenv(t, "FOLD_HEALTH_CHECK_FAILURE_THRESHOLD", "0")
+	_, err = config.Load("")
+	require.True(t, errors.As(err, &invalid), "expected an InvalidValue error but got %v", err)
+	assert.Equal(t, "health-check.failure-threshold", invalid.Key)
+
+	os.Unsetenv("FOLD_HEALTH_CHECK_ENABLED")
+	os.Unsetenv("FOLD_HEALTH_CHECK_FAILURE_THRESHOLD")
+	setenv(t, "FOLD_INGRESS", "json")
+	_, err = config.Load("")
+	require.True(t, errors.As(err, &invalid), "expected an InvalidValue error but got %v", err)
+	assert.Equal(t, "ingress", invalid.Key)
+
+	os.Unsetenv("FOLD_INGRESS")
+	setenv(t, "FOLD_HTTP_TRUSTED_PROXIES", "10.0.0.0/33")
+	_, err = config.Load("")
+	require.True(t, errors.As(err, &invalid), "expected an InvalidValue error but got %v", err)
+	assert.Equal(t, "http.trusted-proxies", invalid.Key)
+
+	os.Unsetenv("FOLD_HTTP_TRUSTED_PROXIES")
+	setenv(t, "FOLD_ADAPTER_PORT", "8080")
+	_, err = config.Load("./testdata/foldrt.yaml")
+	require.True(t, errors.As(err, &invalid), "expected an InvalidValue error but got %v", err)
+	assert.Equal(t, "adapter.port", invalid.Key)
+
+	_, err = config.Load("./testdata/missing.yaml")
+	assert.True(t, errors.Is(err, config.ConfigNotFound))
+}
+
+func setenv(t *testing.T, key, value string) {
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(func() { os.Unsetenv(key) })
+}
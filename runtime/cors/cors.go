@@ -0,0 +1,174 @@
+// Package cors implements Cross-Origin Resource Sharing for a policy declared in a service
+// manifest. It is used both by the runtime router and by the local gateway.
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+type Policy struct {
+	policy         *manifest.CorsPolicy
+	anyOrigin      bool
+	origins        []*regexp.Regexp
+	anyHeader      bool
+	allowedHeaders map[string]struct{}
+	allowedMethods map[string]struct{}
+}
+
+// NewPolicy compiles a policy from the manifest so that it can be applied to requests.
+func NewPolicy(policy *manifest.CorsPolicy) *Policy {
+	p := &Policy{
+		policy:         policy,
+		allowedHeaders: make(map[string]struct{}),
+		allowedMethods: make(map[string]struct{}),
+	}
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			p.anyOrigin = true
+			continue
+		}
+		// A '*' matches a single subdomain or a sequence of them.
+		pattern := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, `[a-zA-Z0-9.-]+`)
+		p.origins = append(p.origins, regexp.MustCompile(fmt.Sprintf("^%s$", pattern)))
+	}
+	for _, header := range policy.AllowedHeaders {
+		if header == "*" {
+			p.anyHeader = true
+			continue
+		}
+		p.allowedHeaders[http.CanonicalHeaderKey(header)] = struct{}{}
+	}
+	for _, method := range policy.AllowedMethods {
+		p.allowedMethods[strings.ToUpper(method)] = struct{}{}
+	}
+	return p
+}
+
+// IsPreflight returns true if the request is a CORS preflight request.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" &&
+		r.Header.Get("Origin") != "" &&
+		r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Preflight answers a preflight request. The allow argument lists the methods available on the
+// requested resource, these are used if the policy doesn't list any methods itself. If the
+// request is not permitted the response carries no CORS headers and the browser will block it.
+func (p *Policy) Preflight(w http.ResponseWriter, r *http.Request, allow []string) {
+	headers := w.Header()
+	headers.Add("Vary", "Origin")
+	headers.Add("Vary", "Access-Control-Request-Method")
+	headers.Add("Vary", "Access-Control-Request-Headers")
+	origin := r.Header.Get("Origin")
+	method := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+	requestedHeaders := parseList(r.Header.Get("Access-Control-Request-Headers"))
+	if !p.originAllowed(origin) || !p.methodAllowed(method, allow) ||
+		!p.headersAllowed(requestedHeaders) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	p.setOrigin(headers, origin)
+	if len(p.policy.AllowedMethods) > 0 {
+		headers.Set("Access-Control-Allow-Methods", strings.Join(p.policy.AllowedMethods, ", "))
+	} else {
+		headers.Set("Access-Control-Allow-Methods", strings.Join(allow, ", "))
+	}
+	if len(requestedHeaders) > 0 {
+		// Echoing the requested headers is valid both for a wildcard and an explicit list as we
+		// have already checked that every one of them is allowed.
+		headers.Set("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+	if p.policy.MaxAge > 0 {
+		headers.Set("Access-Control-Max-Age", fmt.Sprintf("%d", p.policy.MaxAge))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Decorate adds the CORS headers for an actual (i.e. not preflight) request to the response
+// headers. It must be called before the response headers are written.
+func (p *Policy) Decorate(headers http.Header, r *http.Request) {
+	headers.Add("Vary", "Origin")
+	origin := r.Header.Get("Origin")
+	if origin == "" || !p.originAllowed(origin) {
+		return
+	}
+	p.setOrigin(headers, origin)
+	if len(p.policy.ExposedHeaders) > 0 {
+		headers.Set("Access-Control-Expose-Headers", strings.Join(p.policy.ExposedHeaders, ", "))
+	}
+}
+
+// Strip removes any CORS headers from the response headers. It is used when a proxy applies its
+// own policy in place of the upstream one.
+func Strip(headers http.Header) {
+	for key := range headers {
+		if strings.HasPrefix(key, "Access-Control-") {
+			headers.Del(key)
+		}
+	}
+}
+
+func (p *Policy) setOrigin(headers http.Header, origin string) {
+	// A wildcard can't be used when credentials are allowed, so in that case we always echo the
+	// origin back.
+	if p.anyOrigin && !p.policy.AllowCredentials {
+		headers.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		headers.Set("Access-Control-Allow-Origin", origin)
+	}
+	if p.policy.AllowCredentials {
+		headers.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (p *Policy) originAllowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	for _, re := range p.origins {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) methodAllowed(method string, allow []string) bool {
+	if len(p.allowedMethods) > 0 {
+		_, ok := p.allowedMethods[method]
+		return ok
+	}
+	for _, a := range allow {
+		if a == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) headersAllowed(headers []string) bool {
+	if p.anyHeader {
+		return true
+	}
+	for _, h := range headers {
+		if _, ok := p.allowedHeaders[http.CanonicalHeaderKey(h)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func parseList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
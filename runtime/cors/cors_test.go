@@ -0,0 +1,98 @@
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/cors"
+)
+
+func TestPreflight(t *testing.T) {
+	policy := cors.NewPolicy(&manifest.CorsPolicy{
+		AllowedOrigins: []string{"https://fold.sh", "https://*.fold.sh"},
+		AllowedHeaders: []string{"Content-Type"},
+		MaxAge:         600,
+	})
+	allow := []string{"GET", "POST", "OPTIONS"}
+	cases := []struct {
+		name     string
+		origin   string
+		method   string
+		headers  string
+		expected http.Header
+	}{
+		{
+			"allowed origin",
+			"https://fold.sh",
+			"POST",
+			"content-type",
+			http.Header{
+				"Access-Control-Allow-Origin":  []string{"https://fold.sh"},
+				"Access-Control-Allow-Methods": []string{"GET, POST, OPTIONS"},
+				"Access-Control-Allow-Headers": []string{"content-type"},
+				"Access-Control-Max-Age":       []string{"600"},
+			},
+		},
+		{
+			"allowed origin pattern",
+			"https://api.fold.sh",
+			"GET",
+			"",
+			http.Header{
+				"Access-Control-Allow-Origin":  []string{"https://api.fold.sh"},
+				"Access-Control-Allow-Methods": []string{"GET, POST, OPTIONS"},
+				"Access-Control-Max-Age":       []string{"600"},
+			},
+		},
+		{"disallowed origin", "https://evil.com", "GET", "", http.Header{}},
+		{"disallowed method", "https://fold.sh", "DELETE", "", http.Header{}},
+		{"disallowed header", "https://fold.sh", "GET", "X-Secret", http.Header{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("OPTIONS", "/foo", nil)
+			req.Header.Set("Origin", tc.origin)
+			req.Header.Set("Access-Control-Request-Method", tc.method)
+			if tc.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tc.headers)
+			}
+			if !cors.IsPreflight(req) {
+				t.Fatalf("Expected the request to be a preflight request")
+			}
+			w := httptest.NewRecorder()
+			policy.Preflight(w, req, allow)
+			if w.Code != 204 {
+				t.Errorf("Expected a 204 response but found %d", w.Code)
+			}
+			tc.expected["Vary"] = []string{
+				"Origin",
+				"Access-Control-Request-Method",
+				"Access-Control-Request-Headers",
+			}
+			testutils.Diff(t, tc.expected, w.Header(), "Preflight headers did not match")
+		})
+	}
+}
+
+func TestDecorate(t *testing.T) {
+	policy := cors.NewPolicy(&manifest.CorsPolicy{
+		AllowedOrigins:   []string{"*"},
+		ExposedHeaders:   []string{"X-Fold"},
+		AllowCredentials: true,
+	})
+	req := httptest.NewRequest("GET", "/foo", nil)
+	req.Header.Set("Origin", "https://fold.sh")
+	headers := http.Header{}
+	policy.Decorate(headers, req)
+	// Credentials are allowed so the origin must be echoed rather than using a wildcard.
+	expectation := http.Header{
+		"Vary":                             []string{"Origin"},
+		"Access-Control-Allow-Origin":      []string{"https://fold.sh"},
+		"Access-Control-Allow-Credentials": []string{"true"},
+		"Access-Control-Expose-Headers":    []string{"X-Fold"},
+	}
+	testutils.Diff(t, expectation, headers, "Response headers did not match")
+}
@@ -0,0 +1,138 @@
+// Package debugger runs the service under a debugger so that an IDE can attach to it remotely.
+// Go services are run with delve in headless mode and node services have the inspector enabled
+// through NODE_OPTIONS.
+package debugger
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+type DebuggerT uint8
+
+const (
+	// Picks NODE for commands which run node and DLV for Go binaries.
+	AUTO DebuggerT = iota + 1
+	DLV
+	NODE
+)
+
+// The ports the debuggers listen on by default.
+const (
+	DLVPort  = 2345
+	NODEPort = 9229
+)
+
+// The command is neither node nor a Go binary, so the debugger has to be chosen explicitly.
+var CannotDetect = errors.New("cannot tell which debugger to use, set debug.debugger")
+
+var nodeCommands = map[string]struct{}{"node": {}, "nodejs": {}, "ts-node": {}}
+
+// Debugger describes how to run the service's command under a debugger.
+type Debugger struct {
+	kind DebuggerT
+	port int
+}
+
+// NewDebugger returns a debugger which listens on the given port on every interface. A port of
+// zero picks the debugger's usual port.
+func NewDebugger(kind DebuggerT, port int, cmd string) (*Debugger, error) {
+	if kind == AUTO {
+		detected, err := Detect(cmd)
+		if err != nil {
+			return nil, err
+		}
+		kind = detected
+	}
+	if port == 0 {
+		port = DLVPort
+		if kind == NODE {
+			port = NODEPort
+		}
+	}
+	return &Debugger{kind: kind, port: port}, nil
+}
+
+// Detect picks the debugger for the command. Anything other than node or a Go binary, e.g. a
+// package manager or a shell script, returns CannotDetect rather than guessing.
+func Detect(cmd string) (DebuggerT, error) {
+	if _, ok := nodeCommands[filepath.Base(cmd)]; ok {
+		return NODE, nil
+	}
+	if path, err := exec.LookPath(cmd); err == nil && isGoBinary(path) {
+		return DLV, nil
+	}
+	return 0, fmt.Errorf("%w: %s", CannotDetect, cmd)
+}
+
+// isGoBinary reports whether the file is an executable built by the Go toolchain, which keeps
+// its line table even when the binary is stripped.
+func isGoBinary(path string) bool {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return f.Section(".gopclntab") != nil || f.Section(".go.buildinfo") != nil
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return f.Section("__gopclntab") != nil || f.Section("__go_buildinfo") != nil
+	}
+	return false
+}
+
+func (d *Debugger) Kind() DebuggerT {
+	return d.kind
+}
+
+func (d *Debugger) Port() int {
+	return d.port
+}
+
+// Command returns the command which runs the service under the debugger. The service is started
+// straight away rather than waiting for a client to attach, and clients can attach and detach as
+// often as they like.
+func (d *Debugger) Command(cmd string, args []string) (string, []string) {
+	if d.kind != DLV {
+		return cmd, args
+	}
+	dlvArgs := []string{
+		"exec",
+		"--headless",
+		fmt.Sprintf("--listen=0.0.0.0:%d", d.port),
+		"--api-version=2",
+		"--accept-multiclient",
+		"--continue",
+		cmd,
+	}
+	if len(args) > 0 {
+		dlvArgs = append(append(dlvArgs, "--"), args...)
+	}
+	return "dlv", dlvArgs
+}
+
+// Env returns the environment variables which enable the debugger, on top of the runtime's own
+// environment.
+func (d *Debugger) Env() map[string]string {
+	if d.kind != NODE {
+		return nil
+	}
+	inspect := fmt.Sprintf("--inspect=0.0.0.0:%d", d.port)
+	return map[string]string{
+		"NODE_OPTIONS": strings.TrimSpace(fmt.Sprintf("%s %s", os.Getenv("NODE_OPTIONS"), inspect)),
+	}
+}
+
+// StopSignal returns the signal which stops the service. Delve only shuts down cleanly, taking
+// the service with it, when it is interrupted.
+func (d *Debugger) StopSignal() os.Signal {
+	if d.kind == DLV {
+		return syscall.SIGINT
+	}
+	return syscall.SIGTERM
+}
@@ -0,0 +1,95 @@
+package debugger_test
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/runtime/debugger"
+)
+
+func TestDetect(t *testing.T) {
+	// The test binary is built by the Go toolchain, so it stands in for a Go service.
+	service, err := os.Executable()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cases := map[string]debugger.DebuggerT{
+		"node":                debugger.NODE,
+		"/usr/local/bin/node": debugger.NODE,
+		"ts-node":             debugger.NODE,
+		service:               debugger.DLV,
+	}
+	for cmd, expected := range cases {
+		actual, err := debugger.Detect(cmd)
+		if err != nil {
+			t.Errorf("Expected %s to be detected but got %v", cmd, err)
+		} else if actual != expected {
+			t.Errorf("Expected %s to be run with %d but found %d", cmd, expected, actual)
+		}
+	}
+}
+
+func TestDetectRefusesToGuess(t *testing.T) {
+	for _, cmd := range []string{"npm", "yarn", "sh", "/fold/service/missing", "debugger_test.go"} {
+		if _, err := debugger.Detect(cmd); !errors.Is(err, debugger.CannotDetect) {
+			t.Errorf("Expected %s not to be detected but got %v", cmd, err)
+		}
+		if _, err := debugger.NewDebugger(debugger.AUTO, 0, cmd); !errors.Is(err, debugger.CannotDetect) {
+			t.Errorf("Expected no debugger for %s but got %v", cmd, err)
+		}
+	}
+	// Choosing the debugger explicitly works for any command.
+	if _, err := debugger.NewDebugger(debugger.DLV, 0, "sh"); err != nil {
+		t.Errorf("Expected an explicit debugger to be used but got %v", err)
+	}
+}
+
+func TestDLV(t *testing.T) {
+	d, err := debugger.NewDebugger(debugger.DLV, 0, "./bin/service")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cmd, args := d.Command("./bin/service", []string{"-v"})
+	testutils.Diff(t, "dlv", cmd, "Command did not match")
+	testutils.Diff(
+		t,
+		[]string{
+			"exec",
+			"--headless",
+			"--listen=0.0.0.0:2345",
+			"--api-version=2",
+			"--accept-multiclient",
+			"--continue",
+			"./bin/service",
+			"--",
+			"-v",
+		},
+		args,
+		"Args did not match",
+	)
+	if d.Env() != nil {
+		t.Errorf("Expected no environment variables but found %v", d.Env())
+	}
+	if d.StopSignal() != syscall.SIGINT {
+		t.Errorf("Expected delve to be interrupted but found %v", d.StopSignal())
+	}
+}
+
+func TestNode(t *testing.T) {
+	d, err := debugger.NewDebugger(debugger.NODE, 9300, "npm")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cmd, args := d.Command("npm", []string{"start"})
+	testutils.Diff(t, "npm", cmd, "Command did not match")
+	testutils.Diff(t, []string{"start"}, args, "Args did not match")
+	testutils.Diff(
+		t,
+		map[string]string{"NODE_OPTIONS": "--inspect=0.0.0.0:9300"},
+		d.Env(),
+		"Env did not match",
+	)
+}
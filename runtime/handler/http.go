@@ -2,17 +2,40 @@ package handler
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
 
 	"github.com/foldsh/fold/logging"
 )
 
+// NewHTTP creates a handler which serves HTTP on the given address. The address is either a TCP
+// address such as ":6123" or a unix domain socket such as "unix:/tmp/fold.sock".
 func NewHTTP(
 	logger logging.Logger,
 	handler http.Handler,
 	addr string,
+	options ...HTTPOption,
 ) *HTTPHandler {
-	return &HTTPHandler{logger: logger, server: &http.Server{Addr: addr, Handler: handler}}
+	h := &HTTPHandler{logger: logger, server: &http.Server{Addr: addr, Handler: handler}}
+	for _, option := range options {
+		option(h)
+	}
+	return h
+}
+
+type HTTPOption func(*HTTPHandler)
+
+// WithTLS serves HTTPS using the given config. It should contain the server certificate, and
+// optionally a client CA pool to enforce mutual TLS.
+func WithTLS(config *tls.Config) HTTPOption {
+	return func(h *HTTPHandler) {
+		h.server.TLSConfig = config
+	}
 }
 
 type HTTPHandler struct {
@@ -21,7 +44,18 @@ type HTTPHandler struct {
 }
 
 func (h *HTTPHandler) Serve() error {
-	if err := h.server.ListenAndServe(); err != http.ErrServerClosed {
+	network, address := "tcp", h.server.Addr
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	}
+	lis, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	if h.server.TLSConfig != nil {
+		lis = tls.NewListener(lis, h.server.TLSConfig)
+	}
+	if err := h.server.Serve(lis); err != http.ErrServerClosed {
 		return err
 	}
 	return nil
@@ -34,3 +68,27 @@ func (h *HTTPHandler) Shutdown(ctx context.Context, done chan struct{}) {
 	}
 	close(done)
 }
+
+// LoadTLSConfig builds a TLS config from a certificate and key on disk. If a client CA file is
+// given then clients must present a certificate signed by it.
+func LoadTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return config, nil
+	}
+	pem, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("no valid certificates found in client CA file")
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
@@ -2,7 +2,9 @@ package handler
 
 import (
 	"context"
+	"encoding/base64"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -48,6 +50,8 @@ func (lh *LambdaHandler) Handle(
 		}, err
 	}
 	req.Header = e.MultiValueHeaders
+	// API Gateway doesn't tell us the client's port, but the address has to have one.
+	req.RemoteAddr = net.JoinHostPort(e.RequestContext.Identity.SourceIP, "0")
 	req.ContentLength = int64(len(e.Body))
 	req.Close = false
 	req.Host = e.Headers["Host"]
@@ -76,6 +80,15 @@ func NewResponseWriter() *ResponseWriter {
 }
 
 func (rw *ResponseWriter) toAPIGatewayResponse() events.APIGatewayProxyResponse {
+	if rw.headers.Get("Content-Encoding") != "" {
+		// Compressed bodies are binary so they have to be base64 encoded for API Gateway.
+		return events.APIGatewayProxyResponse{
+			StatusCode:        rw.statusCode,
+			MultiValueHeaders: rw.headers,
+			Body:              base64.StdEncoding.EncodeToString(rw.body),
+			IsBase64Encoded:   true,
+		}
+	}
 	return events.APIGatewayProxyResponse{
 		StatusCode:        rw.statusCode,
 		MultiValueHeaders: rw.headers,
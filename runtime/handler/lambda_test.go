@@ -24,6 +24,9 @@ func TestLambdaHandler(t *testing.T) {
 		Path:              "/foo/bar/baz",
 		Body:              `{"statusCode":1234,"headers":{"Test-Header":["foo","bar","baz"]}}`,
 		MultiValueHeaders: map[string][]string{"Content-Type": []string{"application/json"}},
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Identity: events.APIGatewayRequestIdentity{SourceIP: "1.2.3.4"},
+		},
 	}
 
 	res, err := lambda.Handle(context.Background(), req)
@@ -33,7 +36,7 @@ func TestLambdaHandler(t *testing.T) {
 	expectation := events.APIGatewayProxyResponse{
 		StatusCode:        1234,
 		MultiValueHeaders: map[string][]string{"Test-Header": []string{"foo", "bar", "baz"}},
-		Body:              `{"method":"DELETE","path":"/foo/bar/baz"}`,
+		Body:              `{"method":"DELETE","path":"/foo/bar/baz","remoteAddr":"1.2.3.4:0"}`,
 	}
 
 	testutils.Diff(t, expectation, res, "Body did not match expectation")
@@ -56,7 +59,11 @@ func (m *mockHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			headers.Add(key, value)
 		}
 	}
-	responseBody := map[string]interface{}{"method": r.Method, "path": r.URL.String()}
+	responseBody := map[string]interface{}{
+		"method":     r.Method,
+		"path":       r.URL.String(),
+		"remoteAddr": r.RemoteAddr,
+	}
 	w.Write(testutils.MarshalJSON(m.t, responseBody))
 }
 
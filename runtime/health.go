@@ -0,0 +1,156 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/admin"
+	"github.com/foldsh/fold/runtime/transport"
+)
+
+// prober periodically checks the health of a process through its client. Each process gets its
+// own prober, which is stopped along with the process.
+type prober struct {
+	logger    logging.Logger
+	client    Client
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+	// Called after every check once the consecutive failures have reached the threshold.
+	onUnhealthy func()
+	stop        chan struct{}
+
+	mutex    sync.Mutex
+	failures int
+	err      error
+}
+
+func newProber(
+	logger logging.Logger,
+	client Client,
+	interval, timeout time.Duration,
+	threshold int,
+	onUnhealthy func(),
+) *prober {
+	return &prober{
+		logger:      logger,
+		client:      client,
+		interval:    interval,
+		timeout:     timeout,
+		threshold:   threshold,
+		onUnhealthy: onUnhealthy,
+		stop:        make(chan struct{}),
+	}
+}
+
+func (p *prober) start() {
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if !p.check() {
+					return
+				}
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop doesn't wait for a check in progress, as the prober is often stopped by the restart that
+// it triggered itself.
+func (p *prober) Stop() {
+	close(p.stop)
+}
+
+// check performs a single health check. It returns false if there is no point in checking again
+// because the SDK doesn't support health checks.
+func (p *prober) check() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	err := p.client.Check(ctx)
+	if errors.Is(err, transport.HealthCheckUnsupported) {
+		p.logger.Warnf("The SDK does not support health checks, please upgrade it")
+		return false
+	}
+	p.mutex.Lock()
+	if err == nil {
+		p.failures, p.err = 0, nil
+	} else {
+		p.failures, p.err = p.failures+1, err
+	}
+	failures := p.failures
+	p.mutex.Unlock()
+	if err == nil {
+		return true
+	}
+	p.logger.Warnf("Health check failed (%d/%d): %v", failures, p.threshold, err)
+	if failures >= p.threshold {
+		select {
+		case <-p.stop:
+			// The process was stopped while it was being checked.
+		default:
+			p.onUnhealthy()
+		}
+	}
+	return true
+}
+
+func (p *prober) status() admin.ProbeStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	status := admin.ProbeStatus{Failures: p.failures, Unhealthy: p.failures >= p.threshold}
+	if p.err != nil {
+		status.Error = p.err.Error()
+	}
+	return status
+}
+
+// startProbing starts checking the health of the process behind the client, replacing the
+// prober for the previous process. It does nothing if health checks aren't enabled.
+func (r *Runtime) startProbing(client Client) {
+	if r.healthInterval == 0 {
+		return
+	}
+	p := newProber(
+		r.logger,
+		client,
+		r.healthInterval,
+		r.healthTimeout,
+		r.healthThreshold,
+		func() { r.Emit(UNHEALTHY) },
+	)
+	r.probeMutex.Lock()
+	previous := r.prober
+	r.prober = p
+	r.probeMutex.Unlock()
+	if previous != nil {
+		previous.Stop()
+	}
+	p.start()
+}
+
+func (r *Runtime) stopProbing() {
+	r.probeMutex.Lock()
+	defer r.probeMutex.Unlock()
+	if r.prober != nil {
+		r.prober.Stop()
+		r.prober = nil
+	}
+}
+
+// probeStatus reports the result of the health checks of the current process to the admin routes.
+func (r *Runtime) probeStatus() admin.ProbeStatus {
+	r.probeMutex.Lock()
+	defer r.probeMutex.Unlock()
+	if r.prober == nil {
+		return admin.ProbeStatus{}
+	}
+	return r.prober.status()
+}
@@ -0,0 +1,137 @@
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewFileStore creates a store which keeps each entry in its own file in the directory, creating
+// the directory if it doesn't exist.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// FileStore keeps the entries in files named after a hash of their key. Entries are written to a
+// temporary file first so that they are never seen half written, which also makes it safe for
+// several runtimes to share the directory.
+type FileStore struct {
+	dir string
+
+	mutex     sync.Mutex
+	lastSweep time.Time
+}
+
+func (s *FileStore) Reserve(key string, entry *Entry, now time.Time) (*Entry, error) {
+	s.mutex.Lock()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.lastSweep = now
+		go s.sweep(now)
+	}
+	s.mutex.Unlock()
+	path := s.path(key)
+	// An expired entry is removed and the reservation tried again. If it fails a second time,
+	// another runtime got there first.
+	for attempt := 0; ; attempt++ {
+		tmp, err := s.writeTemp(entry)
+		if err != nil {
+			return nil, err
+		}
+		// Unlike a rename, a link fails if the entry already exists.
+		err = os.Link(tmp, path)
+		os.Remove(tmp)
+		if err == nil {
+			return nil, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		existing, err := s.read(path)
+		if os.IsNotExist(err) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !existing.expired(now) || attempt > 0 {
+			return existing, nil
+		}
+		if err := s.Delete(key); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *FileStore) Save(key string, entry *Entry) error {
+	tmp, err := s.writeTemp(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (s *FileStore) writeTemp(entry *Entry) (string, error) {
+	f, err := ioutil.TempFile(s.dir, ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err := json.NewEncoder(f).Encode(entry); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+func (s *FileStore) read(path string) (*Entry, error) {
+	bs, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	entry := &Entry{}
+	if err := json.Unmarshal(bs, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (s *FileStore) sweep(now time.Time) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(s.dir, file.Name())
+		if entry, err := s.read(path); err == nil && entry.expired(now) {
+			os.Remove(path)
+		}
+	}
+}
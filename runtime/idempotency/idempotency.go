@@ -0,0 +1,149 @@
+// Package idempotency makes it safe for clients to retry unsafe requests to routes which opt in
+// through the manifest. The first response to a request with an Idempotency-Key header is stored
+// and sent in response to any retries, so the service only processes the request once.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+const (
+	// Header carries the key the client picked for the request, usually a random UUID.
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses which were stored rather than sent by the service.
+	ReplayedHeader = "Idempotent-Replayed"
+	// MaxKeyLength is the longest key which is accepted.
+	MaxKeyLength = 255
+	// DefaultTTL is how long responses are stored when the policy doesn't say.
+	DefaultTTL = 24 * time.Hour
+)
+
+// How long a key is held for while the request is processed. It only matters if the runtime
+// stops before the request completes, otherwise the key is released or the response stored.
+const lockTimeout = 5 * time.Minute
+
+var (
+	InProgress = errors.New("a request with the same idempotency key is in progress")
+	InvalidKey = fmt.Errorf("the idempotency key must be at most %d characters", MaxKeyLength)
+	KeyReused  = errors.New("the idempotency key was used for a request with a different body")
+)
+
+// NewKeeper creates a keeper which stores the responses in the given store.
+func NewKeeper(store Store) *Keeper {
+	return &Keeper{store: store, now: time.Now}
+}
+
+type Keeper struct {
+	store Store
+	now   func() time.Time
+}
+
+// Claim claims the idempotency key of the request. If a response to the request has been stored
+// already then it is returned instead, and if the request is still being processed then
+// InProgress is returned. Keys are scoped to the caller, which is the authenticated subject or,
+// without one, the Authorization header, along with the method and path. Reusing a key for a
+// request with a different body returns KeyReused.
+// A nil claim is returned for requests without a key or to routes without an idempotency policy.
+func (k *Keeper) Claim(
+	route *manifest.Route,
+	r *http.Request,
+	subject string,
+) (*Claim, *Response, error) {
+	key := r.Header.Get(Header)
+	if route.Idempotency == nil || key == "" {
+		return nil, nil, nil
+	}
+	if len(key) > MaxKeyLength {
+		return nil, nil, InvalidKey
+	}
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, nil, err
+		}
+		// The body has to be put back so that it can be forwarded to the service.
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	key = fmt.Sprintf("%s %s|%s|%s", r.Method, r.URL.Path, caller(r, subject), key)
+	fingerprint := fmt.Sprintf("%x", sha256.Sum256(body))
+	now := k.now()
+	entry := &Entry{Fingerprint: fingerprint, Expires: now.Add(lockTimeout)}
+	existing, err := k.store.Reserve(key, entry, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if existing != nil {
+		if existing.Fingerprint != fingerprint {
+			return nil, nil, KeyReused
+		}
+		if existing.Response == nil {
+			return nil, nil, InProgress
+		}
+		return nil, existing.Response, nil
+	}
+	ttl := DefaultTTL
+	if route.Idempotency.Ttl > 0 {
+		ttl = time.Duration(route.Idempotency.Ttl) * time.Second
+	}
+	return &Claim{keeper: k, key: key, fingerprint: fingerprint, ttl: ttl}, nil, nil
+}
+
+// caller identifies who sent the request, so that one client can't be sent the response to
+// another's request by guessing its key. The Authorization header is hashed so that credentials
+// aren't kept in the store.
+func caller(r *http.Request, subject string) string {
+	if subject != "" {
+		return "sub:" + subject
+	}
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return fmt.Sprintf("auth:%x", sha256.Sum256([]byte(auth)))
+	}
+	return "anonymous"
+}
+
+// Claim is held while a request with an idempotency key is processed. It must either be completed
+// with the response or released. Its methods do nothing on a nil claim.
+type Claim struct {
+	keeper      *Keeper
+	key         string
+	fingerprint string
+	ttl         time.Duration
+	done        bool
+}
+
+// Complete stores the response for retries of the request. Responses with a 5xx status aren't
+// stored, as a retry may well succeed, and the key is released instead.
+func (c *Claim) Complete(res *Response) error {
+	if c == nil || c.done {
+		return nil
+	}
+	if res.Status >= 500 {
+		return c.Release()
+	}
+	c.done = true
+	entry := &Entry{
+		Fingerprint: c.fingerprint,
+		Response:    res,
+		Expires:     c.keeper.now().Add(c.ttl),
+	}
+	return c.keeper.store.Save(c.key, entry)
+}
+
+// Release gives up the key without storing a response so that the request can be retried. It
+// does nothing once the claim has been completed.
+func (c *Claim) Release() error {
+	if c == nil || c.done {
+		return nil
+	}
+	c.done = true
+	return c.keeper.store.Delete(c.key)
+}
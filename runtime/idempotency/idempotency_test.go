@@ -0,0 +1,224 @@
+package idempotency_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/idempotency"
+)
+
+var route = &manifest.Route{
+	HttpMethod:  manifest.FoldHTTPMethod_POST,
+	Route:       "/orders",
+	Idempotency: &manifest.IdempotencyPolicy{Ttl: 60},
+}
+
+func stores(t *testing.T) map[string]idempotency.Store {
+	file, err := idempotency.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	return map[string]idempotency.Store{
+		"Memory": idempotency.NewMemoryStore(),
+		"File":   file,
+	}
+}
+
+func TestClaim(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			keeper := idempotency.NewKeeper(store)
+
+			claim, stored, err := keeper.Claim(route, mkrequest("key", "{}"), "")
+			if err != nil || claim == nil || stored != nil {
+				t.Fatalf("Expected to claim the key but found %v, %v, %v", claim, stored, err)
+			}
+
+			// A retry while the first request is processed is turned away.
+			if _, _, err = keeper.Claim(route, mkrequest("key", "{}"), ""); err != idempotency.InProgress {
+				t.Errorf("Expected the request to be in progress but found %v", err)
+			}
+
+			res := &idempotency.Response{
+				Status:  201,
+				Headers: map[string][]string{"Content-Type": {"application/json"}},
+				Body:    []byte(`{"id":1}`),
+			}
+			if err := claim.Complete(res); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			claim, stored, err = keeper.Claim(route, mkrequest("key", "{}"), "")
+			if err != nil || claim != nil {
+				t.Fatalf("Expected the stored response but found %v, %v", claim, err)
+			}
+			testutils.Diff(t, res, stored, "Stored response did not match")
+
+			// The same key can't be used for a different request.
+			_, _, err = keeper.Claim(route, mkrequest("key", `{"a":1}`), "")
+			if err != idempotency.KeyReused {
+				t.Errorf("Expected the key to be reused but found %v", err)
+			}
+		})
+	}
+}
+
+func TestKeysAreScopedToTheCaller(t *testing.T) {
+	keeper := idempotency.NewKeeper(idempotency.NewMemoryStore())
+	claim, _, err := keeper.Claim(route, mkrequest("key", "{}"), "alice")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := claim.Complete(&idempotency.Response{Status: 201}); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	withAuth := func(auth string) *http.Request {
+		req := mkrequest("key", "{}")
+		req.Header.Set("Authorization", auth)
+		return req
+	}
+	cases := []struct {
+		name    string
+		req     *http.Request
+		subject string
+		stored  bool
+	}{
+		{"Same subject", mkrequest("key", "{}"), "alice", true},
+		{"Other subject", mkrequest("key", "{}"), "bob", false},
+		{"No subject", mkrequest("key", "{}"), "", false},
+		{"Authorization header", withAuth("Bearer a"), "", false},
+		{"Same authorization header", withAuth("Bearer a"), "", true},
+		{"Other authorization header", withAuth("Bearer b"), "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claim, stored, err := keeper.Claim(route, tc.req, tc.subject)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if (stored != nil) != tc.stored {
+				t.Errorf("Expected a stored response to be %v but found %v", tc.stored, stored)
+			}
+			claim.Complete(&idempotency.Response{Status: 201})
+		})
+	}
+}
+
+func TestServerErrorsAreNotStored(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			keeper := idempotency.NewKeeper(store)
+			claim, _, err := keeper.Claim(route, mkrequest("key", "{}"), "")
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if err := claim.Complete(&idempotency.Response{Status: 503}); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			claim, stored, err := keeper.Claim(route, mkrequest("key", "{}"), "")
+			if err != nil || claim == nil || stored != nil {
+				t.Fatalf("Expected to claim the key again but found %v, %v, %v", claim, stored, err)
+			}
+		})
+	}
+}
+
+func TestReleasedKeysCanBeClaimed(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			keeper := idempotency.NewKeeper(store)
+			claim, _, _ := keeper.Claim(route, mkrequest("key", "{}"), "")
+			if err := claim.Release(); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			claim, _, err := keeper.Claim(route, mkrequest("key", "{}"), "")
+			if err != nil || claim == nil {
+				t.Fatalf("Expected to claim the key again but found %v, %v", claim, err)
+			}
+		})
+	}
+}
+
+func TestRequestsWithoutAClaim(t *testing.T) {
+	keeper := idempotency.NewKeeper(idempotency.NewMemoryStore())
+	cases := []struct {
+		name  string
+		route *manifest.Route
+		req   *http.Request
+		err   error
+	}{
+		{"No key", route, mkrequest("", "{}"), nil},
+		{"No policy", &manifest.Route{Route: "/orders"}, mkrequest("key", "{}"), nil},
+		{"Long key", route, mkrequest(strings.Repeat("k", 256), "{}"), idempotency.InvalidKey},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			claim, stored, err := keeper.Claim(tc.route, tc.req, "")
+			if claim != nil || stored != nil {
+				t.Errorf("Expected no claim or stored response")
+			}
+			if err != tc.err {
+				t.Errorf("Expected %v but found %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestTheBodyIsRestored(t *testing.T) {
+	keeper := idempotency.NewKeeper(idempotency.NewMemoryStore())
+	req := mkrequest("key", `{"a":1}`)
+	if _, _, err := keeper.Claim(route, req, ""); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	body, _ := ioutil.ReadAll(req.Body)
+	testutils.Diff(t, `{"a":1}`, string(body), "Body did not match")
+}
+
+func TestExpiredEntriesAreReplaced(t *testing.T) {
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			expired := &idempotency.Entry{
+				Response: &idempotency.Response{Status: 200},
+				Expires:  now.Add(-time.Second),
+			}
+			if err := store.Save("key", expired); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			existing, err := store.Reserve("key", &idempotency.Entry{Expires: now.Add(time.Minute)}, now)
+			if err != nil || existing != nil {
+				t.Fatalf("Expected to reserve the key but found %v, %v", existing, err)
+			}
+		})
+	}
+}
+
+func TestFileStoreIsShared(t *testing.T) {
+	dir := t.TempDir()
+	first, _ := idempotency.NewFileStore(dir)
+	second, _ := idempotency.NewFileStore(dir)
+	claim, _, err := idempotency.NewKeeper(first).Claim(route, mkrequest("key", "{}"), "")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	claim.Complete(&idempotency.Response{Status: 200, Body: []byte("ok")})
+
+	_, stored, err := idempotency.NewKeeper(second).Claim(route, mkrequest("key", "{}"), "")
+	if err != nil || stored == nil {
+		t.Fatalf("Expected the stored response but found %v, %v", stored, err)
+	}
+	testutils.Diff(t, "ok", string(stored.Body), "Body did not match")
+}
+
+func mkrequest(key, body string) *http.Request {
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	return req
+}
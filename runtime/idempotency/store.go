@@ -0,0 +1,91 @@
+package idempotency
+
+import (
+	"sync"
+	"time"
+)
+
+// Store holds the responses to requests with an idempotency key. The in memory store is used by
+// default, the file store keeps them across restarts of the runtime and can be shared by several
+// instances of a service through a shared volume.
+type Store interface {
+	// Reserve stores the entry for the key, unless there is already an entry for it which hasn't
+	// expired. In that case the existing entry is returned instead, and nil is returned if the
+	// key was reserved. This must be atomic.
+	Reserve(key string, entry *Entry, now time.Time) (*Entry, error)
+	// Save replaces the entry for the key.
+	Save(key string, entry *Entry) error
+	// Delete removes the entry for the key, if there is one.
+	Delete(key string) error
+}
+
+// Entry is what the store holds for a key.
+type Entry struct {
+	// The SHA-256 hash of the body of the request which claimed the key.
+	Fingerprint string `json:"fingerprint"`
+	// The response is nil while the first request with the key is being processed.
+	Response *Response `json:"response,omitempty"`
+	Expires  time.Time `json:"expires"`
+}
+
+// Response is a stored response.
+type Response struct {
+	Status  int                 `json:"status"`
+	Headers map[string][]string `json:"headers"`
+	Body    []byte              `json:"body"`
+}
+
+func (e *Entry) expired(now time.Time) bool {
+	return !now.Before(e.Expires)
+}
+
+// sweepInterval is how often the stores look for expired entries to remove.
+const sweepInterval = time.Minute
+
+// NewMemoryStore creates a store which keeps the entries in memory. Expired entries are removed
+// periodically.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+type MemoryStore struct {
+	mutex     sync.Mutex
+	entries   map[string]*Entry
+	lastSweep time.Time
+}
+
+func (s *MemoryStore) Reserve(key string, entry *Entry, now time.Time) (*Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+	if existing, ok := s.entries[key]; ok && !existing.expired(now) {
+		return existing, nil
+	}
+	s.entries[key] = entry
+	return nil, nil
+}
+
+func (s *MemoryStore) Save(key string, entry *Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[key] = entry
+	return nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, entry := range s.entries {
+		if entry.expired(now) {
+			delete(s.entries, key)
+		}
+	}
+	s.lastSweep = now
+}
@@ -16,6 +16,20 @@ type Client struct {
 	mock.Mock
 }
 
+// Check provides a mock function with given fields: _a0
+func (_m *Client) Check(_a0 context.Context) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // DoRequest provides a mock function with given fields: _a0, _a1
 func (_m *Client) DoRequest(_a0 context.Context, _a1 *transport.Request) (*transport.Response, error) {
 	ret := _m.Called(_a0, _a1)
@@ -39,6 +53,29 @@ func (_m *Client) DoRequest(_a0 context.Context, _a1 *transport.Request) (*trans
 	return r0, r1
 }
 
+// Initialize provides a mock function with given fields: _a0, _a1
+func (_m *Client) Initialize(_a0 context.Context, _a1 transport.Handshake) (*transport.Session, error) {
+	ret := _m.Called(_a0, _a1)
+
+	var r0 *transport.Session
+	if rf, ok := ret.Get(0).(func(context.Context, transport.Handshake) *transport.Session); ok {
+		r0 = rf(_a0, _a1)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*transport.Session)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, transport.Handshake) error); ok {
+		r1 = rf(_a0, _a1)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetManifest provides a mock function with given fields: _a0
 func (_m *Client) GetManifest(_a0 context.Context) (*manifest.Manifest, error) {
 	ret := _m.Called(_a0)
@@ -15,8 +15,17 @@ type Router struct {
 }
 
 // Configure provides a mock function with given fields: _a0
-func (_m *Router) Configure(_a0 *manifest.Manifest) {
-	_m.Called(_a0)
+func (_m *Router) Configure(_a0 *manifest.Manifest) error {
+	ret := _m.Called(_a0)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(*manifest.Manifest) error); ok {
+		r0 = rf(_a0)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
 // ServeHTTP provides a mock function with given fields: _a0, _a1
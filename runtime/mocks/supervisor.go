@@ -6,6 +6,8 @@ import (
 	os "os"
 
 	mock "github.com/stretchr/testify/mock"
+
+	supervisor "github.com/foldsh/fold/runtime/supervisor"
 )
 
 // Supervisor is an autogenerated mock type for the Supervisor type
@@ -69,6 +71,29 @@ func (_m *Supervisor) Start(env map[string]string) error {
 	return r0
 }
 
+// Stats provides a mock function with given fields:
+func (_m *Supervisor) Stats() (*supervisor.ProcessStats, error) {
+	ret := _m.Called()
+
+	var r0 *supervisor.ProcessStats
+	if rf, ok := ret.Get(0).(func() *supervisor.ProcessStats); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*supervisor.ProcessStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func() error); ok {
+		r1 = rf()
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Stop provides a mock function with given fields:
 func (_m *Supervisor) Stop() error {
 	ret := _m.Called()
@@ -1,10 +1,19 @@
 package runtime
 
 import (
+	"net"
 	"time"
 
 	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/admin"
+	"github.com/foldsh/fold/runtime/auth"
+	"github.com/foldsh/fold/runtime/cache"
+	"github.com/foldsh/fold/runtime/concurrency"
+	"github.com/foldsh/fold/runtime/debugger"
 	"github.com/foldsh/fold/runtime/fsm"
+	"github.com/foldsh/fold/runtime/idempotency"
+	"github.com/foldsh/fold/runtime/ratelimit"
+	"github.com/foldsh/fold/runtime/recorder"
 	"github.com/foldsh/fold/runtime/watcher"
 )
 
@@ -12,8 +21,17 @@ type Option func(*Runtime)
 
 type HandlerT uint8
 
+// WithEnv sets environment variables for the process, on top of those of the runtime. It can be
+// used more than once.
 func WithEnv(env map[string]string) Option {
-	return func(r *Runtime) {}
+	return func(r *Runtime) {
+		if r.env == nil {
+			r.env = map[string]string{}
+		}
+		for key, value := range env {
+			r.env[key] = value
+		}
+	}
 }
 
 func WithSupervisor(supervisor Supervisor) Option {
@@ -22,12 +40,28 @@ func WithSupervisor(supervisor Supervisor) Option {
 	}
 }
 
+// WithSupervisorFactory sets how the supervisor for a new process is created when the service is
+// reloaded with the BLUE_GREEN strategy.
+func WithSupervisorFactory(supervisorFactory SupervisorFactory) Option {
+	return func(r *Runtime) {
+		r.supervisorFactory = supervisorFactory
+	}
+}
+
 func WithClient(client Client) Option {
 	return func(r *Runtime) {
 		r.client = client
 	}
 }
 
+// WithClientFactory sets how the client for a new process is created when the service is
+// reloaded with the BLUE_GREEN strategy.
+func WithClientFactory(clientFactory ClientFactory) Option {
+	return func(r *Runtime) {
+		r.clientFactory = clientFactory
+	}
+}
+
 func WithSocketFactory(socketFactory SocketFactory) Option {
 	return func(r *Runtime) {
 		r.socketFactory = socketFactory
@@ -43,7 +77,7 @@ func WithRouterFactory(routerFactory RouterFactory) Option {
 func WithDefaultRouter(router Router) Option {
 	return func(r *Runtime) {
 		r.defaultRouter = router
-		r.router = r.defaultRouter
+		r.setRouter(r.defaultRouter)
 	}
 }
 
@@ -53,21 +87,139 @@ func OnProcessEnd(handler func()) Option {
 	}
 }
 
-func WatchDir(frequency time.Duration, dir string) Option {
+// ManifestTimeout sets how long the runtime waits for the service to complete the handshake, and
+// then to return its manifest, after the process has been started.
+func ManifestTimeout(timeout time.Duration) Option {
 	return func(r *Runtime) {
-		debouncer := watcher.NewDebouncer(frequency, func() {
-			r.Emit(FILE_CHANGE)
-		})
-		watcher, err := watcher.NewWatcher(r.logger, dir, debouncer.OnChange)
-		if err != nil {
-			r.logger.Fatalf("Failed to setup hot reloading")
-		}
-		if err := watcher.Watch(); err != nil {
-			r.logger.Fatalf("Failed to setup hot reloading")
+		r.manifestTimeout = timeout
+	}
+}
+
+// ServiceName sets the name of the service, which is passed to the SDK in the handshake.
+func ServiceName(name string) Option {
+	return func(r *Runtime) {
+		r.handshake.ServiceName = name
+	}
+}
+
+// Stage sets the stage the service is running in, which is passed to the SDK in the handshake.
+func Stage(stage string) Option {
+	return func(r *Runtime) {
+		r.handshake.Stage = stage
+	}
+}
+
+// AdminToken protects the admin routes, other than the health checks, with a bearer token.
+func AdminToken(token string) Option {
+	return func(r *Runtime) {
+		admin.WithToken(token)(r.admin)
+	}
+}
+
+// RateLimitStore sets the store used to hold the rate limit buckets. By default they are kept
+// in memory.
+func RateLimitStore(store ratelimit.Store) Option {
+	return func(r *Runtime) {
+		r.rateLimitStore = store
+	}
+}
+
+// TrustedProxies sets the networks of the proxies in front of the runtime, such as a load
+// balancer. Rate limits use the X-Forwarded-For header they add to tell clients apart.
+func TrustedProxies(networks ...*net.IPNet) Option {
+	return func(r *Runtime) {
+		r.trustedProxies = networks
+	}
+}
+
+// IdempotencyStore sets the store used to hold the responses to requests with an idempotency
+// key. By default they are kept in memory.
+func IdempotencyStore(store idempotency.Store) Option {
+	return func(r *Runtime) {
+		r.idempotency = idempotency.NewKeeper(store)
+	}
+}
+
+// Authenticator sets the authenticator used to verify bearer tokens for routes which require
+// authentication.
+func Authenticator(authenticator *auth.Authenticator) Option {
+	return func(r *Runtime) {
+		r.authenticator = authenticator
+	}
+}
+
+// CacheSize sets the number of responses the runtime caches for routes with a cache policy.
+func CacheSize(size int) Option {
+	return func(r *Runtime) {
+		r.cache = cache.NewLRU(size)
+	}
+}
+
+// ConcurrencyLimit limits the number of requests sent to the service at once. Requests over the
+// limit are queued, and rejected with a 503 when the queue is full. The options add limits for
+// individual routes and make the limits adaptive.
+func ConcurrencyLimit(settings concurrency.Settings, options ...concurrency.Option) Option {
+	return func(r *Runtime) {
+		r.concurrency = concurrency.NewLimiter(settings, options...)
+	}
+}
+
+// RecordRequests records every request sent to the service, along with its response, so that
+// the traffic can be replayed later. The recording is closed when the runtime exits.
+func RecordRequests(rec *recorder.Recorder) Option {
+	return func(r *Runtime) {
+		r.recorder = rec
+	}
+}
+
+// Debug runs the process under the debugger. The debugger listens on the same port every time
+// the process is restarted, so clients can reconnect after a reload. Blue/green reloads are
+// replaced by restarts while debugging.
+func Debug(d *debugger.Debugger) Option {
+	return func(r *Runtime) {
+		r.debugger = d
+	}
+}
+
+type PublicAdminT uint8
+
+const (
+	ALL_ADMIN PublicAdminT = iota + 1
+	HEALTH_ONLY
+	NO_ADMIN
+)
+
+// PublicAdmin sets which admin routes can be reached through the runtime itself, rather than
+// through a dedicated admin listener serving Runtime.Admin(). By default only the health checks
+// can be, as the other routes expose the internals of the service.
+func PublicAdmin(publicAdmin PublicAdminT) Option {
+	return func(r *Runtime) {
+		switch publicAdmin {
+		case ALL_ADMIN:
+			r.publicAdmin = r.admin.Shared()
+		case HEALTH_ONLY:
+			// This is the default setting so we don't need to do anything.
+			return
+		case NO_ADMIN:
+			r.publicAdmin = nil
 		}
+	}
+}
+
+// WatchDir restarts the process whenever a file in the directory changes. The options control
+// which changes are ignored, see the watcher package.
+func WatchDir(frequency time.Duration, dir string, options ...watcher.Option) Option {
+	return WatchDirs(frequency, []string{dir}, options...)
+}
+
+// WatchDirs restarts the process whenever a file in any of the directories changes. The
+// directories are watched once the runtime is started.
+func WatchDirs(frequency time.Duration, dirs []string, options ...watcher.Option) Option {
+	return func(r *Runtime) {
+		r.watch = &watchSettings{frequency: frequency, dirs: dirs, options: options}
 		r.fsm.AddTransition(fsm.Transition{FILE_CHANGE, UP, UP, []fsm.Callback{
 			func() {
-				if err := r.restartClientAndSupervisor(); err != nil {
+				if err := r.reload(); err != nil {
 					return
 				}
 			},
@@ -79,9 +231,43 @@ func WatchDir(frequency time.Duration, dir string) Option {
 				}
 			},
 		}})
-		r.fsm.OnTransitionTo(EXITED, func() {
-			watcher.Close()
-		})
+		r.fsm.OnTransitionTo(EXITED, r.stopWatching)
+	}
+}
+
+// BuildCommand sets a shell command which is run before the process is restarted by WatchDir, so
+// that compiled services can be rebuilt. If it fails the process carries on running and the
+// failure is reported by the health check.
+func BuildCommand(cmd string) Option {
+	return func(r *Runtime) {
+		r.builder = watcher.NewBuilder(r.logger, cmd)
+	}
+}
+
+// ReloadStrategy sets how the service is restarted when it is reloaded, either by START while it
+// is running or by a file change.
+func ReloadStrategy(strategy ReloadStrategyT) Option {
+	return func(r *Runtime) {
+		r.reloadStrategy = strategy
+	}
+}
+
+// DrainTimeout sets how long a BLUE_GREEN reload waits for requests to the previous process to
+// complete before stopping it.
+func DrainTimeout(timeout time.Duration) Option {
+	return func(r *Runtime) {
+		r.drainTimeout = timeout
+	}
+}
+
+// HealthCheck checks the health of the process on the given interval using the gRPC health
+// protocol. When the number of consecutive failed checks reaches the threshold the process is
+// restarted using the ReloadStrategy. Health checks are disabled by default.
+func HealthCheck(interval, timeout time.Duration, threshold int) Option {
+	return func(r *Runtime) {
+		r.healthInterval = interval
+		r.healthTimeout = timeout
+		r.healthThreshold = threshold
 	}
 }
 
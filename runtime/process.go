@@ -0,0 +1,130 @@
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/foldsh/fold/runtime/admin"
+	"github.com/foldsh/fold/runtime/supervisor"
+)
+
+// processStats reports the resources used by the current process and how many times it has been
+// restarted.
+func (r *Runtime) processStats() (*supervisor.ProcessStats, int, error) {
+	r.processMutex.Lock()
+	sup, restarts := r.supervisor, r.restarts
+	r.processMutex.Unlock()
+	stats, err := sup.Stats()
+	if err != nil {
+		return nil, restarts, err
+	}
+	stats.Restarts = restarts
+	return stats, restarts, nil
+}
+
+// processInfo reports the PID, uptime and resource usage of the service's process.
+func (r *Runtime) processInfo(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	stats, _, err := r.processStats()
+	if errors.Is(err, supervisor.NotRunning) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"title":"Service is down"}`))
+		return
+	}
+	if err != nil {
+		r.logger.Errorf("Failed to read the process stats: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"title":"Failed to read the process stats"}`))
+		return
+	}
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		r.logger.Errorf("Failed to write process stats: %v", err)
+	}
+}
+
+// metrics serves the process stats in the Prometheus text format so that they can be scraped.
+func (r *Runtime) metrics(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	stats, restarts, err := r.processStats()
+	if err != nil && !errors.Is(err, supervisor.NotRunning) {
+		r.logger.Errorf("Failed to read the process stats: %v", err)
+	}
+	up := 0.0
+	if stats != nil {
+		up = 1
+	}
+	writeMetric(w, "fold_process_up", "gauge", "Whether the service's process is running.", up)
+	writeMetric(
+		w,
+		"fold_process_restarts_total",
+		"counter",
+		"The number of times the service's process has been restarted.",
+		float64(restarts),
+	)
+	if stats == nil {
+		return
+	}
+	for _, metric := range []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"fold_process_start_time_seconds", "gauge", "Start time of the process since the unix epoch.",
+			float64(stats.Started.Unix())},
+		{"fold_process_uptime_seconds", "gauge", "How long the process has been running.",
+			stats.Uptime},
+		{"fold_process_cpu_seconds_total", "counter", "User and system CPU time used by the process.",
+			stats.CPUTime},
+		{"fold_process_resident_memory_bytes", "gauge", "Resident memory size of the process.",
+			float64(stats.RSS)},
+		{"fold_process_open_fds", "gauge", "The number of open file descriptors.",
+			float64(stats.FDs)},
+		{"fold_process_threads", "gauge", "The number of threads in the process.",
+			float64(stats.Threads)},
+	} {
+		writeMetric(w, metric.name, metric.kind, metric.help, metric.value)
+	}
+}
+
+// The number of crash reports the runtime keeps.
+const maxCrashReports = 10
+
+// recordCrash keeps the report of a crash so that it can be fetched from the admin routes.
+func (r *Runtime) recordCrash(report *supervisor.CrashReport) {
+	summary := fmt.Sprintf("exit code %d", report.ExitCode)
+	if report.Signal != "" {
+		summary = fmt.Sprintf("signal %q", report.Signal)
+	}
+	r.logger.Errorf(
+		"The service crashed with %s after %.1fs, see %s/crashes for details",
+		summary,
+		report.Uptime,
+		admin.Prefix,
+	)
+	r.processMutex.Lock()
+	defer r.processMutex.Unlock()
+	r.crashes = append([]*supervisor.CrashReport{report}, r.crashes...)
+	if len(r.crashes) > maxCrashReports {
+		r.crashes = r.crashes[:maxCrashReports]
+	}
+}
+
+// crashReports serves the reports of the most recent crashes of the service, newest first.
+func (r *Runtime) crashReports(w http.ResponseWriter, req *http.Request) {
+	r.processMutex.Lock()
+	crashes := append([]*supervisor.CrashReport{}, r.crashes...)
+	r.processMutex.Unlock()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(crashes); err != nil {
+		r.logger.Errorf("Failed to write crash reports: %v", err)
+	}
+}
+
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+	fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
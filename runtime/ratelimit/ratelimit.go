@@ -0,0 +1,177 @@
+// Package ratelimit enforces the token bucket rate limits declared on routes in a service
+// manifest. The buckets live in a Store so that they survive the service being reloaded.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+// NewLimiter creates a limiter which keeps its buckets in the given store.
+func NewLimiter(store Store, options ...Option) *Limiter {
+	l := &Limiter{store: store, now: time.Now, counters: make(map[string]*counter)}
+	for _, option := range options {
+		option(l)
+	}
+	return l
+}
+
+type Option func(*Limiter)
+
+// TrustedProxies makes the limiter use the X-Forwarded-For header to identify clients when a
+// request comes from one of the networks, e.g. a load balancer, as otherwise every client behind
+// the proxy would share its address.
+func TrustedProxies(networks ...*net.IPNet) Option {
+	return func(l *Limiter) {
+		l.trustedProxies = append(l.trustedProxies, networks...)
+	}
+}
+
+type Limiter struct {
+	store          Store
+	now            func() time.Time
+	trustedProxies []*net.IPNet
+
+	countersMutex sync.Mutex
+	counters      map[string]*counter
+}
+
+type counter struct {
+	allowed uint64
+	limited uint64
+}
+
+// Counter reports how many requests to a route have been allowed and how many were limited.
+type Counter struct {
+	Route   string `json:"route"`
+	Allowed uint64 `json:"allowed"`
+	Limited uint64 `json:"limited"`
+}
+
+// Take takes a token for the request from the bucket its route and client map to. If the route
+// has no rate limit then the request is always allowed.
+func (l *Limiter) Take(route *manifest.Route, r *http.Request) Result {
+	rl := route.RateLimit
+	if rl == nil || rl.Requests == 0 || rl.Period == 0 {
+		return Result{Allowed: true}
+	}
+	name := routeName(route)
+	limit := Limit{Requests: int(rl.Requests), Period: time.Duration(rl.Period) * time.Second}
+	result := l.store.Take(fmt.Sprintf("%s|%s", name, l.clientKey(rl, r)), limit, l.now())
+	c := l.counter(name)
+	if result.Allowed {
+		atomic.AddUint64(&c.allowed, 1)
+	} else {
+		atomic.AddUint64(&c.limited, 1)
+	}
+	return result
+}
+
+// Counters returns the counters for every rate limited route which has received a request.
+func (l *Limiter) Counters() []Counter {
+	l.countersMutex.Lock()
+	defer l.countersMutex.Unlock()
+	counters := make([]Counter, 0, len(l.counters))
+	for route, c := range l.counters {
+		counters = append(counters, Counter{
+			Route:   route,
+			Allowed: atomic.LoadUint64(&c.allowed),
+			Limited: atomic.LoadUint64(&c.limited),
+		})
+	}
+	sort.Slice(counters, func(i, j int) bool { return counters[i].Route < counters[j].Route })
+	return counters
+}
+
+func (l *Limiter) counter(route string) *counter {
+	l.countersMutex.Lock()
+	defer l.countersMutex.Unlock()
+	c, ok := l.counters[route]
+	if !ok {
+		c = &counter{}
+		l.counters[route] = c
+	}
+	return c
+}
+
+// SetHeaders adds the RateLimit-* headers describing the result to the response, along with
+// Retry-After if the request was limited. It does nothing for routes without a rate limit.
+func (r Result) SetHeaders(headers http.Header) {
+	if r.Limit == 0 {
+		return
+	}
+	headers.Set("RateLimit-Limit", strconv.Itoa(r.Limit))
+	headers.Set("RateLimit-Remaining", strconv.Itoa(r.Remaining))
+	headers.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(r.Reset)))
+	if !r.Allowed {
+		headers.Set("Retry-After", strconv.Itoa(ceilSeconds(r.RetryAfter)))
+	}
+}
+
+func routeName(route *manifest.Route) string {
+	return fmt.Sprintf("%s %s", route.HttpMethod, route.Route)
+}
+
+func (l *Limiter) clientKey(rl *manifest.RateLimit, r *http.Request) string {
+	switch rl.Key {
+	case manifest.RateLimit_GLOBAL:
+		return "global"
+	case manifest.RateLimit_HEADER:
+		if value := r.Header.Get(rl.Header); value != "" {
+			return fmt.Sprintf("header:%s", value)
+		}
+	}
+	return fmt.Sprintf("ip:%s", l.clientIP(r))
+}
+
+func (l *Limiter) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !l.trusted(ip) {
+		return ip
+	}
+	// Every proxy appends the address it received the request from, so the client is the last
+	// address which wasn't added by a trusted proxy. The ones before it could have been sent by
+	// the client.
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if addr == "" {
+			continue
+		}
+		ip = addr
+		if !l.trusted(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (l *Limiter) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range l.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
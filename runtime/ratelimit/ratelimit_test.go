@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 2, Period: 2 * time.Second}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if result := store.Take("key", limit, now); !result.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i)
+		}
+	}
+	result := store.Take("key", limit, now)
+	if result.Allowed {
+		t.Fatalf("Expected the bucket to be empty")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("Expected to retry after 1s but found %v", result.RetryAfter)
+	}
+	if result.Reset != 2*time.Second {
+		t.Errorf("Expected the bucket to reset after 2s but found %v", result.Reset)
+	}
+	// Other keys have their own bucket.
+	if result := store.Take("other", limit, now); !result.Allowed {
+		t.Errorf("Expected a different key to be allowed")
+	}
+	// The bucket refills at one token per second.
+	result = store.Take("key", limit, now.Add(time.Second))
+	if !result.Allowed {
+		t.Errorf("Expected a token to be available after 1s")
+	}
+	if result.Remaining != 0 {
+		t.Errorf("Expected no tokens to remain but found %d", result.Remaining)
+	}
+}
+
+func TestMemoryStoreSweepsIdleBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Requests: 1, Period: time.Second}
+	now := time.Now()
+	store.Take("key", limit, now)
+	store.Take("other", limit, now.Add(2*sweepInterval))
+	if _, ok := store.buckets["key"]; ok {
+		t.Errorf("Expected the idle bucket to have been removed")
+	}
+}
+
+func TestLimiterKeys(t *testing.T) {
+	mkreq := func(remoteAddr, apiKey string) *http.Request {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.RemoteAddr = remoteAddr
+		if apiKey != "" {
+			r.Header.Set("X-Api-Key", apiKey)
+		}
+		return r
+	}
+	route := func(key manifest.RateLimit_Key) *manifest.Route {
+		return &manifest.Route{
+			HttpMethod: manifest.FoldHTTPMethod_GET,
+			Route:      "/foo",
+			RateLimit:  &manifest.RateLimit{Key: key, Header: "X-Api-Key", Requests: 1, Period: 60},
+		}
+	}
+	cases := []struct {
+		name     string
+		route    *manifest.Route
+		first    *http.Request
+		second   *http.Request
+		expected bool
+	}{
+		{
+			"IP limits share a bucket for the same address",
+			route(manifest.RateLimit_IP),
+			mkreq("10.0.0.1:1234", ""),
+			mkreq("10.0.0.1:5678", ""),
+			false,
+		},
+		{
+			"IP limits use a bucket per address",
+			route(manifest.RateLimit_IP),
+			mkreq("10.0.0.1:1234", ""),
+			mkreq("10.0.0.2:1234", ""),
+			true,
+		},
+		{
+			"Header limits use a bucket per value",
+			route(manifest.RateLimit_HEADER),
+			mkreq("10.0.0.1:1234", "a"),
+			mkreq("10.0.0.1:1234", "b"),
+			true,
+		},
+		{
+			"Header limits fall back to the address",
+			route(manifest.RateLimit_HEADER),
+			mkreq("10.0.0.1:1234", ""),
+			mkreq("10.0.0.1:1234", ""),
+			false,
+		},
+		{
+			"Global limits share a single bucket",
+			route(manifest.RateLimit_GLOBAL),
+			mkreq("10.0.0.1:1234", "a"),
+			mkreq("10.0.0.2:1234", "b"),
+			false,
+		},
+		{
+			"Routes without a limit are always allowed",
+			&manifest.Route{HttpMethod: manifest.FoldHTTPMethod_GET, Route: "/foo"},
+			mkreq("10.0.0.1:1234", ""),
+			mkreq("10.0.0.1:1234", ""),
+			true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			limiter := NewLimiter(NewMemoryStore())
+			if !limiter.Take(tc.route, tc.first).Allowed {
+				t.Fatalf("Expected the first request to be allowed")
+			}
+			if allowed := limiter.Take(tc.route, tc.second).Allowed; allowed != tc.expected {
+				t.Errorf("Expected allowed to be %t but found %t", tc.expected, allowed)
+			}
+		})
+	}
+}
+
+func TestLimiterTrustedProxies(t *testing.T) {
+	_, proxies, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	limiter := NewLimiter(NewMemoryStore(), TrustedProxies(proxies))
+	route := &manifest.Route{
+		HttpMethod: manifest.FoldHTTPMethod_GET,
+		Route:      "/foo",
+		RateLimit:  &manifest.RateLimit{Key: manifest.RateLimit_IP, Requests: 1, Period: 60},
+	}
+	mkreq := func(remoteAddr string, forwardedFor ...string) *http.Request {
+		r := httptest.NewRequest("GET", "/foo", nil)
+		r.RemoteAddr = remoteAddr
+		for _, value := range forwardedFor {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		return r
+	}
+	cases := []struct {
+		name     string
+		req      *http.Request
+		expected bool
+	}{
+		{"Clients behind a trusted proxy get a bucket", mkreq("10.0.0.1:1234", "1.1.1.1"), true},
+		{"Clients behind two proxies get a bucket", mkreq("10.0.0.1:1234", "2.2.2.2, 10.0.0.2"), true},
+		{"The same client shares its bucket", mkreq("10.0.0.2:1234", "1.1.1.1"), false},
+		{"Addresses before the client are ignored", mkreq("10.0.0.1:1234", "3.3.3.3", "1.1.1.1"), false},
+		{"The header is ignored from other addresses", mkreq("4.4.4.4:1234", "5.5.5.5"), true},
+		{"Other addresses can't pick a bucket", mkreq("6.6.6.6:1234", "4.4.4.4"), true},
+	}
+	for _, tc := range cases {
+		if allowed := limiter.Take(route, tc.req).Allowed; allowed != tc.expected {
+			t.Errorf("%s: expected allowed to be %t but found %t", tc.name, tc.expected, allowed)
+		}
+	}
+}
+
+func TestLimiterCounters(t *testing.T) {
+	limiter := NewLimiter(NewMemoryStore())
+	route := &manifest.Route{
+		HttpMethod: manifest.FoldHTTPMethod_GET,
+		Route:      "/foo",
+		RateLimit:  &manifest.RateLimit{Key: manifest.RateLimit_GLOBAL, Requests: 1, Period: 60},
+	}
+	for i := 0; i < 3; i++ {
+		limiter.Take(route, httptest.NewRequest("GET", "/foo", nil))
+	}
+	counters := limiter.Counters()
+	expected := Counter{Route: "GET /foo", Allowed: 1, Limited: 2}
+	if len(counters) != 1 || counters[0] != expected {
+		t.Errorf("Expected %+v but found %+v", expected, counters)
+	}
+}
+
+func TestResultHeaders(t *testing.T) {
+	headers := http.Header{}
+	Result{
+		Allowed:    false,
+		Limit:      10,
+		Remaining:  0,
+		Reset:      5500 * time.Millisecond,
+		RetryAfter: 500 * time.Millisecond,
+	}.SetHeaders(headers)
+	expected := map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "6",
+		"Retry-After":         "1",
+	}
+	for key, value := range expected {
+		if actual := headers.Get(key); actual != value {
+			t.Errorf("Expected %s to be %s but found %s", key, value, actual)
+		}
+	}
+}
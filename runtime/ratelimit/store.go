@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Store holds the token buckets for every client. The in memory store is used by default, the
+// interface exists so that buckets can be shared between several instances of a service.
+type Store interface {
+	// Take removes a token from the bucket identified by key, creating a full bucket if it
+	// doesn't exist yet.
+	Take(key string, limit Limit, now time.Time) Result
+}
+
+// Limit describes the size of a bucket and how quickly it refills.
+type Limit struct {
+	// The capacity of the bucket.
+	Requests int
+	// How long it takes for an empty bucket to refill completely.
+	Period time.Duration
+}
+
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// Result is the outcome of trying to take a token from a bucket.
+type Result struct {
+	Allowed bool
+	// The capacity of the bucket.
+	Limit int
+	// The number of whole tokens left in the bucket.
+	Remaining int
+	// How long until the bucket is full again.
+	Reset time.Duration
+	// How long until a token is available. It is zero when the request was allowed.
+	RetryAfter time.Duration
+}
+
+// NewMemoryStore creates a store which keeps the buckets in memory. Buckets which have been idle
+// long enough to refill completely are removed periodically.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+type MemoryStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// sweepInterval is how often the memory store looks for idle buckets to remove.
+const sweepInterval = time.Minute
+
+func (s *MemoryStore) Take(key string, limit Limit, now time.Time) Result {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now.Sub(s.lastSweep) > sweepInterval {
+		s.sweep(now)
+	}
+	b, ok := s.buckets[key]
+	if !ok || b.limit != limit {
+		// The limit can change when the service is reloaded, in which case we start afresh.
+		b = &bucket{tokens: float64(limit.Requests), last: now, limit: limit}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+	result := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / limit.rate())
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = seconds((float64(limit.Requests) - b.tokens) / limit.rate())
+	return result
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.last) >= b.limit.Period {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens = math.Min(float64(b.limit.Requests), b.tokens+elapsed*b.limit.rate())
+	b.last = now
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
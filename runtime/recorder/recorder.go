@@ -0,0 +1,320 @@
+// Package recorder records the requests the runtime sends to the service, along with the
+// responses and how long they took, so that the traffic can be replayed later with foldctl
+// replay. Entries are written to a JSON lines file which is rotated when it gets too large.
+package recorder
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/router"
+	"github.com/foldsh/fold/runtime/transport"
+)
+
+// Redacted replaces the values of redacted headers and fields.
+const Redacted = "[REDACTED]"
+
+// DefaultRedactHeaders are always redacted as they hold credentials.
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+// Entry is a single line of the recording.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// The name of the service which handled the request.
+	Service    string              `json:"service,omitempty"`
+	DurationMS float64             `json:"duration_ms"`
+	Request    *transport.Request  `json:"request"`
+	Response   *transport.Response `json:"response,omitempty"`
+	// Set when the service couldn't be reached, in which case there is no response.
+	Error string `json:"error,omitempty"`
+}
+
+type Option func(*Recorder)
+
+// MaxSize sets the size in bytes at which the file is rotated. It is 10MB by default.
+func MaxSize(size int64) Option {
+	return func(r *Recorder) {
+		r.maxSize = size
+	}
+}
+
+// MaxFiles sets how many rotated files are kept alongside the current one, e.g. with a file
+// named requests.jsonl the previous one is requests.jsonl.1. It is 5 by default.
+func MaxFiles(files int) Option {
+	return func(r *Recorder) {
+		r.maxFiles = files
+	}
+}
+
+// Service sets the name of the service recorded with each entry.
+func Service(name string) Option {
+	return func(r *Recorder) {
+		r.service = name
+	}
+}
+
+// RedactHeaders redacts the headers, on both requests and responses, in addition to
+// DefaultRedactHeaders.
+func RedactHeaders(headers ...string) Option {
+	return func(r *Recorder) {
+		for _, h := range headers {
+			r.redactHeaders[http.CanonicalHeaderKey(h)] = struct{}{}
+		}
+	}
+}
+
+// RedactFields redacts the fields with any of the given names, at any depth, in JSON bodies.
+func RedactFields(fields ...string) Option {
+	return func(r *Recorder) {
+		for _, f := range fields {
+			r.redactFields[f] = struct{}{}
+		}
+	}
+}
+
+// Recorder writes an entry for every request sent through the doers it wraps.
+type Recorder struct {
+	logger        logging.Logger
+	path          string
+	service       string
+	maxSize       int64
+	maxFiles      int
+	redactHeaders map[string]struct{}
+	redactFields  map[string]struct{}
+
+	mutex sync.Mutex
+	file  *os.File
+	buf   *bufio.Writer
+	size  int64
+}
+
+func NewRecorder(logger logging.Logger, path string, options ...Option) (*Recorder, error) {
+	r := &Recorder{
+		logger:        logger,
+		path:          path,
+		maxSize:       10 * 1024 * 1024,
+		maxFiles:      5,
+		redactHeaders: map[string]struct{}{},
+		redactFields:  map[string]struct{}{},
+	}
+	RedactHeaders(DefaultRedactHeaders...)(r)
+	for _, option := range options {
+		option(r)
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Wrap returns a RequestDoer which records every request it sends to the doer.
+func (r *Recorder) Wrap(doer router.RequestDoer) router.RequestDoer {
+	return &recordingDoer{recorder: r, doer: doer}
+}
+
+// Close flushes the recording and closes the file.
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.buf.Flush(); err != nil {
+		return err
+	}
+	return r.file.Close()
+}
+
+type recordingDoer struct {
+	recorder *Recorder
+	doer     router.RequestDoer
+}
+
+func (rd *recordingDoer) DoRequest(
+	ctx context.Context,
+	req *transport.Request,
+) (*transport.Response, error) {
+	start := time.Now()
+	res, err := rd.doer.DoRequest(ctx, req)
+	entry := &Entry{
+		Time:       start.UTC(),
+		Service:    rd.recorder.service,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+		Request:    rd.recorder.redactRequest(req),
+		Response:   rd.recorder.redactResponse(res),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if err := rd.recorder.write(entry); err != nil {
+		rd.recorder.logger.Errorf("Failed to record request: %v", err)
+	}
+	return res, err
+}
+
+func (r *Recorder) write(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.size > 0 && r.size+int64(len(line)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.buf.Write(line)
+	r.size += int64(n)
+	if err != nil {
+		return err
+	}
+	// Each entry is flushed so that the recording is complete if the runtime is killed.
+	return r.buf.Flush()
+}
+
+func (r *Recorder) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the recording file: %w", err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open the recording file: %w", err)
+	}
+	r.file, r.buf, r.size = file, bufio.NewWriter(file), fi.Size()
+	return nil
+}
+
+// rotate shifts each rotated file along by one, dropping the oldest, and starts a new file. The
+// mutex must be held.
+func (r *Recorder) rotate() error {
+	if err := r.buf.Flush(); err != nil {
+		return err
+	}
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	if r.maxFiles > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.maxFiles))
+		for i := r.maxFiles - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		if err := os.Rename(r.path, r.path+".1"); err != nil {
+			return err
+		}
+	} else if err := os.Remove(r.path); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// redactRequest returns a copy of the request with the sensitive headers and fields redacted.
+func (r *Recorder) redactRequest(req *transport.Request) *transport.Request {
+	redacted := *req
+	redacted.Headers = r.redactHeaderMap(req.Headers)
+	redacted.Body = r.redactBody(req.Body)
+	if len(r.redactFields) > 0 && req.Claims != nil {
+		redacted.Claims = r.redactValue(req.Claims).(map[string]interface{})
+	}
+	return &redacted
+}
+
+func (r *Recorder) redactResponse(res *transport.Response) *transport.Response {
+	if res == nil {
+		return nil
+	}
+	redacted := *res
+	redacted.Headers = r.redactHeaderMap(res.Headers)
+	redacted.Body = r.redactBody(res.Body)
+	return &redacted
+}
+
+func (r *Recorder) redactHeaderMap(headers map[string][]string) map[string][]string {
+	if headers == nil {
+		return nil
+	}
+	redacted := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		if _, ok := r.redactHeaders[http.CanonicalHeaderKey(key)]; ok {
+			redacted[key] = []string{Redacted}
+			continue
+		}
+		redacted[key] = values
+	}
+	return redacted
+}
+
+// redactBody redacts fields in JSON bodies. Other bodies are recorded as they are.
+func (r *Recorder) redactBody(body []byte) []byte {
+	if len(r.redactFields) == 0 || len(body) == 0 {
+		return body
+	}
+	trimmed := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return body
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return body
+	}
+	redacted, err := json.Marshal(r.redactValue(value))
+	if err != nil {
+		return body
+	}
+	return redacted
+}
+
+func (r *Recorder) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, field := range v {
+			if _, ok := r.redactFields[key]; ok {
+				redacted[key] = Redacted
+				continue
+			}
+			redacted[key] = r.redactValue(field)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = r.redactValue(item)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
+
+// Read loads every entry from a recording.
+func Read(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	// Bodies can make for very long lines.
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid entry on line %d of %s: %w", line, path, err)
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, scanner.Err()
+}
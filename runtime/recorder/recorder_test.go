@@ -0,0 +1,142 @@
+package recorder_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/recorder"
+	"github.com/foldsh/fold/runtime/transport"
+)
+
+type echoRequestDoer struct {
+	err error
+}
+
+func (d echoRequestDoer) DoRequest(
+	ctx context.Context,
+	req *transport.Request,
+) (*transport.Response, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	return &transport.Response{
+		Status:  200,
+		Body:    req.Body,
+		Headers: map[string][]string{"Set-Cookie": {"session=abc"}},
+	}, nil
+}
+
+func TestRecordAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.jsonl")
+	rec, err := recorder.NewRecorder(
+		logging.NewTestLogger(),
+		path,
+		recorder.Service("orders"),
+		recorder.RedactHeaders("x-secret"),
+		recorder.RedactFields("password"),
+	)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	doer := rec.Wrap(echoRequestDoer{})
+	req := &transport.Request{
+		HTTPMethod: "POST",
+		Path:       "/login",
+		Headers: map[string][]string{
+			"Authorization": {"Bearer token"},
+			"X-Secret":      {"hunter2"},
+			"Accept":        {"application/json"},
+		},
+		Body: []byte(`{"user":"a","credentials":[{"password":"hunter2"}]}`),
+	}
+	res, err := doer.DoRequest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	// The service and the caller see the request and response as they are.
+	if string(res.Body) != string(req.Body) || req.Headers["Authorization"][0] != "Bearer token" {
+		t.Errorf("Expected the request and response to be unchanged")
+	}
+	if _, err := rec.Wrap(echoRequestDoer{err: errors.New("oops")}).DoRequest(
+		context.Background(),
+		&transport.Request{HTTPMethod: "GET", Path: "/"},
+	); err == nil {
+		t.Errorf("Expected the error to be returned")
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	entries, err := recorder.Read(path)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries but found %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Service != "orders" {
+		t.Errorf("Expected the service to be recorded but found %q", entry.Service)
+	}
+	testutils.Diff(t, map[string][]string{
+		"Authorization": {recorder.Redacted},
+		"X-Secret":      {recorder.Redacted},
+		"Accept":        {"application/json"},
+	}, entry.Request.Headers, "Request headers did not match")
+	testutils.Diff(
+		t,
+		`{"credentials":[{"password":"[REDACTED]"}],"user":"a"}`,
+		string(entry.Request.Body),
+		"Request body did not match",
+	)
+	testutils.Diff(
+		t,
+		map[string][]string{"Set-Cookie": {recorder.Redacted}},
+		entry.Response.Headers,
+		"Response headers did not match",
+	)
+	if entries[1].Response != nil || entries[1].Error != "oops" {
+		t.Errorf("Expected the error to be recorded but found %+v", entries[1])
+	}
+}
+
+func TestRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.jsonl")
+	rec, err := recorder.NewRecorder(
+		logging.NewTestLogger(),
+		path,
+		recorder.MaxSize(1),
+		recorder.MaxFiles(2),
+	)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	doer := rec.Wrap(echoRequestDoer{})
+	// Every entry is bigger than the maximum size, so each ends up in its own file.
+	for i := 0; i < 4; i++ {
+		req := &transport.Request{HTTPMethod: "GET", Path: fmt.Sprintf("/%d", i)}
+		if _, err := doer.DoRequest(context.Background(), req); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	rec.Close()
+
+	for file, expected := range map[string]string{path: "/3", path + ".1": "/2", path + ".2": "/1"} {
+		entries, err := recorder.Read(file)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if len(entries) != 1 || entries[0].Request.Path != expected {
+			t.Errorf("Expected %s to hold the request to %s", file, expected)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest file to be removed")
+	}
+}
@@ -0,0 +1,156 @@
+package runtime
+
+import (
+	"sync"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/transport"
+)
+
+type ReloadStrategyT uint8
+
+const (
+	// RESTART stops the process before starting the new one. Requests fail while the new process
+	// is starting.
+	RESTART ReloadStrategyT = iota + 1
+	// BLUE_GREEN starts the new process alongside the current one and only switches to it once
+	// it is ready. The current process is drained and stopped afterwards.
+	BLUE_GREEN
+)
+
+// activeRouter wraps the router which is currently serving requests. It counts the requests in
+// flight so that the process behind it can be drained before it is stopped.
+type activeRouter struct {
+	Router
+
+	mutex    sync.Mutex
+	inflight int
+	draining bool
+	drained  chan struct{}
+}
+
+func newActiveRouter(router Router) *activeRouter {
+	return &activeRouter{Router: router, drained: make(chan struct{})}
+}
+
+// acquire registers a request with the router. It returns false if the router is being drained,
+// in which case the request must go to the router which replaced it.
+func (ar *activeRouter) acquire() bool {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	if ar.draining {
+		return false
+	}
+	ar.inflight++
+	return true
+}
+
+func (ar *activeRouter) release() {
+	ar.mutex.Lock()
+	defer ar.mutex.Unlock()
+	ar.inflight--
+	if ar.draining && ar.inflight == 0 {
+		close(ar.drained)
+	}
+}
+
+// drain stops the router from accepting requests and waits for those in flight to complete. It
+// returns false if they didn't complete within the timeout or before abort was closed.
+func (ar *activeRouter) drain(timeout time.Duration, abort <-chan struct{}) bool {
+	ar.mutex.Lock()
+	ar.draining = true
+	if ar.inflight == 0 {
+		close(ar.drained)
+	}
+	ar.mutex.Unlock()
+	select {
+	case <-ar.drained:
+		return true
+	case <-time.After(timeout):
+		return false
+	case <-abort:
+		return false
+	}
+}
+
+func (r *Runtime) active() *activeRouter {
+	return r.router.Load().(*activeRouter)
+}
+
+// setRouter atomically replaces the router serving requests and returns the previous one.
+func (r *Runtime) setRouter(router Router) *activeRouter {
+	previous, _ := r.router.Load().(*activeRouter)
+	r.router.Store(newActiveRouter(router))
+	return previous
+}
+
+// reload restarts the service using the configured strategy.
+func (r *Runtime) reload() error {
+	if r.reloadStrategy == BLUE_GREEN {
+		return r.blueGreenReload()
+	}
+	return r.restartClientAndSupervisor()
+}
+
+// blueGreenReload starts a new process on a fresh socket and switches traffic to it once it has
+// completed the handshake and returned a valid manifest. If it doesn't get that far then it is
+// stopped and the current process carries on serving requests.
+func (r *Runtime) blueGreenReload() error {
+	r.logger.Debugf("Starting a new process alongside the current one")
+	supervisor, client, socket := r.supervisorFactory(), r.clientFactory(), r.socketFactory()
+	started, err := r.startProcess(supervisor, client, socket)
+	var session *transport.Session
+	if err == nil {
+		session, err = r.initialize(client)
+	}
+	var (
+		router Router
+		m      *manifest.Manifest
+	)
+	if err == nil {
+		router, m, err = r.newRouter(client)
+	}
+	if err != nil {
+		r.logger.Errorf("The new process failed to start, the current one will carry on: %v", err)
+		r.admin.SetError(err)
+		if started {
+			client.Stop()
+		}
+		supervisor.Stop()
+		return nil
+	}
+
+	r.processMutex.Lock()
+	previousSupervisor, previousClient := r.supervisor, r.client
+	r.supervisor, r.client, r.socketAddress = supervisor, client, socket
+	r.session = session
+	r.restarts++
+	r.processMutex.Unlock()
+	r.startProbing(client)
+	previous := r.setRouter(router)
+	r.admin.SetManifest(m)
+	r.admin.SetError(nil)
+
+	// Draining can take as long as the drain timeout, so it happens in the background rather than
+	// holding up the FSM, which would block every other event until it was done.
+	r.retiring.Add(1)
+	go r.retire(previous, previousClient, previousSupervisor)
+	return nil
+}
+
+// retire drains the router of a process replaced by a blue/green reload and then stops the
+// process. If the runtime exits in the meantime the process is stopped straight away.
+func (r *Runtime) retire(previous *activeRouter, client Client, supervisor Supervisor) {
+	defer r.retiring.Done()
+	r.logger.Debugf("Draining the previous process")
+	if !previous.drain(r.drainTimeout, r.exiting) {
+		r.logger.Warnf("Requests to the previous process didn't complete before it was stopped")
+	}
+	if err := client.Stop(); err != nil {
+		r.logger.Errorf("Failed to stop the client for the previous process: %v", err)
+	}
+	if err := supervisor.Stop(); err != nil {
+		r.logger.Errorf("Failed to stop the previous process: %v", err)
+	}
+}
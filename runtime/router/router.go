@@ -1,14 +1,32 @@
 package router
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/auth"
+	"github.com/foldsh/fold/runtime/cache"
+	"github.com/foldsh/fold/runtime/compression"
+	"github.com/foldsh/fold/runtime/concurrency"
+	"github.com/foldsh/fold/runtime/cors"
+	"github.com/foldsh/fold/runtime/idempotency"
+	"github.com/foldsh/fold/runtime/ratelimit"
+	"github.com/foldsh/fold/runtime/schema"
+	"github.com/foldsh/fold/runtime/static"
 	"github.com/foldsh/fold/runtime/transport"
 )
 
@@ -19,8 +37,68 @@ type RequestDoer interface {
 // Builds a router from a service manifest. While we could fetch the manfiest
 // from the service, making it a parameter gives some more options about
 // how and when we acquire one.
-func NewRouter(logger logging.Logger, doer RequestDoer) *Router {
-	return &Router{logger: logger, doer: doer, router: newRouter()}
+func NewRouter(logger logging.Logger, doer RequestDoer, options ...Option) *Router {
+	router := &Router{logger: logger, doer: doer, router: newRouter()}
+	for _, option := range options {
+		option(router)
+	}
+	if router.limiter == nil {
+		router.limiter = ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	}
+	if router.cache == nil {
+		router.cache = cache.NewLRU(DefaultCacheSize)
+	}
+	if router.idempotency == nil {
+		router.idempotency = idempotency.NewKeeper(idempotency.NewMemoryStore())
+	}
+	return router
+}
+
+type Option func(*Router)
+
+// DefaultCacheSize is the number of responses held by the cache if one isn't given to the router.
+const DefaultCacheSize = 1000
+
+// WithCache sets the cache used for the responses of routes with a cache policy. It is purged
+// whenever the router is configured with a new manifest.
+func WithCache(c *cache.LRU) Option {
+	return func(r *Router) {
+		r.cache = c
+	}
+}
+
+// WithAuthenticator sets the authenticator used to verify the bearer tokens sent to routes which
+// require authentication. Without one, requests to those routes are always rejected.
+func WithAuthenticator(authenticator *auth.Authenticator) Option {
+	return func(r *Router) {
+		r.authenticator = authenticator
+	}
+}
+
+// WithLimiter sets the limiter used to enforce the rate limits declared in the manifest. The
+// limiter should be shared between routers so that clients can't escape their limits when the
+// service is reloaded.
+func WithLimiter(limiter *ratelimit.Limiter) Option {
+	return func(r *Router) {
+		r.limiter = limiter
+	}
+}
+
+// WithConcurrencyLimiter limits the number of requests sent to the service at once. Like the rate
+// limiter, it should be shared between routers.
+func WithConcurrencyLimiter(limiter *concurrency.Limiter) Option {
+	return func(r *Router) {
+		r.concurrency = limiter
+	}
+}
+
+// WithIdempotency sets the keeper which stores the responses to requests with an idempotency key.
+// Like the rate limiter, it should be shared between routers so that retries are still caught
+// after the service is reloaded.
+func WithIdempotency(keeper *idempotency.Keeper) Option {
+	return func(r *Router) {
+		r.idempotency = keeper
+	}
 }
 
 var HTTP_METHODS = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
@@ -38,47 +116,102 @@ type Router struct {
 	doer     RequestDoer
 	router   *httprouter.Router
 	manifest *manifest.Manifest
+	cors     *cors.Policy
+	limiter  *ratelimit.Limiter
+	compress *compression.Policy
+	cache    *cache.LRU
+
+	authenticator *auth.Authenticator
+	concurrency   *concurrency.Limiter
+	idempotency   *idempotency.Keeper
 }
 
 // This just implements the http.Handler interface
 func (fr *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Preflight requests are answered by the router's OPTIONS handler, every other request
+	// is decorated with the CORS headers before it is handled.
+	if fr.cors != nil && !cors.IsPreflight(r) {
+		fr.cors.Decorate(w.Header(), r)
+	}
 	fr.router.ServeHTTP(w, r)
 }
 
-func (fr *Router) Configure(m *manifest.Manifest) {
+// Configure replaces the routes with those from the manifest. If the manifest is invalid then an
+// error is returned and the router keeps serving its current routes.
+func (fr *Router) Configure(m *manifest.Manifest) error {
+	if err := manifest.Validate(m); err != nil {
+		return err
+	}
 	fr.manifest = m
 	router := newRouter()
-	// Register the default admin routes.
-	router.Handle(
-		"GET",
-		"/_foldadmin/healthz",
-		func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-			w.WriteHeader(200)
-			w.Write([]byte(`{"status":"OK"}`))
-		},
-	)
-	router.Handle(
-		"GET",
-		"/_foldadmin/manifest",
-		func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-			// If successful Write implicity sets the 200 response on the ResponseWriter
-			err := manifest.WriteJSON(w, fr.manifest)
-			if err != nil {
-				w.WriteHeader(500)
-				w.Write([]byte(`{"title":"Failed to marshal manifest to JSON"}`))
-				return
-			}
-		},
-	)
-	// And now register all of the routes from the manifest.
+	fr.compress = compression.NewPolicy(m.Compression)
+	// The service has changed so anything we have cached could be stale.
+	fr.cache.Purge()
+	fr.cors = nil
+	if m.Cors != nil {
+		fr.cors = cors.NewPolicy(m.Cors)
+		// httprouter calls this for OPTIONS requests to any path that has a route registered,
+		// unless the service has registered an OPTIONS handler itself.
+		router.GlobalOPTIONS = http.HandlerFunc(fr.preflight)
+	}
+	// Register all of the routes from the manifest.
 	for _, route := range m.Routes {
+		if route.Auth != nil && fr.authenticator == nil {
+			fr.logger.Errorf(
+				"Route %s %s requires authentication but no JWKS is configured, "+
+					"all requests to it will be rejected",
+				route.HttpMethod,
+				route.Route,
+			)
+		}
+		schemas, err := compileSchemas(route)
+		if err != nil {
+			// The route is still served, requests to it just aren't validated.
+			fr.logger.Errorf("Not validating requests to %s %s: %v", route.HttpMethod, route.Route, err)
+		}
 		router.Handle(
 			route.HttpMethod.String(),
 			route.Route,
-			fr.makeHandler(route),
+			fr.makeHandler(route, schemas),
 		)
 	}
+	if len(m.Static) > 0 {
+		mounts := make([]*static.Mount, len(m.Static))
+		for i, mount := range m.Static {
+			mounts[i] = static.NewMount(mount)
+		}
+		// The most specific mount is tried first.
+		sort.SliceStable(mounts, func(i, j int) bool {
+			return len(mounts[i].Prefix()) > len(mounts[j].Prefix())
+		})
+		router.NotFound = serveStatic(mounts, notFound)
+		router.MethodNotAllowed = serveStatic(mounts, notAllowed)
+	}
 	fr.router = router
+	return nil
+}
+
+// serveStatic serves GET and HEAD requests which don't match any of the service's routes from the
+// static mounts, if one of them has the file. Otherwise the request is passed on to next.
+func serveStatic(mounts []*static.Mount, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || r.Method == "HEAD" {
+			for _, mount := range mounts {
+				if mount.Match(r.URL.Path) && mount.Serve(w, r) {
+					return
+				}
+			}
+		}
+		next(w, r)
+	})
+}
+
+func (fr *Router) preflight(w http.ResponseWriter, r *http.Request) {
+	if !cors.IsPreflight(r) {
+		// This is just a plain OPTIONS request, the Allow header has already been set.
+		return
+	}
+	fr.cors.Preflight(w, r, strings.Split(w.Header().Get("Allow"), ", "))
 }
 
 func newRouter() *httprouter.Router {
@@ -88,8 +221,76 @@ func newRouter() *httprouter.Router {
 	return router
 }
 
-func (fr *Router) makeHandler(route *manifest.Route) httprouter.Handle {
+type schemas struct {
+	body  *schema.Schema
+	query *schema.Schema
+}
+
+func compileSchemas(route *manifest.Route) (schemas, error) {
+	var (
+		s   schemas
+		err error
+	)
+	if route.BodySchema != "" {
+		if s.body, err = schema.Compile(route.BodySchema); err != nil {
+			return schemas{}, fmt.Errorf("body schema: %w", err)
+		}
+	}
+	if route.QuerySchema != "" {
+		if s.query, err = schema.Compile(route.QuerySchema); err != nil {
+			return schemas{}, fmt.Errorf("query schema: %w", err)
+		}
+	}
+	return s, nil
+}
+
+type invalidParam struct {
+	In      string `json:"in"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// validate checks the request against the route's schemas and returns every problem found.
+func (s schemas) validate(r *http.Request) []invalidParam {
+	var invalid []invalidParam
+	if s.query != nil {
+		for _, e := range s.query.ValidateQuery(r.URL.Query()) {
+			invalid = append(invalid, invalidParam{"query", e.Path, e.Message})
+		}
+	}
+	if s.body != nil && r.Body != nil {
+		bs, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return append(invalid, invalidParam{"body", "/", "could not be read"})
+		}
+		// The body has to be put back so that it can be forwarded to the service.
+		r.Body = ioutil.NopCloser(bytes.NewReader(bs))
+		var body interface{}
+		if err := json.Unmarshal(bs, &body); err != nil {
+			return append(invalid, invalidParam{"body", "/", "must be valid JSON"})
+		}
+		for _, e := range s.body.Validate(body) {
+			invalid = append(invalid, invalidParam{"body", e.Path, e.Message})
+		}
+	}
+	return invalid
+}
+
+func (fr *Router) makeHandler(route *manifest.Route, schemas schemas) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		limit := fr.limiter.Take(route, r)
+		limit.SetHeaders(w.Header())
+		if !limit.Allowed {
+			tooManyRequests(w, r)
+			return
+		}
+		var claims auth.Claims
+		if route.Auth != nil {
+			var ok bool
+			if claims, ok = fr.authenticate(w, r, route.Auth); !ok {
+				return
+			}
+		}
 		if r.Method == "PUT" || r.Method == "POST" {
 			isJSON := false
 			for _, c := range r.Header.Values("Content-Type") {
@@ -103,7 +304,54 @@ func (fr *Router) makeHandler(route *manifest.Route) httprouter.Handle {
 				return
 			}
 		}
+		if invalid := schemas.validate(r); len(invalid) > 0 {
+			invalidRequest(w, invalid)
+			return
+		}
+		subject, _ := claims["sub"].(string)
+		claim, stored, err := fr.idempotency.Claim(route, r, subject)
+		switch {
+		case errors.Is(err, idempotency.InProgress):
+			problem(w, http.StatusConflict, "Conflict", err.Error())
+			return
+		case errors.Is(err, idempotency.KeyReused):
+			problem(w, http.StatusUnprocessableEntity, "Unprocessable entity", err.Error())
+			return
+		case errors.Is(err, idempotency.InvalidKey):
+			problem(w, http.StatusBadRequest, "Invalid request", err.Error())
+			return
+		case err != nil:
+			fr.logger.Errorf("Failed to claim the idempotency key: %v", err)
+			problem(w, 500, "Runtime error", "Failed to claim the idempotency key.")
+			return
+		case stored != nil:
+			w.Header().Set(idempotency.ReplayedHeader, "true")
+			fr.writeResponse(w, r, stored.Status, stored.Headers, stored.Body)
+			return
+		}
+		// The key is released if the request fails before the service responds.
+		defer claim.Release()
+		if cache.Lookup(route, r) {
+			if entry, ok := fr.cache.Get(cache.Key(route, r)); ok {
+				w.Header().Set("Age", fmt.Sprintf("%d", int(time.Since(entry.Created).Seconds())))
+				fr.writeResponse(w, r, entry.Status, entry.Headers, entry.Body)
+				return
+			}
+		}
+		if fr.concurrency != nil {
+			release, err := fr.concurrency.Acquire(r.Context(), route)
+			if err != nil {
+				w.Header().Set(
+					"Retry-After",
+					strconv.Itoa(int(math.Ceil(fr.concurrency.RetryAfter().Seconds()))),
+				)
+				problem(w, http.StatusServiceUnavailable, "Service busy", err.Error())
+				return
+			}
+			defer release()
+		}
 		req := transport.ReqFromHTTP(r, route.Route, encodePathParams(ps))
+		req.Claims = claims
 		res, err := fr.doer.DoRequest(r.Context(), req)
 		if err != nil {
 			httpError(
@@ -113,27 +361,123 @@ func (fr *Router) makeHandler(route *manifest.Route) httprouter.Handle {
 			)
 			return
 		}
-		// Write the status code
-		w.WriteHeader(int(res.Status))
-		// Write the headers
-		headers := w.Header()
-		for key, values := range res.Headers {
-			for _, value := range values {
-				headers.Add(key, value)
-			}
-		}
-		// Write the body
-		body := []byte(res.Body)
-		n, err := w.Write(body)
+		err = claim.Complete(&idempotency.Response{
+			Status:  res.Status,
+			Headers: res.Headers,
+			Body:    res.Body,
+		})
 		if err != nil {
-			httpError(w, 500, fmt.Sprintf(`{"title": "Runtime error", "detail": "%v"}`, err))
-			return
+			fr.logger.Errorf("Failed to store the response for the idempotency key: %v", err)
+		}
+		if cache.Cacheable(route, r, int(res.Status), res.Headers) {
+			fr.cache.Set(
+				route.Route,
+				cache.Key(route, r),
+				&cache.Entry{
+					Status:  int(res.Status),
+					Headers: res.Headers,
+					Body:    res.Body,
+					Created: time.Now(),
+				},
+				time.Duration(route.Cache.Ttl)*time.Second,
+			)
+		}
+		fr.writeResponse(w, r, int(res.Status), res.Headers, res.Body)
+	}
+}
+
+func (fr *Router) writeResponse(
+	w http.ResponseWriter,
+	r *http.Request,
+	status int,
+	resHeaders map[string][]string,
+	body []byte,
+) {
+	// Write the headers, this must happen before the status code or they are discarded.
+	headers := w.Header()
+	for key, values := range resHeaders {
+		for _, value := range values {
+			headers.Add(key, value)
+		}
+	}
+	encoding := headers.Get("Content-Encoding")
+	body = fr.compress.Compress(headers, r, status, body)
+	// Each encoding is a different representation of the resource, so the ETag is computed after
+	// compression and the service's own ETag is given the encoding as a suffix.
+	if status == http.StatusOK && (r.Method == "GET" || r.Method == "HEAD") {
+		etag := headers.Get("ETag")
+		if etag == "" {
+			etag = cache.ETag(body)
+			headers.Set("ETag", etag)
+		} else if compressed := headers.Get("Content-Encoding"); compressed != encoding {
+			etag = encodedETag(etag, compressed)
+			headers.Set("ETag", etag)
 		}
-		if n != len(body) {
-			httpError(w, 500, `{"title": "Runtime error", "detail": "Failed to read entire body."}`)
+		if cache.NotModified(r, etag) {
+			headers.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 	}
+	// Write the status code
+	w.WriteHeader(status)
+	// Write the body
+	n, err := w.Write(body)
+	if err != nil {
+		httpError(w, 500, fmt.Sprintf(`{"title": "Runtime error", "detail": "%v"}`, err))
+		return
+	}
+	if n != len(body) {
+		httpError(w, 500, `{"title": "Runtime error", "detail": "Failed to read entire body."}`)
+		return
+	}
+}
+
+// encodedETag adds the encoding to the opaque part of the ETag, keeping it weak if it was weak.
+func encodedETag(etag, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return fmt.Sprintf(`%s-%s"`, strings.TrimSuffix(etag, `"`), encoding)
+}
+
+// authenticate verifies the request against the policy, writing the error response and returning
+// false if it isn't allowed.
+func (fr *Router) authenticate(
+	w http.ResponseWriter,
+	r *http.Request,
+	policy *manifest.AuthPolicy,
+) (auth.Claims, bool) {
+	if fr.authenticator == nil {
+		problem(w, 500, "Runtime error", "Authentication is not configured.")
+		return nil, false
+	}
+	claims, err := fr.authenticator.Authenticate(r, policy)
+	if err == nil {
+		return claims, true
+	}
+	var insufficientScope auth.InsufficientScope
+	switch {
+	case errors.Is(err, auth.MissingToken):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="fold"`)
+		problem(w, http.StatusUnauthorized, "Unauthorized", "A bearer token is required.")
+	case errors.As(err, &insufficientScope):
+		w.Header().Set(
+			"WWW-Authenticate",
+			fmt.Sprintf(
+				`Bearer realm="fold", error="insufficient_scope", scope="%s"`,
+				strings.Join(policy.Scopes, " "),
+			),
+		)
+		problem(w, http.StatusForbidden, "Forbidden", err.Error())
+	default:
+		if errors.Is(err, auth.FailedToFetchJWKS) {
+			fr.logger.Errorf("Failed to authenticate request: %v", err)
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="fold", error="invalid_token"`)
+		problem(w, http.StatusUnauthorized, "Unauthorized", err.Error())
+	}
+	return nil, false
 }
 
 func encodePathParams(params httprouter.Params) map[string]string {
@@ -151,6 +495,20 @@ func httpError(w http.ResponseWriter, code int, e string) {
 	fmt.Fprintln(w, e)
 }
 
+func problem(w http.ResponseWriter, code int, title, detail string) {
+	body, _ := json.Marshal(map[string]string{"title": title, "detail": detail})
+	httpError(w, code, string(body))
+}
+
+func invalidRequest(w http.ResponseWriter, invalid []invalidParam) {
+	body, _ := json.Marshal(map[string]interface{}{
+		"title":  "Invalid request",
+		"detail": "The request does not match the schema for this route.",
+		"errors": invalid,
+	})
+	httpError(w, http.StatusBadRequest, string(body))
+}
+
 func notFound(w http.ResponseWriter, r *http.Request) {
 	httpError(w, http.StatusNotFound, `{"title":"Resource not found"}`)
 }
@@ -159,6 +517,10 @@ func notAllowed(w http.ResponseWriter, r *http.Request) {
 	httpError(w, http.StatusMethodNotAllowed, `{"title":"Method not allowed"}`)
 }
 
+func tooManyRequests(w http.ResponseWriter, r *http.Request) {
+	httpError(w, http.StatusTooManyRequests, `{"title":"Too many requests"}`)
+}
+
 func unsupportedMediaType(w http.ResponseWriter, r *http.Request) {
 	httpError(
 		w,
@@ -3,16 +3,24 @@ package router
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/foldsh/fold/internal/testutils"
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/auth"
+	"github.com/foldsh/fold/runtime/concurrency"
+	"github.com/foldsh/fold/runtime/idempotency"
 	"github.com/foldsh/fold/runtime/transport"
 )
 
@@ -256,36 +264,18 @@ func TestServeHTTP(t *testing.T) {
 	}
 	ms := &mockRequestDoer{t}
 	router := NewRouter(logger, ms)
+	// Listening before we start serving guarantees the server is up before the first request.
+	lis, err := net.Listen("tcp", port)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer lis.Close()
 	go func() {
-		http.ListenAndServe(port, router)
+		http.Serve(lis, router)
 	}()
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			router.Configure(tc.manifest)
-			// Test healthz
-			status, body := req(logger, t, "GET", "/_foldadmin/healthz", nil)
-			if status != 200 {
-				t.Errorf("Expected 200 response from healthz but found %d", status)
-			}
-			testutils.Diff(
-				t,
-				`{"status":"OK"}`,
-				string(body),
-				"/_foldadmin/healthz body did not match expectation",
-			)
-			// Test the manifest
-			expectation := &bytes.Buffer{}
-			manifest.WriteJSON(expectation, tc.manifest)
-			status, actual := req(logger, t, "GET", "/_foldadmin/manifest", nil)
-			if status != 200 {
-				t.Errorf("Expected 200 response from manifest but found %d", status)
-			}
-			testutils.Diff(
-				t,
-				expectation.Bytes(),
-				actual,
-				"/_foldadmin/manifest did not return the expected manifest",
-			)
 			for _, r := range tc.requests {
 				t.Run(fmt.Sprintf("%s:%s", r.method, r.path), func(t *testing.T) {
 					status, body := req(logger, t, r.method, r.path, r.body)
@@ -414,6 +404,16 @@ func req(
 	return resp.StatusCode, resBody
 }
 
+// A RequestDoer which returns an empty 200 response to every request.
+type okRequestDoer struct{}
+
+func (d okRequestDoer) DoRequest(
+	ctx context.Context,
+	req *transport.Request,
+) (*transport.Response, error) {
+	return &transport.Response{Status: 200, Body: []byte("{}")}, nil
+}
+
 type catchAllHandler struct{}
 
 func (cah catchAllHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -459,3 +459,492 @@ func TestCatchAllRouter(t *testing.T) {
 		}
 	}
 }
+
+func TestInvalidManifestIsRejected(t *testing.T) {
+	// An invalid manifest should be rejected without panicking, and the router should keep
+	// serving the routes it already had.
+	router := NewRouter(logging.NewTestLogger(), okRequestDoer{})
+	if err := router.Configure(mkmanifest(mkroute("GET", "/foo"))); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	err := router.Configure(mkmanifest(
+		mkroute("GET", "/_foldadmin/manifest"),
+		mkroute("GET", "/bar"),
+	))
+	var invalid manifest.InvalidManifest
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected the manifest to be rejected but got %v", err)
+	}
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	for path, expected := range map[string]int{"/foo": 200, "/bar": 404} {
+		res, err := server.Client().Get(fmt.Sprintf("%s%s", server.URL, path))
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != expected {
+			t.Errorf("%s: expected a %d status code but found %d", path, expected, res.StatusCode)
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	router := NewRouter(logging.NewTestLogger(), okRequestDoer{})
+	m := mkmanifest(mkroute("GET", "/foo"), mkroute("PUT", "/foo"))
+	m.Cors = &manifest.CorsPolicy{AllowedOrigins: []string{"https://fold.sh"}}
+	router.Configure(m)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	client := server.Client()
+
+	// The preflight request should be answered by the router without a handler being registered.
+	req, _ := http.NewRequest("OPTIONS", fmt.Sprintf("%s/foo", server.URL), nil)
+	req.Header.Set("Origin", "https://fold.sh")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 204 {
+		t.Errorf("Expected a 204 status code but found %d", res.StatusCode)
+	}
+	if origin := res.Header.Get("Access-Control-Allow-Origin"); origin != "https://fold.sh" {
+		t.Errorf("Expected the preflight to allow https://fold.sh but found %s", origin)
+	}
+	// Preflights for unknown paths should still 404.
+	req, _ = http.NewRequest("OPTIONS", fmt.Sprintf("%s/bar", server.URL), nil)
+	req.Header.Set("Origin", "https://fold.sh")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 404 {
+		t.Errorf("Expected a 404 status code but found %d", res.StatusCode)
+	}
+
+	// Regular requests should be decorated with the CORS headers.
+	req, _ = http.NewRequest("GET", fmt.Sprintf("%s/foo", server.URL), nil)
+	req.Header.Set("Origin", "https://fold.sh")
+	res, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	res.Body.Close()
+	if origin := res.Header.Get("Access-Control-Allow-Origin"); origin != "https://fold.sh" {
+		t.Errorf("Expected the response to allow https://fold.sh but found %s", origin)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	router := NewRouter(logging.NewTestLogger(), okRequestDoer{})
+	route := mkroute("GET", "/foo")
+	route.RateLimit = &manifest.RateLimit{Key: manifest.RateLimit_IP, Requests: 1, Period: 60}
+	router.Configure(mkmanifest(route))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	if w.Code != 200 {
+		t.Errorf("Expected a 200 status code but found %d", w.Code)
+	}
+	if remaining := w.Header().Get("RateLimit-Remaining"); remaining != "0" {
+		t.Errorf("Expected no requests to remain but found %s", remaining)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	if w.Code != 429 {
+		t.Errorf("Expected a 429 status code but found %d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "60" {
+		t.Errorf("Expected to retry after 60 seconds but found %s", retryAfter)
+	}
+}
+
+type blockingRequestDoer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d blockingRequestDoer) DoRequest(
+	ctx context.Context,
+	req *transport.Request,
+) (*transport.Response, error) {
+	d.started <- struct{}{}
+	<-d.release
+	return &transport.Response{Status: 200}, nil
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	doer := blockingRequestDoer{started: make(chan struct{}), release: make(chan struct{})}
+	limiter := concurrency.NewLimiter(
+		concurrency.Settings{MaxInFlight: 1},
+		concurrency.RetryAfter(2*time.Second),
+	)
+	router := NewRouter(logging.NewTestLogger(), doer, WithConcurrencyLimiter(limiter))
+	router.Configure(mkmanifest(mkroute("GET", "/foo")))
+
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/foo", nil))
+		close(done)
+	}()
+	<-doer.started
+
+	// There is no queue, so the second request is turned away while the first is in flight.
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	if w.Code != 503 {
+		t.Errorf("Expected a 503 status code but found %d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("Expected to retry after 2 seconds but found %s", retryAfter)
+	}
+	close(doer.release)
+	<-done
+}
+
+func TestAuthRequired(t *testing.T) {
+	route := mkroute("GET", "/foo")
+	route.Auth = &manifest.AuthPolicy{Scopes: []string{"read"}}
+
+	// Without a token the request is rejected before it reaches the service.
+	authenticator := auth.NewAuthenticator(auth.NewJWKS("./testdata/jwks.json", time.Hour))
+	router := NewRouter(logging.NewTestLogger(), okRequestDoer{}, WithAuthenticator(authenticator))
+	router.Configure(mkmanifest(route))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	if w.Code != 401 {
+		t.Errorf("Expected a 401 status code but found %d", w.Code)
+	}
+	if w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("Expected a WWW-Authenticate header")
+	}
+
+	// An invalid token is rejected too.
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("Authorization", "Bearer foo.bar.baz")
+	router.ServeHTTP(w, r)
+	if w.Code != 401 {
+		t.Errorf("Expected a 401 status code but found %d", w.Code)
+	}
+
+	// If no authenticator is configured then the route can never be reached.
+	router = NewRouter(logging.NewTestLogger(), okRequestDoer{})
+	router.Configure(mkmanifest(route))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	if w.Code != 500 {
+		t.Errorf("Expected a 500 status code but found %d", w.Code)
+	}
+}
+
+type largeRequestDoer struct {
+	etag string
+}
+
+func (d largeRequestDoer) DoRequest(
+	ctx context.Context,
+	req *transport.Request,
+) (*transport.Response, error) {
+	headers := map[string][]string{"Content-Type": {"application/json"}}
+	if d.etag != "" {
+		headers["ETag"] = []string{d.etag}
+	}
+	return &transport.Response{
+		Status:  200,
+		Body:    bytes.Repeat([]byte(`{"foo":"bar"}`), 200),
+		Headers: headers,
+	}, nil
+}
+
+func TestCompression(t *testing.T) {
+	router := NewRouter(logging.NewTestLogger(), largeRequestDoer{})
+	router.Configure(mkmanifest(mkroute("GET", "/foo")))
+
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "gzip" {
+		t.Errorf("Expected the response to be gzipped but found %q", encoding)
+	}
+	if vary := w.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("Expected Vary to be Accept-Encoding but found %q", vary)
+	}
+
+	// Compression can be turned off in the manifest.
+	m := mkmanifest(mkroute("GET", "/foo"))
+	m.Compression = &manifest.CompressionPolicy{Disabled: true}
+	router.Configure(m)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if encoding := w.Header().Get("Content-Encoding"); encoding != "" {
+		t.Errorf("Expected the response not to be compressed but found %q", encoding)
+	}
+}
+
+func TestCompressionETag(t *testing.T) {
+	cases := map[string]struct {
+		etag           string
+		acceptEncoding string
+		expected       string
+	}{
+		"Compressed":        {`"v1"`, "gzip", `"v1-gzip"`},
+		"Weak compressed":   {`W/"v1"`, "gzip", `W/"v1-gzip"`},
+		"Uncompressed":      {`"v1"`, "", `"v1"`},
+		"Weak uncompressed": {`W/"v1"`, "", `W/"v1"`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			router := NewRouter(logging.NewTestLogger(), largeRequestDoer{etag: tc.etag})
+			router.Configure(mkmanifest(mkroute("GET", "/foo")))
+
+			r := httptest.NewRequest("GET", "/foo", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if etag := w.Header().Get("ETag"); etag != tc.expected {
+				t.Fatalf("Expected the ETag %s but found %s", tc.expected, etag)
+			}
+
+			r = httptest.NewRequest("GET", "/foo", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			r.Header.Set("If-None-Match", tc.expected)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != 304 {
+				t.Errorf("Expected a 304 status code but found %d", w.Code)
+			}
+			if tc.acceptEncoding == "" {
+				return
+			}
+			// Revalidating with the ETag of the uncompressed representation must not match.
+			r = httptest.NewRequest("GET", "/foo", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			r.Header.Set("If-None-Match", tc.etag)
+			w = httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != 200 {
+				t.Errorf("Expected the uncompressed ETag not to match but found %d", w.Code)
+			}
+		})
+	}
+}
+
+type countingRequestDoer struct {
+	calls int
+}
+
+func (d *countingRequestDoer) DoRequest(
+	ctx context.Context,
+	req *transport.Request,
+) (*transport.Response, error) {
+	d.calls++
+	return &transport.Response{Status: 200, Body: []byte(fmt.Sprintf(`{"calls":%d}`, d.calls))}, nil
+}
+
+func TestConditionalRequests(t *testing.T) {
+	router := NewRouter(logging.NewTestLogger(), okRequestDoer{})
+	router.Configure(mkmanifest(mkroute("GET", "/foo")))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Expected the response to have an ETag")
+	}
+
+	r := httptest.NewRequest("GET", "/foo", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != 304 {
+		t.Errorf("Expected a 304 status code but found %d", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("Expected a 304 response to have no body")
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	doer := &countingRequestDoer{}
+	router := NewRouter(logging.NewTestLogger(), doer)
+	route := mkroute("GET", "/foo")
+	route.Cache = &manifest.CachePolicy{Ttl: 60}
+	router.Configure(mkmanifest(route))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+		if body := w.Body.String(); body != `{"calls":1}` {
+			t.Errorf("Expected the cached response but found %s", body)
+		}
+	}
+	if doer.calls != 1 {
+		t.Errorf("Expected the service to be called once but it was called %d times", doer.calls)
+	}
+
+	// Reconfiguring the router empties the cache.
+	router.Configure(mkmanifest(route))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/foo", nil))
+	if body := w.Body.String(); body != `{"calls":2}` {
+		t.Errorf("Expected a fresh response but found %s", body)
+	}
+}
+
+func TestSchemaValidation(t *testing.T) {
+	route := mkroute("POST", "/foo")
+	route.BodySchema = `{"type": "object", "required": ["name"]}`
+	route.QuerySchema = `{"type": "object", "properties": {"page": {"type": "integer"}}}`
+	router := NewRouter(logging.NewTestLogger(), okRequestDoer{})
+	router.Configure(mkmanifest(route))
+
+	r := httptest.NewRequest("POST", "/foo?page=a", bytes.NewReader([]byte(`{}`)))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != 400 {
+		t.Fatalf("Expected a 400 status code but found %d", w.Code)
+	}
+	var problem struct {
+		Errors []invalidParam `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expected := []invalidParam{
+		{"query", "/page", "must be of type integer"},
+		{"body", "/name", "is required"},
+	}
+	testutils.Diff(t, expected, problem.Errors, "Validation errors did not match")
+
+	r = httptest.NewRequest("POST", "/foo?page=1", bytes.NewReader([]byte(`{"name": "foo"}`)))
+	r.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Errorf("Expected a 200 status code but found %d", w.Code)
+	}
+}
+
+func TestStaticMounts(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<html>app</html>"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "foo"), []byte("file"), 0644)
+	doer := &countingRequestDoer{}
+	router := NewRouter(logging.NewTestLogger(), doer)
+	m := mkmanifest(mkroute("GET", "/foo"), mkroute("POST", "/bar"))
+	m.Static = []*manifest.StaticMount{{Prefix: "/", Dir: dir, Fallback: "index.html"}}
+	if err := router.Configure(m); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cases := []struct {
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		// The service's routes take precedence over the files.
+		{"GET", "/foo", 200, `{"calls":1}`},
+		{"GET", "/index.html", 200, "<html>app</html>"},
+		{"HEAD", "/bar", 200, ""},
+		{"GET", "/items/1", 200, "<html>app</html>"},
+		{"DELETE", "/items/1", 404, `{"title":"Resource not found"}` + "\n"},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		if w.Code != tc.status || w.Body.String() != tc.body {
+			t.Errorf(
+				"Expected %s %s to return %d %q but got %d %q",
+				tc.method,
+				tc.path,
+				tc.status,
+				tc.body,
+				w.Code,
+				w.Body.String(),
+			)
+		}
+	}
+	if doer.calls != 1 {
+		t.Errorf("Expected only one request to reach the service but %d did", doer.calls)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	doer := &countingRequestDoer{}
+	router := NewRouter(logging.NewTestLogger(), doer)
+	route := mkroute("POST", "/foo")
+	route.Idempotency = &manifest.IdempotencyPolicy{Ttl: 60}
+	router.Configure(mkmanifest(route))
+
+	post := func(key string, body string, auth string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/foo", bytes.NewReader([]byte(body)))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(idempotency.Header, key)
+		if auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	w := post("a", `{}`, "")
+	if w.Header().Get(idempotency.ReplayedHeader) != "" {
+		t.Errorf("Expected the first response not to be replayed")
+	}
+	w = post("a", `{}`, "")
+	if body := w.Body.String(); body != `{"calls":1}` {
+		t.Errorf("Expected the stored response but found %s", body)
+	}
+	if w.Header().Get(idempotency.ReplayedHeader) != "true" {
+		t.Errorf("Expected the response to be marked as replayed")
+	}
+	if body := post("b", `{}`, "").Body.String(); body != `{"calls":2}` {
+		t.Errorf("Expected a fresh response for a new key but found %s", body)
+	}
+	if body := post("a", `{}`, "Bearer token").Body.String(); body != `{"calls":3}` {
+		t.Errorf("Expected a fresh response for another caller but found %s", body)
+	}
+	if w := post("a", `{"a":1}`, ""); w.Code != 422 {
+		t.Errorf("Expected a 422 status code for a reused key but found %d", w.Code)
+	}
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	doer := blockingRequestDoer{started: make(chan struct{}), release: make(chan struct{})}
+	router := NewRouter(logging.NewTestLogger(), doer)
+	route := mkroute("POST", "/foo")
+	route.Idempotency = &manifest.IdempotencyPolicy{}
+	router.Configure(mkmanifest(route))
+
+	post := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/foo", bytes.NewReader([]byte(`{}`)))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(idempotency.Header, "a")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w
+	}
+	done := make(chan struct{})
+	go func() {
+		post()
+		close(done)
+	}()
+	<-doer.started
+
+	if w := post(); w.Code != 409 {
+		t.Errorf("Expected a 409 status code but found %d", w.Code)
+	}
+	close(doer.release)
+	<-done
+}
@@ -6,17 +6,30 @@ package runtime
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/admin"
+	"github.com/foldsh/fold/runtime/auth"
+	"github.com/foldsh/fold/runtime/cache"
+	"github.com/foldsh/fold/runtime/concurrency"
+	"github.com/foldsh/fold/runtime/debugger"
 	"github.com/foldsh/fold/runtime/fsm"
+	"github.com/foldsh/fold/runtime/idempotency"
+	"github.com/foldsh/fold/runtime/ratelimit"
+	"github.com/foldsh/fold/runtime/recorder"
 	"github.com/foldsh/fold/runtime/router"
 	"github.com/foldsh/fold/runtime/supervisor"
 	"github.com/foldsh/fold/runtime/transport"
+	"github.com/foldsh/fold/runtime/watcher"
 )
 
 //go:generate mockery --config ../.mockery.yaml --name Supervisor
@@ -27,6 +40,7 @@ type Supervisor interface {
 	Kill() error
 	Wait() error
 	Signal(sig os.Signal) error
+	Stats() (*supervisor.ProcessStats, error)
 }
 
 //go:generate mockery --config ../.mockery.yaml --name Client
@@ -34,18 +48,24 @@ type Client interface {
 	Start(string) error
 	Stop() error
 	Restart(string) error
+	Initialize(context.Context, transport.Handshake) (*transport.Session, error)
 	GetManifest(context.Context) (*manifest.Manifest, error)
 	DoRequest(context.Context, *transport.Request) (*transport.Response, error)
+	Check(context.Context) error
 }
 
 //go:generate mockery --config ../.mockery.yaml --name Router
 type Router interface {
 	http.Handler
-	Configure(*manifest.Manifest)
+	Configure(*manifest.Manifest) error
 }
 
 type SocketFactory func() string
 
+type SupervisorFactory func() Supervisor
+
+type ClientFactory func() Client
+
 type RouterFactory func(logger logging.Logger, doer router.RequestDoer) Router
 
 type Runtime struct {
@@ -56,17 +76,57 @@ type Runtime struct {
 	done   chan struct{}
 
 	// These properties have the same lifetime as the runtime
-	env           map[string]string
+	env               map[string]string
+	supervisorFactory SupervisorFactory
+	clientFactory     ClientFactory
+	socketFactory     SocketFactory
+	routerFactory     RouterFactory
+	defaultRouter     Router
+	onProcessEnd      func()
+	manifestTimeout   time.Duration
+	reloadStrategy    ReloadStrategyT
+	drainTimeout      time.Duration
+	admin             *admin.Admin
+	publicAdmin       http.Handler
+	limiter           *ratelimit.Limiter
+	rateLimitStore    ratelimit.Store
+	trustedProxies    []*net.IPNet
+	authenticator     *auth.Authenticator
+	cache             *cache.LRU
+	concurrency       *concurrency.Limiter
+	idempotency       *idempotency.Keeper
+	recorder          *recorder.Recorder
+	debugger          *debugger.Debugger
+	handshake         transport.Handshake
+	watch             *watchSettings
+	builder           *watcher.Builder
+	healthInterval    time.Duration
+	healthTimeout     time.Duration
+	healthThreshold   int
+
+	// These are set dynamically with restarts etc. The process fields are guarded by the mutex
+	// as a blue/green reload replaces them while the previous process may still be terminating.
+	processMutex  sync.Mutex
 	supervisor    Supervisor
 	client        Client
-	socketFactory SocketFactory
-	routerFactory RouterFactory
-	defaultRouter Router
-	onProcessEnd  func()
-
-	// These are set dynamically with restarts etc
 	socketAddress string
-	router        Router
+	session       *transport.Session
+	// The number of times the process has been replaced since the runtime started it.
+	restarts int
+	// The most recent crash reports, newest first.
+	crashes []*supervisor.CrashReport
+	// The processes replaced by blue/green reloads which are still being drained. They are
+	// stopped straight away once exiting is closed.
+	retiring sync.WaitGroup
+	exiting  chan struct{}
+	// Holds an *activeRouter, it is swapped atomically as requests are served concurrently.
+	router atomic.Value
+	// The watcher is started by Start, it is nil until then or if hot reloading is disabled.
+	watchMutex sync.Mutex
+	watcher    watcher.Watcher
+	// Checks the health of the current process, it is nil when health checks are disabled.
+	probeMutex sync.Mutex
+	prober     *prober
 }
 
 var (
@@ -79,6 +139,14 @@ var (
 	EXIT        fsm.Event = "EXIT"
 	CRASH       fsm.Event = "CRASH"
 	FILE_CHANGE fsm.Event = "FILE_CHANGE"
+	// The process is up but the runtime can't serve it, because its SDK is incompatible or its
+	// manifest is invalid.
+	REJECT fsm.Event = "REJECT"
+	// The process is up but has failed too many health checks in a row.
+	UNHEALTHY fsm.Event = "UNHEALTHY"
+	// The process was stopped by a signal which the runtime didn't send as part of a transition,
+	// e.g. one it passed on from docker stop.
+	SIGNALLED fsm.Event = "SIGNALLED"
 )
 
 func NewRuntime(
@@ -89,31 +157,75 @@ func NewRuntime(
 	options ...Option,
 ) *Runtime {
 	newRuntime := &Runtime{
-		logger: logger,
-		cmd:    cmd,
-		args:   args,
-		done:   done,
+		logger:          logger,
+		cmd:             cmd,
+		args:            args,
+		done:            done,
+		manifestTimeout: 10 * time.Second,
+		reloadStrategy:  RESTART,
+		drainTimeout:    30 * time.Second,
+		handshake:       transport.Handshake{Features: transport.Features},
+		exiting:         make(chan struct{}),
 	}
 
 	// First up we configure the default FSM. Other options can change it later on.
 	configureFSM(newRuntime)
 
+	// The admin routes are served by the runtime rather than the router so that they are
+	// available regardless of the state of the service. By default they are public, the
+	// options can restrict or remove them.
+	newRuntime.admin = admin.NewAdmin(
+		newRuntime.logger,
+		admin.HealthCheck(func() bool { return newRuntime.State() == UP }),
+		admin.Probe(newRuntime.probeStatus),
+	)
+	newRuntime.publicAdmin = newRuntime.admin.Public()
+	newRuntime.admin.Handle("GET", "/ratelimits", newRuntime.rateLimits)
+	newRuntime.admin.Handle("DELETE", "/cache", newRuntime.purgeCache)
+	newRuntime.admin.Handle("GET", "/concurrency", newRuntime.concurrencyStats)
+	newRuntime.admin.Handle("GET", "/process", newRuntime.processInfo)
+	newRuntime.admin.Handle("GET", "/metrics", newRuntime.metrics)
+	newRuntime.admin.HandleSensitive("GET", "/crashes", newRuntime.crashReports)
+
+	newRuntime.rateLimitStore = ratelimit.NewMemoryStore()
+	newRuntime.idempotency = idempotency.NewKeeper(idempotency.NewMemoryStore())
+	newRuntime.cache = cache.NewLRU(router.DefaultCacheSize)
+
+	// The same constructors are used for the first process and for the new processes started by
+	// blue/green reloads.
+	newSupervisor := func() Supervisor {
+		cmd, args := newRuntime.cmd, newRuntime.args
+		if newRuntime.debugger != nil {
+			cmd, args = newRuntime.debugger.Command(cmd, args)
+		}
+		s := supervisor.NewSupervisor(newRuntime.logger, cmd, args, os.Stdout, os.Stdout)
+		if newRuntime.debugger != nil {
+			s.StopSignal = newRuntime.debugger.StopSignal()
+		}
+		return s
+	}
+	newClient := func() Client { return transport.NewIngress(newRuntime.logger) }
+
 	// The default options are handled the same way as user defined options. Options are applied
 	// in order so the defaults just get overriden by the user defined ones.
 	defaultOptions := []Option{
-		WithSupervisor(
-			supervisor.NewSupervisor(
-				newRuntime.logger,
-				newRuntime.cmd,
-				newRuntime.args,
-				os.Stdout,
-				os.Stdout,
-			),
-		),
-		WithClient(transport.NewIngress(newRuntime.logger)),
+		WithSupervisorFactory(newSupervisor),
+		WithClient(newClient()),
+		WithClientFactory(newClient),
 		WithSocketFactory(newAddr),
 		WithRouterFactory(func(l logging.Logger, d router.RequestDoer) Router {
-			return router.NewRouter(l, d)
+			if newRuntime.recorder != nil {
+				d = newRuntime.recorder.Wrap(d)
+			}
+			return router.NewRouter(
+				l,
+				d,
+				router.WithLimiter(newRuntime.limiter),
+				router.WithAuthenticator(newRuntime.authenticator),
+				router.WithCache(newRuntime.cache),
+				router.WithConcurrencyLimiter(newRuntime.concurrency),
+				router.WithIdempotency(newRuntime.idempotency),
+			)
 		}),
 		WithDefaultRouter(router.NewCatchAllRouter(newRuntime.logger, &defaultRequestDoer{})),
 		// For now, regardless of the reason for termination, we handle process termination using
@@ -128,6 +240,23 @@ func NewRuntime(
 		option(newRuntime)
 	}
 
+	// Rate limits are tracked by the runtime, rather than by each router, so that clients don't
+	// get a fresh set of tokens every time the service is reloaded.
+	newRuntime.limiter = ratelimit.NewLimiter(
+		newRuntime.rateLimitStore,
+		ratelimit.TrustedProxies(newRuntime.trustedProxies...),
+	)
+	// The first supervisor is only created now so that it picks up options like Debug.
+	if newRuntime.supervisor == nil {
+		newRuntime.supervisor = newRuntime.supervisorFactory()
+	}
+	// The new process would fail to listen on the debugger's port while the previous one is still
+	// running.
+	if newRuntime.debugger != nil && newRuntime.reloadStrategy == BLUE_GREEN {
+		newRuntime.logger.Warnf("Blue/green reloads are not supported while debugging, restarting")
+		newRuntime.reloadStrategy = RESTART
+	}
+
 	return newRuntime
 }
 
@@ -148,7 +277,15 @@ func configureFSM(r *Runtime) {
 			}},
 			{START, UP, UP, []fsm.Callback{
 				func() {
-					if err := r.restartClientAndSupervisor(); err != nil {
+					if err := r.reload(); err != nil {
+						r.exit()
+					}
+				},
+			}},
+			// An unhealthy process is restarted in the same way as it is reloaded.
+			{UNHEALTHY, UP, UP, []fsm.Callback{
+				func() {
+					if err := r.reload(); err != nil {
 						r.exit()
 					}
 				},
@@ -160,6 +297,18 @@ func configureFSM(r *Runtime) {
 					}
 				},
 			}},
+			{REJECT, UP, DOWN, []fsm.Callback{
+				func() {
+					if err := r.stopClientAndSupervisor(); err != nil {
+						r.exit()
+					}
+				},
+			}},
+			// There is no transition from DOWN, where the runtime stopped the process itself
+			// because it was rejected.
+			{SIGNALLED, UP, EXITED, []fsm.Callback{
+				func() { r.stopClientAndSupervisor() },
+			}},
 			{STOP, DOWN, EXITED, nil},
 			{CRASH, UP, EXITED, nil},
 			{EXIT, UP, EXITED, nil},
@@ -169,9 +318,22 @@ func configureFSM(r *Runtime) {
 	// Whenever we transition back to DOWN or EXIT, we want to switch back to the default router.
 	// Transitioning to EXITED will result in a shutdown pretty snappily but we'll set the
 	// default router up again so there is a semblance of graceful handling.
-	f.OnTransitionTo(DOWN, func() { r.router = r.defaultRouter })
+	f.OnTransitionTo(DOWN, func() {
+		r.stopProbing()
+		r.setRouter(r.defaultRouter)
+		r.admin.SetManifest(nil)
+	})
 	f.OnTransitionTo(EXITED, func() {
-		r.router = r.defaultRouter
+		r.stopProbing()
+		r.setRouter(r.defaultRouter)
+		r.admin.SetManifest(nil)
+		if r.recorder != nil {
+			if err := r.recorder.Close(); err != nil {
+				r.logger.Errorf("Failed to close the request recording: %v", err)
+			}
+		}
+		close(r.exiting)
+		r.retiring.Wait()
 		close(r.done)
 	})
 
@@ -183,11 +345,25 @@ func (r *Runtime) State() fsm.State {
 	return r.fsm.State()
 }
 
+// Session returns what was agreed with the service's SDK when it was last started.
+func (r *Runtime) Session() *transport.Session {
+	r.processMutex.Lock()
+	defer r.processMutex.Unlock()
+	return r.session
+}
+
 func (r *Runtime) Router() Router {
-	return r.router
+	return r.active().Router
+}
+
+// Admin returns the handler for all of the admin routes. This can be served on a separate
+// listener, see PublicAdmin.
+func (r *Runtime) Admin() *admin.Admin {
+	return r.admin
 }
 
 func (r *Runtime) Start() {
+	r.startWatching()
 	r.Emit(START)
 }
 
@@ -196,69 +372,231 @@ func (r *Runtime) Stop() {
 }
 
 func (r *Runtime) Signal(signal os.Signal) {
-	r.supervisor.Signal(signal)
+	r.currentSupervisor().Signal(signal)
+}
+
+func (r *Runtime) currentSupervisor() Supervisor {
+	r.processMutex.Lock()
+	defer r.processMutex.Unlock()
+	return r.supervisor
+}
+
+// currentProcess returns the supervisor and client of the process which is currently serving
+// requests.
+func (r *Runtime) currentProcess() (Supervisor, Client) {
+	r.processMutex.Lock()
+	defer r.processMutex.Unlock()
+	return r.supervisor, r.client
 }
 
 func (r *Runtime) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.logger.Debugf("Serving request from runtime")
-	r.router.ServeHTTP(w, req)
+	if admin.IsAdminPath(req.URL.Path) {
+		if r.publicAdmin == nil {
+			http.NotFound(w, req)
+			return
+		}
+		r.publicAdmin.ServeHTTP(w, req)
+		return
+	}
+	for {
+		// A router only refuses a request when it has been replaced, so this will pick up its
+		// replacement next time around.
+		active := r.active()
+		if active.acquire() {
+			defer active.release()
+			active.ServeHTTP(w, req)
+			return
+		}
+	}
+}
+
+func (r *Runtime) rateLimits(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(r.limiter.Counters()); err != nil {
+		r.logger.Errorf("Failed to write rate limit counters: %v", err)
+	}
+}
+
+// concurrencyStats reports the requests in flight to the service and waiting to be sent to it.
+func (r *Runtime) concurrencyStats(w http.ResponseWriter, req *http.Request) {
+	stats := []concurrency.Stats{}
+	if r.concurrency != nil {
+		stats = r.concurrency.Stats()
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(stats); err != nil {
+		r.logger.Errorf("Failed to write concurrency stats: %v", err)
+	}
+}
+
+// purgeCache empties the response cache, or just the responses for a single route if one is given
+// with the route query parameter, e.g. ?route=/items/:id.
+func (r *Runtime) purgeCache(w http.ResponseWriter, req *http.Request) {
+	var purged int
+	if route := req.URL.Query().Get("route"); route != "" {
+		purged = r.cache.PurgeRoute(route)
+	} else {
+		purged = r.cache.Purge()
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(map[string]int{"purged": purged}); err != nil {
+		r.logger.Errorf("Failed to write cache purge response: %v", err)
+	}
 }
 
 func (r *Runtime) Emit(event fsm.Event) {
 	r.fsm.Emit(event)
 }
 
+// createAndConfigureRouter fetches the manifest from the service and builds a router for it. If
+// the manifest is invalid then the router from before the restart, if there is one, carries on
+// serving requests so that a mistake doesn't take the service down.
 func (r *Runtime) createAndConfigureRouter() error {
+	_, client := r.currentProcess()
+	router, m, err := r.newRouter(client)
+	var invalid manifest.InvalidManifest
+	if errors.As(err, &invalid) {
+		if current := r.Router(); current != nil && current != r.defaultRouter {
+			r.logger.Infof("Continuing to serve the previous routes")
+			return nil
+		}
+	}
+	if err != nil {
+		return err
+	}
+	r.setRouter(router)
+	r.admin.SetManifest(m)
+	r.admin.SetError(nil)
+	return nil
+}
+
+// newRouter fetches the manifest from the service behind the client and builds a router for it.
+func (r *Runtime) newRouter(client Client) (Router, *manifest.Manifest, error) {
 	r.logger.Debugf("Setting up new router")
-	r.router = r.routerFactory(r.logger, r.client)
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	router := r.routerFactory(r.logger, client)
+	ctx, cancel := context.WithTimeout(context.Background(), r.manifestTimeout)
 	defer cancel()
-	manifest, err := r.client.GetManifest(ctx)
+	m, err := client.GetManifest(ctx)
 	if err != nil {
 		r.logger.Debugf("Failed to fetch manifest")
-		return err
+		return nil, nil, err
 	}
-	r.router.Configure(manifest)
-	return nil
+	if err := router.Configure(m); err != nil {
+		r.logger.Errorf("Rejected the service manifest: %v", err)
+		r.admin.SetError(err)
+		return nil, nil, err
+	}
+	return router, m, nil
 }
 
 func (r *Runtime) startClientAndSupervisor() error {
 	r.logger.Debugf("Starting the client and supervisor")
-	r.socketAddress = r.socketFactory()
-	env := map[string]string{"FOLD_SOCK_ADDR": r.socketAddress}
-	if err := r.supervisor.Start(env); err != nil {
+	sup, client := r.currentProcess()
+	socketAddress := r.socketFactory()
+	r.processMutex.Lock()
+	r.socketAddress = socketAddress
+	r.processMutex.Unlock()
+	if _, err := r.startProcess(sup, client, socketAddress); err != nil {
 		return err
 	}
+	session, err := r.initialize(client)
+	if err == nil {
+		r.processMutex.Lock()
+		r.session = session
+		r.processMutex.Unlock()
+		err = r.createAndConfigureRouter()
+	}
+	var (
+		invalid      manifest.InvalidManifest
+		incompatible transport.IncompatibleSDK
+	)
+	if errors.As(err, &invalid) || errors.As(err, &incompatible) {
+		// This is called during a transition, so the transition to DOWN has to wait until it has
+		// finished.
+		go r.Emit(REJECT)
+		return nil
+	}
+	if err == nil {
+		r.startProbing(client)
+	}
+	return err
+}
+
+// startProcess starts the process and connects the client to it. It returns true if the client
+// was started, in which case it needs to be stopped too.
+func (r *Runtime) startProcess(
+	sup Supervisor,
+	client Client,
+	socketAddress string,
+) (bool, error) {
+	env := map[string]string{}
+	for key, value := range r.env {
+		env[key] = value
+	}
+	if r.debugger != nil {
+		for key, value := range r.debugger.Env() {
+			env[key] = value
+		}
+	}
+	env["FOLD_SOCK_ADDR"] = socketAddress
+	if err := sup.Start(env); err != nil {
+		return false, err
+	}
 	// Now that we've started the process, we want to set up a goroutine that waits for the process
 	// to terminate. When it does, we'll identify whether it was a crash or not and then emit
 	// the appropriate event.
 	go func() {
-		err := r.supervisor.Wait()
+		err := sup.Wait()
 		r.logger.Debugf("Process terminated")
+		var processErr supervisor.ProcessError
+		if errors.As(err, &processErr) && processErr.Report != nil {
+			r.recordCrash(processErr.Report)
+		}
+		if r.currentSupervisor() != sup {
+			// It was replaced by a blue/green reload, or never made it that far, so its end has
+			// no bearing on the state of the runtime.
+			return
+		}
 		// If the process was stopped by a signal then it was intentional and we want to exit,
-		// regardless of the configured process end behaviour.
+		// regardless of the configured process end behaviour. This goes through the FSM so that
+		// the clean up can't overlap with a transition which is replacing the process.
 		if errors.Is(err, supervisor.TerminatedBySignal) {
-			r.stopClientAndSupervisor()
-			r.exit()
+			r.Emit(SIGNALLED)
 			return
 		}
 		r.onProcessEnd()
 	}()
-	if err := r.client.Start(r.socketAddress); err != nil {
-		return err
+	if err := client.Start(socketAddress); err != nil {
+		return false, err
 	}
-	if err := r.createAndConfigureRouter(); err != nil {
-		return err
+	return true, nil
+}
+
+// initialize performs the handshake with the service's SDK.
+func (r *Runtime) initialize(client Client) (*transport.Session, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.manifestTimeout)
+	defer cancel()
+	session, err := client.Initialize(ctx, r.handshake)
+	if err != nil {
+		var incompatible transport.IncompatibleSDK
+		if errors.As(err, &incompatible) {
+			r.logger.Errorf("Rejected the service: %v", err)
+			r.admin.SetError(err)
+		}
+		return nil, err
 	}
-	return nil
+	return session, nil
 }
 
 func (r *Runtime) stopClientAndSupervisor() error {
 	r.logger.Debugf("Stopping the client and supervisor")
-	if err := r.client.Stop(); err != nil {
+	r.stopProbing()
+	sup, client := r.currentProcess()
+	if err := client.Stop(); err != nil {
 		return err
 	}
-	if err := r.supervisor.Stop(); err != nil {
+	if err := sup.Stop(); err != nil {
 		return err
 	}
 	// It's important to note that we don't wait here. Waiting is handled by the goroutine that
@@ -272,12 +610,71 @@ func (r *Runtime) restartClientAndSupervisor() error {
 	if err := r.stopClientAndSupervisor(); err != nil {
 		return err
 	}
+	r.processMutex.Lock()
+	r.restarts++
+	r.processMutex.Unlock()
 	if err := r.startClientAndSupervisor(); err != nil {
 		return err
 	}
 	return nil
 }
 
+// watchSettings are recorded by WatchDirs. The watcher isn't started until the runtime is, as it
+// calls onFileChange which relies on options applied after WatchDirs, such as BuildCommand.
+type watchSettings struct {
+	frequency time.Duration
+	dirs      []string
+	options   []watcher.Option
+}
+
+func (r *Runtime) startWatching() {
+	if r.watch == nil {
+		return
+	}
+	r.watchMutex.Lock()
+	defer r.watchMutex.Unlock()
+	if r.watcher != nil {
+		return
+	}
+	debouncer := watcher.NewDebouncer(r.watch.frequency, r.onFileChange)
+	w, err := watcher.NewWatcher(r.logger, r.watch.dirs, debouncer.OnChange, r.watch.options...)
+	if err != nil {
+		r.logger.Fatalf("Failed to setup hot reloading")
+	}
+	if err := w.Watch(); err != nil {
+		r.logger.Fatalf("Failed to setup hot reloading")
+	}
+	r.watcher = w
+}
+
+func (r *Runtime) stopWatching() {
+	r.watchMutex.Lock()
+	defer r.watchMutex.Unlock()
+	if r.watcher != nil {
+		r.watcher.Close()
+	}
+}
+
+// onFileChange runs the build, if there is one, and then reloads the service. The build happens
+// before the event is emitted so that the service keeps running while it builds and isn't
+// restarted at all if the build fails. The debouncer drops the changes made while this runs, but
+// the build's outputs should still be ignored by the watcher as polling only finds them later.
+func (r *Runtime) onFileChange() {
+	if r.builder != nil {
+		if err := r.builder.Build(); err != nil {
+			r.logger.Errorf("%v", err)
+			r.admin.SetError(err)
+			return
+		}
+		// A successful build clears the report of an earlier failure.
+		var failed watcher.BuildFailed
+		if errors.As(r.admin.Error(), &failed) {
+			r.admin.SetError(nil)
+		}
+	}
+	r.Emit(FILE_CHANGE)
+}
+
 func (r *Runtime) exit() {
 	r.Emit(EXIT)
 }
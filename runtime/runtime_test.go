@@ -1,8 +1,10 @@
 package runtime_test
 
 import (
+	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,10 +15,13 @@ import (
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
 	"github.com/foldsh/fold/runtime"
+	"github.com/foldsh/fold/runtime/concurrency"
+	"github.com/foldsh/fold/runtime/debugger"
 	"github.com/foldsh/fold/runtime/handler"
 	"github.com/foldsh/fold/runtime/mocks"
 	"github.com/foldsh/fold/runtime/router"
 	"github.com/foldsh/fold/runtime/supervisor"
+	"github.com/foldsh/fold/runtime/transport"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -36,6 +41,33 @@ func TestStartFromDOWNState(t *testing.T) {
 	}
 }
 
+func TestProcessEnvironment(t *testing.T) {
+	// The environment for the process combines the runtime's own variables with the debugger's.
+	os.Setenv("NODE_OPTIONS", "--max-old-space-size=512")
+	defer os.Unsetenv("NODE_OPTIONS")
+	d, err := debugger.NewDebugger(debugger.AUTO, 0, "node")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	ctx := makeRuntime(
+		t,
+		runtime.WithEnv(map[string]string{"LOG_LEVEL": "debug"}),
+		runtime.Debug(d),
+	)
+	defer ctx.Finish()
+	ctx.supervisor.On("Start", map[string]string{
+		"LOG_LEVEL":      "debug",
+		"NODE_OPTIONS":   "--max-old-space-size=512 --inspect=0.0.0.0:9229",
+		"FOLD_SOCK_ADDR": SOCKET,
+	}).Return(nil)
+	ctx.supervisor.On("Wait").Return(nil)
+	ctx.client.On("Start", SOCKET).Return(nil)
+	ctx.client.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
+	ctx.client.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
+	ctx.router.On("Configure", mock.Anything).Return(nil)
+	ctx.runtime.Start()
+}
+
 func TestStartFromUPState(t *testing.T) {
 	ctx := makeRuntime(t)
 	defer ctx.Finish()
@@ -138,6 +170,54 @@ func TestKeepAliveOnCrash(t *testing.T) {
 	}
 }
 
+func TestSignalWhileDown(t *testing.T) {
+	// The runtime stops a rejected process itself, which mustn't make it exit.
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+	ctx.runtime.Emit(runtime.SIGNALLED)
+	if ctx.runtime.State() != runtime.DOWN {
+		t.Errorf("Expected the runtime to stay DOWN but found %v", ctx.runtime.State())
+	}
+}
+
+func TestStopOnCrashSignal(t *testing.T) {
+	// A process killed by a signal the runtime didn't send, e.g. by the kernel running out of
+	// memory, still stops the runtime but its crash report is kept.
+	ctx := makeRuntime(t, runtime.CrashPolicy(runtime.KEEP_ALIVE))
+	defer ctx.Finish()
+
+	mockSignal := make(chan struct{})
+	ctx.supervisor.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
+	ctx.supervisor.On("Wait").Return(supervisor.ProcessError{
+		Reason: "process was killed",
+		Inner:  supervisor.TerminatedBySignal,
+		Report: &supervisor.CrashReport{ExitCode: -1, Signal: "killed"},
+	}).Run(func(args mock.Arguments) { <-mockSignal })
+	ctx.client.On("Start", SOCKET).Return(nil)
+	ctx.client.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
+	ctx.client.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
+	ctx.router.On("Configure", mock.Anything).Return(nil)
+
+	ctx.runtime.Start()
+	ctx.expectRuntimeStopTrace()
+	close(mockSignal)
+
+	<-ctx.done
+
+	if ctx.runtime.State() != runtime.EXITED {
+		t.Errorf(
+			"Expected the runtime to transition to EXIT but found %v",
+			ctx.runtime.State(),
+		)
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/crashes", nil)
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	if !strings.Contains(rw.Body.String(), `"signal":"killed"`) {
+		t.Errorf("Expected the crash report to be kept but found %s", rw.Body.String())
+	}
+}
+
 func TestStopOnSignal(t *testing.T) {
 	// We set the keep alive policy as it is only with that setting that this test is interesting.
 	ctx := makeRuntime(t, runtime.CrashPolicy(runtime.KEEP_ALIVE))
@@ -153,8 +233,9 @@ func TestStopOnSignal(t *testing.T) {
 		<-mockSignal
 	})
 	ctx.client.On("Start", SOCKET).Return(nil)
+	ctx.client.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
 	ctx.client.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
-	ctx.router.On("Configure", mock.Anything)
+	ctx.router.On("Configure", mock.Anything).Return(nil)
 
 	ctx.runtime.Start()
 
@@ -212,6 +293,448 @@ func TestDoRequestInEXITEDState(t *testing.T) {
 	ctx.runtime.ServeHTTP(rw, req)
 }
 
+func TestAdminRoutesServedByRuntime(t *testing.T) {
+	// The admin routes never make it to the router, they are handled by the runtime itself.
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 503 {
+		t.Errorf("Expected healthz to return 503 in the DOWN state but found %d", rw.Code)
+	}
+
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+	rw = httptest.NewRecorder()
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 200 {
+		t.Errorf("Expected healthz to return 200 in the UP state but found %d", rw.Code)
+	}
+}
+
+func TestHiddenAdminRoutes(t *testing.T) {
+	ctx := makeRuntime(t, runtime.PublicAdmin(runtime.NO_ADMIN))
+	defer ctx.Finish()
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 404 {
+		t.Errorf("Expected hidden healthz to return 404 but found %d", rw.Code)
+	}
+}
+
+func TestPrivateAdminRoutes(t *testing.T) {
+	// By default only the health checks are served alongside the service.
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/concurrency", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 404 {
+		t.Errorf("Expected private admin routes to return 404 but found %d", rw.Code)
+	}
+
+	ctx = makeRuntime(t, runtime.PublicAdmin(runtime.ALL_ADMIN))
+	rw = httptest.NewRecorder()
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 200 {
+		t.Errorf("Expected public admin routes to return 200 but found %d", rw.Code)
+	}
+}
+
+func TestConcurrencyStats(t *testing.T) {
+	ctx := makeRuntime(t, runtime.ConcurrencyLimit(concurrency.Settings{MaxInFlight: 5}))
+	defer ctx.Finish()
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/concurrency", nil)
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	expected := `[{"route":"*","limit":5,"inflight":0,"queued":0,"admitted":0,"rejected":0}]`
+	if rw.Code != 200 || strings.TrimSpace(rw.Body.String()) != expected {
+		t.Errorf("Expected the concurrency stats but found %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestProcessStats(t *testing.T) {
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+	// The restart is counted.
+	ctx.expectRuntimeStopTrace()
+	ctx.runtime.Start()
+
+	started := time.Unix(1600000000, 0).UTC()
+	ctx.supervisor.On("Stats").Return(func() *supervisor.ProcessStats {
+		return &supervisor.ProcessStats{PID: 42, Started: started, RSS: 1024, Threads: 3}
+	}, nil)
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/process", nil)
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	expected := `{"pid":42,"started":"2020-09-13T12:26:40Z","uptime":0,"cpu_time":0,"rss":1024,` +
+		`"fds":0,"threads":3,"restarts":1}`
+	if rw.Code != 200 || strings.TrimSpace(rw.Body.String()) != expected {
+		t.Errorf("Expected the process stats but found %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/_foldadmin/metrics", nil)
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	for _, metric := range []string{
+		"fold_process_up 1\n",
+		"fold_process_restarts_total 1\n",
+		"fold_process_resident_memory_bytes 1024\n",
+		"fold_process_threads 3\n",
+	} {
+		if !strings.Contains(rw.Body.String(), metric) {
+			t.Errorf("Expected the metrics to include %q but found %s", metric, rw.Body.String())
+		}
+	}
+}
+
+func TestProcessStatsWhenDown(t *testing.T) {
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+	ctx.supervisor.On("Stats").Return(nil, supervisor.NotRunning)
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/process", nil)
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	if rw.Code != 503 {
+		t.Errorf("Expected a 503 while the service is down but found %d", rw.Code)
+	}
+
+	rw = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/_foldadmin/metrics", nil)
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	if body := rw.Body.String(); !strings.Contains(body, "fold_process_up 0\n") ||
+		strings.Contains(body, "fold_process_threads") {
+		t.Errorf("Expected only the up and restart metrics but found %s", body)
+	}
+}
+
+func TestCrashReports(t *testing.T) {
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/crashes", nil)
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	if body := strings.TrimSpace(rw.Body.String()); rw.Code != 200 || body != "[]" {
+		t.Errorf("Expected no crash reports but found %d %s", rw.Code, body)
+	}
+	// They include the output of the service so they aren't served alongside it without a token.
+	rw = httptest.NewRecorder()
+	ctx.runtime.Admin().Shared().ServeHTTP(rw, req)
+	if rw.Code != 404 {
+		t.Errorf("Expected the crash reports not to be shared but found %d", rw.Code)
+	}
+
+	report := &supervisor.CrashReport{
+		Time:     time.Unix(1600000000, 0).UTC(),
+		PID:      42,
+		ExitCode: 2,
+		Stderr:   []string{"panic: boom"},
+		Stdout:   []string{},
+		Panic:    "panic: boom",
+	}
+	crashed := make(chan struct{})
+	ctx.supervisor.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
+	ctx.supervisor.On("Wait").Return(supervisor.ProcessError{
+		Reason: "process crashed",
+		Inner:  errors.New("exit status 2"),
+		Report: report,
+	}).Run(func(args mock.Arguments) { <-crashed })
+	ctx.client.On("Start", SOCKET).Return(nil)
+	ctx.client.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
+	ctx.client.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
+	ctx.router.On("Configure", mock.Anything).Return(nil)
+	ctx.runtime.Start()
+	close(crashed)
+	time.Sleep(10 * time.Millisecond)
+
+	rw = httptest.NewRecorder()
+	ctx.runtime.Admin().ServeHTTP(rw, req)
+	expected := `[{"time":"2020-09-13T12:26:40Z","pid":42,"exit_code":2,"uptime":0,` +
+		`"stderr":["panic: boom"],"stdout":[],"panic":"panic: boom"}]`
+	if body := strings.TrimSpace(rw.Body.String()); rw.Code != 200 || body != expected {
+		t.Errorf("Expected the crash report but found %d %s", rw.Code, body)
+	}
+}
+
+func TestInvalidManifestOnStart(t *testing.T) {
+	// Without a previous router to fall back on, the runtime should stop the process and go DOWN.
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+	invalid := manifest.InvalidManifest{Errors: []manifest.RouteError{
+		{Route: "/_foldadmin", Method: "GET", Reason: "reserved"},
+	}}
+	ctx.supervisor.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
+	ctx.supervisor.On("Wait").Return(nil)
+	ctx.client.On("Start", SOCKET).Return(nil)
+	ctx.client.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
+	ctx.client.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
+	ctx.router.On("Configure", mock.Anything).Return(invalid)
+	ctx.expectRuntimeStopTrace()
+	ctx.runtime.Start()
+
+	// The transition to DOWN happens asynchronously.
+	time.Sleep(10 * time.Millisecond)
+	if ctx.runtime.State() != runtime.DOWN {
+		t.Errorf("Expected an invalid manifest to leave the runtime DOWN, found %v", ctx.runtime.State())
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 503 {
+		t.Errorf("Expected healthz to return 503 but found %d", rw.Code)
+	}
+	if !strings.Contains(rw.Body.String(), `"routes":[{"route":"/_foldadmin"`) {
+		t.Errorf("Expected healthz to report the invalid routes but found %s", rw.Body.String())
+	}
+}
+
+func TestIncompatibleSDK(t *testing.T) {
+	ctx := makeRuntime(t, runtime.ServiceName("test"), runtime.Stage("LOCAL"))
+	defer ctx.Finish()
+	ctx.supervisor.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
+	ctx.supervisor.On("Wait").Return(nil)
+	ctx.client.On("Start", SOCKET).Return(nil)
+	ctx.client.
+		On("Initialize", mock.Anything, transport.Handshake{
+			ServiceName: "test",
+			Stage:       "LOCAL",
+			Features:    transport.Features,
+		}).
+		Return(nil, transport.IncompatibleSDK{SDK: "fold-go", Version: "v9.0.0", Min: 9, Max: 9})
+	ctx.expectRuntimeStopTrace()
+	ctx.runtime.Start()
+
+	time.Sleep(10 * time.Millisecond)
+	if ctx.runtime.State() != runtime.DOWN {
+		t.Errorf("Expected an incompatible SDK to leave the runtime DOWN, found %v", ctx.runtime.State())
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 503 || !strings.Contains(rw.Body.String(), "fold-go v9.0.0") {
+		t.Errorf("Expected healthz to report the error but found %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestInvalidManifestOnRestart(t *testing.T) {
+	// When the service is restarted with an invalid manifest the previous router is kept.
+	ctx := makeRuntime(t)
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+	previous := ctx.runtime.Router()
+
+	ctx.router.ExpectedCalls = nil
+	ctx.router.On("Configure", mock.Anything).Return(manifest.InvalidManifest{})
+	ctx.expectRuntimeStopTrace()
+	ctx.runtime.Start()
+
+	time.Sleep(10 * time.Millisecond)
+	if ctx.runtime.State() != runtime.UP {
+		t.Errorf("Expected the runtime to stay UP but found %v", ctx.runtime.State())
+	}
+	if ctx.runtime.Router() != previous {
+		t.Errorf("Expected the previous router to be kept")
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 200 || !strings.Contains(rw.Body.String(), "invalid manifest") {
+		t.Errorf("Expected healthz to report the error but found %d %s", rw.Code, rw.Body.String())
+	}
+}
+
+func TestBlueGreenReload(t *testing.T) {
+	next, nextClient := &mocks.Supervisor{}, &mocks.Client{}
+	ctx := makeRuntime(
+		t,
+		runtime.ReloadStrategy(runtime.BLUE_GREEN),
+		runtime.WithSupervisorFactory(func() runtime.Supervisor { return next }),
+		runtime.WithClientFactory(func() runtime.Client { return nextClient }),
+	)
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+
+	// A request is still in flight to the current process when the reload happens.
+	release := make(chan struct{})
+	ctx.router.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		<-release
+	}).Once()
+	req, _ := http.NewRequest("GET", "/items", nil)
+	go ctx.runtime.ServeHTTP(httptest.NewRecorder(), req)
+	time.Sleep(10 * time.Millisecond)
+
+	next.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
+	next.On("Wait").Return(nil)
+	nextClient.On("Start", SOCKET).Return(nil)
+	nextClient.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
+	nextClient.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
+	ctx.expectRuntimeStopTrace()
+	go ctx.runtime.Start()
+
+	// The previous process can't be stopped until its request has completed.
+	time.Sleep(10 * time.Millisecond)
+	ctx.client.AssertNotCalled(t, "Stop")
+	ctx.supervisor.AssertNotCalled(t, "Stop")
+	close(release)
+	time.Sleep(10 * time.Millisecond)
+	if ctx.runtime.State() != runtime.UP {
+		t.Errorf("Expected the runtime to be UP but found %v", ctx.runtime.State())
+	}
+
+	// From now on the new process is the one that gets stopped.
+	next.On("Stop").Return(nil)
+	nextClient.On("Stop").Return(nil)
+	ctx.runtime.Stop()
+	time.Sleep(10 * time.Millisecond)
+	next.AssertExpectations(t)
+	nextClient.AssertExpectations(t)
+}
+
+func TestStopWhileDraining(t *testing.T) {
+	next, nextClient := &mocks.Supervisor{}, &mocks.Client{}
+	ctx := makeRuntime(
+		t,
+		runtime.ReloadStrategy(runtime.BLUE_GREEN),
+		runtime.WithSupervisorFactory(func() runtime.Supervisor { return next }),
+		runtime.WithClientFactory(func() runtime.Client { return nextClient }),
+	)
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+
+	// The request to the current process never completes by itself.
+	release := make(chan struct{})
+	defer close(release)
+	ctx.router.On("ServeHTTP", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		<-release
+	}).Once()
+	req, _ := http.NewRequest("GET", "/items", nil)
+	go ctx.runtime.ServeHTTP(httptest.NewRecorder(), req)
+	time.Sleep(10 * time.Millisecond)
+
+	next.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
+	next.On("Wait").Return(nil)
+	next.On("Stop").Return(nil)
+	nextClient.On("Start", SOCKET).Return(nil)
+	nextClient.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
+	nextClient.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
+	nextClient.On("Stop").Return(nil)
+	ctx.expectRuntimeStopTrace()
+
+	// Neither the reload nor stopping the runtime wait for the previous process to drain.
+	stopped := make(chan struct{})
+	go func() {
+		ctx.runtime.Start()
+		ctx.runtime.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the runtime to stop while the previous process was draining")
+	}
+	ctx.client.AssertCalled(t, "Stop")
+	ctx.supervisor.AssertCalled(t, "Stop")
+	next.AssertCalled(t, "Stop")
+}
+
+func TestBlueGreenReloadFailure(t *testing.T) {
+	next, nextClient := &mocks.Supervisor{}, &mocks.Client{}
+	ctx := makeRuntime(
+		t,
+		runtime.ReloadStrategy(runtime.BLUE_GREEN),
+		runtime.WithSupervisorFactory(func() runtime.Supervisor { return next }),
+		runtime.WithClientFactory(func() runtime.Client { return nextClient }),
+	)
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+
+	// The new process is incompatible, so it is stopped and the current one carries on.
+	next.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
+	next.On("Wait").Return(nil)
+	next.On("Stop").Return(nil)
+	nextClient.On("Start", SOCKET).Return(nil)
+	nextClient.
+		On("Initialize", mock.Anything, mock.Anything).
+		Return(nil, transport.IncompatibleSDK{SDK: "fold-go", Version: "v9.0.0", Min: 9, Max: 9})
+	nextClient.On("Stop").Return(nil)
+	ctx.runtime.Start()
+
+	if ctx.runtime.State() != runtime.UP {
+		t.Errorf("Expected the runtime to still be UP but found %v", ctx.runtime.State())
+	}
+	if ctx.runtime.Router() != ctx.router {
+		t.Errorf("Expected the previous router to still be serving requests")
+	}
+	ctx.client.AssertNotCalled(t, "Stop")
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if !strings.Contains(rw.Body.String(), "fold-go v9.0.0") {
+		t.Errorf("Expected healthz to report the failed reload but found %s", rw.Body.String())
+	}
+	time.Sleep(10 * time.Millisecond)
+	next.AssertExpectations(t)
+	nextClient.AssertExpectations(t)
+}
+
+func TestUnhealthyProcessIsRestarted(t *testing.T) {
+	ctx := makeRuntime(t, runtime.HealthCheck(5*time.Millisecond, 5*time.Millisecond, 2))
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.expectRuntimeStopTrace()
+	ctx.client.On("Check", mock.Anything).Return(errors.New("deadline exceeded")).Twice()
+	ctx.client.On("Check", mock.Anything).Return(nil)
+	ctx.runtime.Start()
+
+	time.Sleep(50 * time.Millisecond)
+	if ctx.runtime.State() != runtime.UP {
+		t.Errorf("Expected the runtime to be UP but found %v", ctx.runtime.State())
+	}
+	ctx.supervisor.AssertNumberOfCalls(t, "Stop", 1)
+	ctx.supervisor.AssertNumberOfCalls(t, "Start", 2)
+	// Stopping the runtime stops the health checks too.
+	ctx.runtime.Stop()
+}
+
+func TestReadyzReportsFailedHealthChecks(t *testing.T) {
+	ctx := makeRuntime(t, runtime.HealthCheck(5*time.Millisecond, 5*time.Millisecond, 100))
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.client.On("Check", mock.Anything).Return(errors.New("deadline exceeded"))
+	ctx.runtime.Start()
+	time.Sleep(20 * time.Millisecond)
+
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/readyz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 503 || !strings.Contains(rw.Body.String(), "deadline exceeded") {
+		t.Errorf("Expected readyz to report the failure but found %d %s", rw.Code, rw.Body.String())
+	}
+	// The process hasn't reached the failure threshold so it is still considered healthy.
+	rw = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if rw.Code != 200 || !strings.Contains(rw.Body.String(), "deadline exceeded") {
+		t.Errorf("Expected healthz to report the failure but found %d %s", rw.Code, rw.Body.String())
+	}
+	ctx.expectRuntimeStopTrace()
+	ctx.runtime.Stop()
+}
+
 func TestHandleSignal(t *testing.T) {
 	ctx := makeRuntime(t)
 	defer ctx.Finish()
@@ -256,9 +779,13 @@ func TestHotReloadFromDOWNState(t *testing.T) {
 
 	// Now we set up the runtime and enable filesystem watching. When a change happens, we're
 	// expecting to see a restart happen.
-	ctx := makeRuntime(t, runtime.WatchDir(0, testDir))
+	// The watcher only runs once the runtime has been started, so it is taken DOWN by a crash.
+	ctx := makeRuntime(t, runtime.WatchDir(0, testDir), runtime.CrashPolicy(runtime.KEEP_ALIVE))
 	defer cleanUpWatchers(ctx)
 	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+	ctx.runtime.Emit(runtime.CRASH)
 
 	// As the runtime is currently down, we only expect to see a start up trace on the change.
 	ctx.expectRuntimeStartTrace()
@@ -270,6 +797,36 @@ func TestHotReloadFromDOWNState(t *testing.T) {
 	}
 }
 
+func TestFailedBuildDoesNotReload(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "hot-reload-failed-build")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	ctx := makeRuntime(t, runtime.WatchDir(0, testDir), runtime.BuildCommand("exit 1"))
+	defer cleanUpWatchers(ctx)
+	defer ctx.Finish()
+	ctx.expectRuntimeStartTrace()
+	ctx.runtime.Start()
+
+	// The build fails so there should be no restart, the process just keeps running.
+	file := filepath.Join(testDir, "new-file")
+	if err := ioutil.WriteFile(file, []byte{}, 0644); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if ctx.runtime.State() != runtime.UP {
+		t.Errorf("Expected the runtime to still be UP but found %v", ctx.runtime.State())
+	}
+	rw := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/_foldadmin/healthz", nil)
+	ctx.runtime.ServeHTTP(rw, req)
+	if !strings.Contains(rw.Body.String(), "build command 'exit 1' failed") {
+		t.Errorf("Expected healthz to report the failed build but found %s", rw.Body.String())
+	}
+}
+
 func cleanUpWatchers(ctx *testContext) {
 	// The library we're using to watch for file changes behaves a little oddly when there are
 	// multiple watchers on the go. This utility function stops the runtime, which invokes the
@@ -307,8 +864,9 @@ func (c *testContext) expectRuntimeStartTrace() {
 	c.supervisor.On("Start", map[string]string{"FOLD_SOCK_ADDR": SOCKET}).Return(nil)
 	c.supervisor.On("Wait").Return(nil)
 	c.client.On("Start", SOCKET).Return(nil)
+	c.client.On("Initialize", mock.Anything, mock.Anything).Return(&transport.Session{}, nil)
 	c.client.On("GetManifest", mock.Anything).Return(&manifest.Manifest{}, nil)
-	c.router.On("Configure", mock.Anything)
+	c.router.On("Configure", mock.Anything).Return(nil)
 }
 
 func (c *testContext) expectRuntimeStopTrace() {
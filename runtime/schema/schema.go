@@ -0,0 +1,412 @@
+// Package schema validates requests against the JSON Schemas declared on routes in the service
+// manifest. It supports the commonly used validation keywords of JSON Schema draft 7, the type,
+// object, array, string, number, enum and combinator keywords. Schemas which use any other
+// validation keyword, e.g. $ref or format, are rejected rather than silently accepting requests
+// the schema would not. Annotations such as title and description are ignored.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	Type                 types              `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                *interface{}       `json:"const"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *additional        `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum"`
+	AllOf                []*Schema          `json:"allOf"`
+	AnyOf                []*Schema          `json:"anyOf"`
+	OneOf                []*Schema          `json:"oneOf"`
+	Not                  *Schema            `json:"not"`
+
+	pattern *regexp.Regexp
+}
+
+// The draft 7 validation keywords which aren't supported.
+var unsupported = map[string]struct{}{
+	"$ref":              {},
+	"format":            {},
+	"multipleOf":        {},
+	"uniqueItems":       {},
+	"contains":          {},
+	"additionalItems":   {},
+	"minProperties":     {},
+	"maxProperties":     {},
+	"patternProperties": {},
+	"propertyNames":     {},
+	"dependencies":      {},
+	"if":                {},
+	"then":              {},
+	"else":              {},
+	"contentEncoding":   {},
+	"contentMediaType":  {},
+}
+
+// ValidationError describes a single part of a value which doesn't satisfy the schema. The path
+// is a JSON pointer to the invalid value.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// InvalidSchema is returned when a schema can't be compiled.
+type InvalidSchema struct {
+	Reason string
+}
+
+func (is InvalidSchema) Error() string {
+	return fmt.Sprintf("invalid JSON schema: %s", is.Reason)
+}
+
+// Compile parses a JSON Schema.
+func Compile(raw string) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, InvalidSchema{err.Error()}
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UnmarshalJSON rejects the keywords which aren't supported before decoding the schema.
+func (s *Schema) UnmarshalJSON(bs []byte) error {
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &keywords); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	// Sorting the keywords keeps the error stable when there is more than one.
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := unsupported[name]; ok {
+			return fmt.Errorf("unsupported keyword %q", name)
+		}
+	}
+	// The alias has no methods, so decoding into it doesn't recurse back into this one.
+	type plain Schema
+	return json.Unmarshal(bs, (*plain)(s))
+}
+
+func (s *Schema) compile() error {
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return InvalidSchema{fmt.Sprintf("pattern %q: %v", s.Pattern, err)}
+		}
+		s.pattern = re
+	}
+	for _, t := range s.Type {
+		switch t {
+		case "null", "boolean", "object", "array", "number", "integer", "string":
+		default:
+			return InvalidSchema{fmt.Sprintf("unknown type %q", t)}
+		}
+	}
+	children := append(append(append([]*Schema{}, s.AllOf...), s.AnyOf...), s.OneOf...)
+	children = append(children, s.Items, s.Not)
+	if s.AdditionalProperties != nil {
+		children = append(children, s.AdditionalProperties.schema)
+	}
+	for _, property := range s.Properties {
+		children = append(children, property)
+	}
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		if err := child.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a value decoded from JSON against the schema and returns every error found.
+func (s *Schema) Validate(v interface{}) []ValidationError {
+	var errs []ValidationError
+	s.validate("", v, &errs)
+	return errs
+}
+
+// ValidateQuery validates query parameters. They are converted to an object first, a parameter
+// is a string unless the schema says its property is a number, integer, boolean or array.
+func (s *Schema) ValidateQuery(query url.Values) []ValidationError {
+	var errs []ValidationError
+	obj := make(map[string]interface{})
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		values := query[key]
+		var property *Schema
+		if s.Properties != nil {
+			property = s.Properties[key]
+		}
+		if property != nil && property.Type.allows("array") {
+			items := make([]interface{}, len(values))
+			for i, value := range values {
+				items[i] = coerce(property.Items, value)
+			}
+			obj[key] = items
+			continue
+		}
+		if len(values) > 1 {
+			errs = append(errs, ValidationError{pointer("", key), "must only be given once"})
+		}
+		obj[key] = coerce(property, values[0])
+	}
+	s.validate("", obj, &errs)
+	return errs
+}
+
+// coerce converts a query parameter to the type the schema expects, if it is possible. Values
+// which can't be converted are left as strings so that validation reports the type error.
+func coerce(s *Schema, value string) interface{} {
+	if s == nil {
+		return value
+	}
+	switch {
+	case s.Type.allows("integer"), s.Type.allows("number"):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case s.Type.allows("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *[]ValidationError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, ValidationError{displayPath(path), fmt.Sprintf(format, args...)})
+	}
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		fail("must be of type %s", strings.Join(s.Type, " or "))
+		// None of the other keywords are meaningful if the type is wrong.
+		return
+	}
+	if s.Enum != nil && !contains(s.Enum, v) {
+		fail("must be one of the enumerated values")
+	}
+	if s.Const != nil && !equal(*s.Const, v) {
+		fail("must be equal to the constant value")
+	}
+	switch value := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(path, value, errs)
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				s.Items.validate(pointer(path, strconv.Itoa(i)), item, errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			fail("must match the pattern %s", s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && value < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && value <= *s.ExclusiveMinimum {
+			fail("must be greater than %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && value >= *s.ExclusiveMaximum {
+			fail("must be less than %v", *s.ExclusiveMaximum)
+		}
+	}
+	for _, sub := range s.AllOf {
+		sub.validate(path, v, errs)
+	}
+	if len(s.AnyOf) > 0 && s.countValid(s.AnyOf, v) == 0 {
+		fail("must match at least one of the schemas in anyOf")
+	}
+	if len(s.OneOf) > 0 && s.countValid(s.OneOf, v) != 1 {
+		fail("must match exactly one of the schemas in oneOf")
+	}
+	if s.Not != nil && len(s.Not.Validate(v)) == 0 {
+		fail("must not match the schema in not")
+	}
+}
+
+func (s *Schema) validateObject(path string, obj map[string]interface{}, errs *[]ValidationError) {
+	for _, name := range s.Required {
+		if _, ok := obj[name]; !ok {
+			*errs = append(*errs, ValidationError{pointer(path, name), "is required"})
+		}
+	}
+	// Sorting the keys keeps the order of the errors stable.
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if property, ok := s.Properties[key]; ok {
+			property.validate(pointer(path, key), obj[key], errs)
+			continue
+		}
+		if s.AdditionalProperties == nil {
+			continue
+		}
+		if s.AdditionalProperties.schema != nil {
+			s.AdditionalProperties.schema.validate(pointer(path, key), obj[key], errs)
+		} else if !s.AdditionalProperties.allowed {
+			*errs = append(*errs, ValidationError{pointer(path, key), "is not allowed"})
+		}
+	}
+}
+
+func (s *Schema) countValid(schemas []*Schema, v interface{}) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.Validate(v)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// types handles the type keyword, which is either a single type or a list of them.
+type types []string
+
+func (t *types) UnmarshalJSON(bs []byte) error {
+	var single string
+	if err := json.Unmarshal(bs, &single); err == nil {
+		*t = types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(bs, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+func (t types) allows(name string) bool {
+	for _, typ := range t {
+		if typ == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (t types) matches(v interface{}) bool {
+	for _, typ := range t {
+		switch value := v.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case float64:
+			if typ == "number" || (typ == "integer" && value == math.Trunc(value)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// additional handles additionalProperties, which is either a boolean or a schema.
+type additional struct {
+	allowed bool
+	schema  *Schema
+}
+
+func (a *additional) UnmarshalJSON(bs []byte) error {
+	if err := json.Unmarshal(bs, &a.allowed); err == nil {
+		return nil
+	}
+	a.schema = &Schema{}
+	return json.Unmarshal(bs, a.schema)
+}
+
+func contains(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if equal(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+func pointer(path, key string) string {
+	key = strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+	return path + "/" + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
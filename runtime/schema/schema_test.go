@@ -0,0 +1,167 @@
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+)
+
+const itemSchema = `{
+	"type": "object",
+	"required": ["name", "tags"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 1, "maxLength": 10},
+		"price": {"type": "number", "minimum": 0},
+		"quantity": {"type": "integer", "exclusiveMaximum": 100},
+		"tags": {"type": "array", "minItems": 1, "items": {"type": "string", "pattern": "^[a-z]+$"}},
+		"kind": {"enum": ["book", "film"]},
+		"owner": {"type": ["string", "null"]}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s, err := Compile(itemSchema)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	cases := []struct {
+		name     string
+		body     string
+		expected []ValidationError
+	}{
+		{
+			"Valid bodies have no errors",
+			`{"name": "foo", "price": 1.5, "quantity": 2, "tags": ["a"], "kind": "book", "owner": null}`,
+			nil,
+		},
+		{
+			"Required properties are reported",
+			`{"price": 1}`,
+			[]ValidationError{{"/name", "is required"}, {"/tags", "is required"}},
+		},
+		{
+			"Types are checked",
+			`{"name": 1, "tags": "a", "quantity": 1.5, "owner": 2}`,
+			[]ValidationError{
+				{"/name", "must be of type string"},
+				{"/owner", "must be of type string or null"},
+				{"/quantity", "must be of type integer"},
+				{"/tags", "must be of type array"},
+			},
+		},
+		{
+			"Nested values are validated",
+			`{"name": "", "tags": ["a", "B"], "price": -1, "quantity": 100, "kind": "game"}`,
+			[]ValidationError{
+				{"/kind", "must be one of the enumerated values"},
+				{"/name", "must be at least 1 characters long"},
+				{"/price", "must be at least 0"},
+				{"/quantity", "must be less than 100"},
+				{"/tags/1", "must match the pattern ^[a-z]+$"},
+			},
+		},
+		{
+			"Additional properties can be forbidden",
+			`{"name": "foo", "tags": ["a"], "colour": "red"}`,
+			[]ValidationError{{"/colour", "is not allowed"}},
+		},
+		{
+			"The root is checked",
+			`[]`,
+			[]ValidationError{{"/", "must be of type object"}},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body interface{}
+			if err := json.Unmarshal([]byte(tc.body), &body); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			testutils.Diff(t, tc.expected, s.Validate(body), "Validation errors did not match")
+		})
+	}
+}
+
+func TestCombinators(t *testing.T) {
+	s, err := Compile(`{
+		"anyOf": [{"type": "string"}, {"type": "integer"}],
+		"not": {"const": "forbidden"}
+	}`)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if errs := s.Validate("foo"); len(errs) != 0 {
+		t.Errorf("Expected foo to be valid but got %v", errs)
+	}
+	if errs := s.Validate(true); len(errs) != 1 {
+		t.Errorf("Expected true to fail anyOf but got %v", errs)
+	}
+	if errs := s.Validate("forbidden"); len(errs) != 1 {
+		t.Errorf("Expected forbidden to fail not but got %v", errs)
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	s, err := Compile(`{
+		"type": "object",
+		"required": ["page"],
+		"properties": {
+			"page": {"type": "integer", "minimum": 1},
+			"draft": {"type": "boolean"},
+			"tag": {"type": "array", "items": {"type": "string"}}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	valid, _ := url.ParseQuery("page=2&draft=true&tag=a&tag=b&other=x")
+	if errs := s.ValidateQuery(valid); len(errs) != 0 {
+		t.Errorf("Expected the query to be valid but got %v", errs)
+	}
+	invalid, _ := url.ParseQuery("page=zero&draft=maybe")
+	testutils.Diff(
+		t,
+		[]ValidationError{
+			{"/draft", "must be of type boolean"},
+			{"/page", "must be of type integer"},
+		},
+		s.ValidateQuery(invalid),
+		"Validation errors did not match",
+	)
+	missing, _ := url.ParseQuery("")
+	testutils.Diff(
+		t,
+		[]ValidationError{{"/page", "is required"}},
+		s.ValidateQuery(missing),
+		"Validation errors did not match",
+	)
+}
+
+func TestInvalidSchema(t *testing.T) {
+	cases := []string{
+		`{"type": "foo"}`,
+		`{"pattern": "("}`,
+		`[`,
+		`{"$ref": "#/definitions/item"}`,
+		`{"type": "string", "format": "email"}`,
+		`{"properties": {"tags": {"type": "array", "uniqueItems": true}}}`,
+		`{"additionalProperties": {"format": "date"}}`,
+		`{"anyOf": [{"if": {"type": "string"}}]}`,
+	}
+	for _, raw := range cases {
+		_, err := Compile(raw)
+		var invalidSchema InvalidSchema
+		if !errors.As(err, &invalidSchema) {
+			t.Errorf("Expected %s to be invalid but got %v", raw, err)
+		}
+	}
+	// Annotations don't affect validation so they are allowed.
+	raw := `{"title": "Item", "description": "An item", "properties": {"id": {"default": "1"}}}`
+	if _, err := Compile(raw); err != nil {
+		t.Errorf("Expected %s to be valid but got %v", raw, err)
+	}
+}
@@ -0,0 +1,123 @@
+// Package static serves the directories of static files declared in a service's manifest, so that
+// assets such as a single page app don't have to be streamed through the service.
+package static
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/compression"
+)
+
+// FallbackCacheControl is sent with the fallback file so that clients always check for a new
+// version of it.
+const FallbackCacheControl = "no-cache"
+
+// Mount serves the files in a directory under a URL prefix.
+type Mount struct {
+	prefix       string
+	dir          http.Dir
+	fallback     string
+	cacheControl string
+}
+
+func NewMount(m *manifest.StaticMount) *Mount {
+	return &Mount{
+		prefix:       strings.TrimSuffix(m.Prefix, "/"),
+		dir:          http.Dir(m.Dir),
+		fallback:     m.Fallback,
+		cacheControl: m.CacheControl,
+	}
+}
+
+// Match returns true if the path is under the mount's prefix.
+func (m *Mount) Match(urlPath string) bool {
+	return m.prefix == "" || urlPath == m.prefix || strings.HasPrefix(urlPath, m.prefix+"/")
+}
+
+// Prefix returns the prefix the mount is served under, without a trailing slash.
+func (m *Mount) Prefix() string {
+	return m.prefix
+}
+
+// Serve responds with the file the request is for. It returns false, without writing anything,
+// if there is no such file and no fallback.
+func (m *Mount) Serve(w http.ResponseWriter, r *http.Request) bool {
+	name := path.Clean("/" + strings.TrimPrefix(r.URL.Path, m.prefix))
+	cacheControl := m.cacheControl
+	name, file, info, ok := m.open(name)
+	if !ok && m.fallback != "" {
+		cacheControl = FallbackCacheControl
+		name, file, info, ok = m.open(path.Clean("/" + m.fallback))
+	}
+	if !ok {
+		return false
+	}
+	defer file.Close()
+
+	headers := w.Header()
+	if cacheControl != "" {
+		headers.Set("Cache-Control", cacheControl)
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	_, gz, gzInfo, hasGz := m.open(name + ".gz")
+	if hasGz {
+		defer gz.Close()
+		headers.Add("Vary", "Accept-Encoding")
+	}
+	if hasGz && compression.Accepts(r.Header.Get("Accept-Encoding"), "gzip") {
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		headers.Set("Content-Type", contentType)
+		headers.Set("Content-Encoding", "gzip")
+		headers.Set("ETag", etag(gzInfo, "gz"))
+		http.ServeContent(w, r, name, gzInfo.ModTime(), gz)
+		return true
+	}
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+	headers.Set("ETag", etag(info, ""))
+	// ServeContent takes care of range and conditional requests.
+	http.ServeContent(w, r, name, info.ModTime(), file)
+	return true
+}
+
+// open opens the file with the name, or the index.html of a directory, and returns the name of
+// the file it opened. It returns false if there is no such file, directories are never listed.
+func (m *Mount) open(name string) (string, http.File, os.FileInfo, bool) {
+	file, err := m.dir.Open(name)
+	if err != nil {
+		return "", nil, nil, false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return "", nil, nil, false
+	}
+	if info.IsDir() {
+		file.Close()
+		if path.Base(name) == "index.html" {
+			return "", nil, nil, false
+		}
+		return m.open(path.Join(name, "index.html"))
+	}
+	return name, file, info, true
+}
+
+// etag identifies a version of a file by its size and modification time, which is much cheaper
+// than hashing it. Each encoding of a file is a different representation so it gets its own tag.
+func etag(info os.FileInfo, encoding string) string {
+	tag := fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size())
+	if encoding != "" {
+		tag = fmt.Sprintf("%s-%s", tag, encoding)
+	}
+	return fmt.Sprintf(`"%s"`, tag)
+}
@@ -0,0 +1,123 @@
+package static_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/static"
+)
+
+func TestServe(t *testing.T) {
+	dir := makeDir(t, map[string]string{
+		"index.html":      "<html>app</html>",
+		"app.js":          "console.log('fold')",
+		"app.js.gz":       "gzipped",
+		"docs/index.html": "<html>docs</html>",
+		"images/.keep":    "",
+	})
+	mount := static.NewMount(&manifest.StaticMount{
+		Prefix:       "/app/",
+		Dir:          dir,
+		CacheControl: "public, max-age=60",
+	})
+	cases := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		served         bool
+		body           string
+		contentType    string
+	}{
+		{"Files", "/app/app.js", "", true, "console.log('fold')", "application/javascript"},
+		{"Precompressed files", "/app/app.js", "gzip, br", true, "gzipped", "application/javascript"},
+		{"The prefix", "/app", "", true, "<html>app</html>", "text/html; charset=utf-8"},
+		{"Directories", "/app/docs/", "", true, "<html>docs</html>", "text/html; charset=utf-8"},
+		{"Directories without an index", "/app/images", "", false, "", ""},
+		{"Missing files", "/app/missing.js", "", false, "", ""},
+		{"Paths outside the directory", "/app/../../etc/passwd", "", false, "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.URL.Path = tc.path
+			if tc.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			w := httptest.NewRecorder()
+			served := mount.Serve(w, r)
+			if served != tc.served {
+				t.Fatalf("Expected the file to be served: %v", tc.served)
+			}
+			if !served {
+				return
+			}
+			testutils.Diff(t, 200, w.Code, "Status did not match")
+			testutils.Diff(t, tc.body, w.Body.String(), "Body did not match")
+			// Some systems give javascript a different content type.
+			if tc.contentType != "application/javascript" {
+				testutils.Diff(t, tc.contentType, w.Header().Get("Content-Type"), "Content type")
+			}
+			testutils.Diff(t, "public, max-age=60", w.Header().Get("Cache-Control"), "Cache")
+			if tc.acceptEncoding != "" && w.Header().Get("Content-Encoding") != "gzip" {
+				t.Errorf("Expected the gzip variant to be served")
+			}
+		})
+	}
+}
+
+func TestFallback(t *testing.T) {
+	dir := makeDir(t, map[string]string{"index.html": "<html>app</html>"})
+	mount := static.NewMount(&manifest.StaticMount{
+		Prefix:       "/",
+		Dir:          dir,
+		Fallback:     "index.html",
+		CacheControl: "public, max-age=60",
+	})
+	w := httptest.NewRecorder()
+	if !mount.Serve(w, httptest.NewRequest("GET", "/items/1", nil)) {
+		t.Fatalf("Expected the fallback to be served")
+	}
+	testutils.Diff(t, "<html>app</html>", w.Body.String(), "Body did not match")
+	testutils.Diff(t, static.FallbackCacheControl, w.Header().Get("Cache-Control"), "Cache")
+}
+
+func TestRangeAndConditionalRequests(t *testing.T) {
+	dir := makeDir(t, map[string]string{"data.txt": "0123456789"})
+	mount := static.NewMount(&manifest.StaticMount{Prefix: "/", Dir: dir})
+
+	r := httptest.NewRequest("GET", "/data.txt", nil)
+	r.Header.Set("Range", "bytes=2-5")
+	w := httptest.NewRecorder()
+	mount.Serve(w, r)
+	testutils.Diff(t, 206, w.Code, "Status did not match")
+	testutils.Diff(t, "2345", w.Body.String(), "Body did not match")
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("Expected the response to have an ETag")
+	}
+
+	r = httptest.NewRequest("GET", "/data.txt", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	mount.Serve(w, r)
+	testutils.Diff(t, 304, w.Code, "Status did not match")
+}
+
+func makeDir(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	return dir
+}
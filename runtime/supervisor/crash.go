@@ -0,0 +1,74 @@
+package supervisor
+
+import (
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// The number of lines of each output stream the supervisor keeps. Only the last CrashLines
+	// are included in a report but the rest are searched for a panic.
+	outputLines = 1000
+	// DefaultCrashLines is the number of lines of output included in a crash report by default.
+	DefaultCrashLines = 50
+)
+
+// CrashReport describes why the process crashed and what it was doing at the time.
+type CrashReport struct {
+	Time time.Time `json:"time"`
+	PID  int       `json:"pid"`
+	// The exit code of the process, or -1 if it was killed by a signal.
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal,omitempty"`
+	// How long the process ran for in seconds.
+	Uptime float64 `json:"uptime"`
+	// The last lines the process wrote to each stream.
+	Stderr []string `json:"stderr"`
+	Stdout []string `json:"stdout"`
+	// The trace of the Go panic or fatal error which caused the crash, if there was one.
+	Panic string `json:"panic,omitempty"`
+}
+
+// Signals which only a crash, or the kernel running out of memory, would kill the process with.
+// Any other signal is taken to mean that the process was stopped on purpose.
+var crashSignals = map[syscall.Signal]bool{
+	syscall.SIGABRT: true,
+	syscall.SIGBUS:  true,
+	syscall.SIGFPE:  true,
+	syscall.SIGILL:  true,
+	syscall.SIGKILL: true,
+	syscall.SIGSEGV: true,
+}
+
+func (s *Supervisor) crashReport(pid, exitCode int, signal string) *CrashReport {
+	s.stateMutex.Lock()
+	started := s.started
+	s.stateMutex.Unlock()
+	now := time.Now()
+	lines := s.CrashLines
+	if lines <= 0 {
+		lines = DefaultCrashLines
+	}
+	return &CrashReport{
+		Time:     now,
+		PID:      pid,
+		ExitCode: exitCode,
+		Signal:   signal,
+		Uptime:   now.Sub(started).Seconds(),
+		Stderr:   s.stderr.Last(lines),
+		Stdout:   s.stdout.Last(lines),
+		Panic:    findPanic(s.stderr.Last(outputLines)),
+	}
+}
+
+// findPanic returns the last Go panic or fatal error in the output, along with the stack traces
+// which follow it.
+func findPanic(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.HasPrefix(lines[i], "panic: ") || strings.HasPrefix(lines[i], "fatal error: ") {
+			return strings.TrimSpace(strings.Join(lines[i:], "\n"))
+		}
+	}
+	return ""
+}
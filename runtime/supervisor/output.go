@@ -0,0 +1,79 @@
+package supervisor
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Lines longer than this are truncated so that a single huge line can't use up the memory.
+const maxLineLength = 4096
+
+// lineBuffer keeps the most recent lines written to it in a ring.
+type lineBuffer struct {
+	mutex   sync.Mutex
+	lines   []string
+	next    int
+	full    bool
+	partial []byte
+}
+
+func newLineBuffer(size int) *lineBuffer {
+	return &lineBuffer{lines: make([]string, size)}
+}
+
+func (b *lineBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	rest := p
+	for {
+		i := bytes.IndexByte(rest, '\n')
+		if i == -1 {
+			b.appendPartial(rest)
+			return len(p), nil
+		}
+		b.appendPartial(rest[:i])
+		b.push(string(bytes.TrimSuffix(b.partial, []byte("\r"))))
+		b.partial = b.partial[:0]
+		rest = rest[i+1:]
+	}
+}
+
+func (b *lineBuffer) appendPartial(p []byte) {
+	if room := maxLineLength - len(b.partial); len(p) > room {
+		p = p[:room]
+	}
+	b.partial = append(b.partial, p...)
+}
+
+func (b *lineBuffer) push(line string) {
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Last returns up to the n most recent lines, oldest first, including a final line which hasn't
+// been terminated yet.
+func (b *lineBuffer) Last(n int) []string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	lines := append([]string{}, b.lines[:b.next]...)
+	if b.full {
+		lines = append(append([]string{}, b.lines[b.next:]...), lines...)
+	}
+	if len(b.partial) > 0 {
+		lines = append(lines, string(b.partial))
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// Reset discards everything written so far.
+func (b *lineBuffer) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.next, b.full, b.partial = 0, false, b.partial[:0]
+}
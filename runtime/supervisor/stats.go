@@ -0,0 +1,94 @@
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The kernel reports CPU time in clock ticks. This is fixed at 100 per second on every platform
+// Linux supports and isn't exposed without cgo.
+const clockTicks = 100
+
+var (
+	NotRunning = errors.New("the process is not running")
+)
+
+// ProcessStats describes the resources used by the supervised process. The resource usage is read
+// from /proc so it is only available on Linux, elsewhere those fields are left at zero.
+type ProcessStats struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+	// The uptime and CPU time are in seconds.
+	Uptime  float64 `json:"uptime"`
+	CPUTime float64 `json:"cpu_time"`
+	// The resident set size in bytes.
+	RSS     int64 `json:"rss"`
+	FDs     int   `json:"fds"`
+	Threads int   `json:"threads"`
+	// The number of times the runtime has restarted the process, the supervisor leaves it at zero.
+	Restarts int `json:"restarts"`
+}
+
+// Stats reports the resources used by the process. It returns NotRunning if the process isn't
+// running.
+func (s *Supervisor) Stats() (*ProcessStats, error) {
+	s.stateMutex.Lock()
+	if s.state != RUNNING {
+		s.stateMutex.Unlock()
+		return nil, NotRunning
+	}
+	pid, started := s.command.Process.Pid, s.started
+	s.stateMutex.Unlock()
+
+	stats := &ProcessStats{
+		PID:     pid,
+		Started: started,
+		Uptime:  time.Since(started).Seconds(),
+	}
+	if err := readProc(pid, stats); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// readProc fills in the resource usage of the process from /proc/<pid>.
+func readProc(pid int, stats *ProcessStats) error {
+	bs, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return err
+	}
+	// The command name is in brackets and may contain spaces, the other fields follow it.
+	stat := string(bs)
+	end := strings.LastIndex(stat, ")")
+	if end == -1 {
+		return fmt.Errorf("failed to parse /proc/%d/stat", pid)
+	}
+	// The fields are numbered from 1 in proc(5) and the first two are the pid and command.
+	fields := strings.Fields(stat[end+1:])
+	field := func(n int) int64 {
+		if n-3 >= len(fields) {
+			return 0
+		}
+		value, _ := strconv.ParseInt(fields[n-3], 10, 64)
+		return value
+	}
+	stats.CPUTime = float64(field(14)+field(15)) / clockTicks
+	stats.Threads = int(field(20))
+	stats.RSS = field(24) * int64(os.Getpagesize())
+	dir, err := os.Open(fmt.Sprintf("/proc/%d/fd", pid))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	fds, err := dir.Readdirnames(-1)
+	if err != nil {
+		return err
+	}
+	stats.FDs = len(fds)
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/foldsh/fold/logging"
 )
@@ -28,11 +29,21 @@ type Supervisor struct {
 	Sout       io.Writer
 	Serr       io.Writer
 	Terminated chan error
+	// The signal sent by Stop, SIGTERM by default.
+	StopSignal os.Signal
+	// The number of lines of output included in a crash report, DefaultCrashLines by default.
+	CrashLines int
 
 	state      State
 	stateMutex *sync.Mutex
 	command    *exec.Cmd
-	logger     logging.Logger
+	started    time.Time
+	// Whether the process has been sent a signal, in which case being killed by it is expected.
+	signalled bool
+	// The most recent output of the process, for crash reports.
+	stdout *lineBuffer
+	stderr *lineBuffer
+	logger logging.Logger
 }
 
 func NewSupervisor(
@@ -48,6 +59,10 @@ func NewSupervisor(
 		Sout:       sout,
 		Serr:       serr,
 		Terminated: make(chan error, 1),
+		StopSignal: syscall.SIGTERM,
+		CrashLines: DefaultCrashLines,
+		stdout:     newLineBuffer(outputLines),
+		stderr:     newLineBuffer(outputLines),
 		logger:     logger,
 		state:      NOTSTARTED,
 		stateMutex: &sync.Mutex{},
@@ -73,6 +88,8 @@ var (
 type ProcessError struct {
 	Reason string
 	Inner  error
+	// Describes the crash when the process crashed.
+	Report *CrashReport
 }
 
 func (e ProcessError) Error() string {
@@ -87,8 +104,10 @@ func (s *Supervisor) Start(env map[string]string) error {
 	s.logger.Debugf("Starting the process")
 	command := exec.Command(s.Cmd, s.Args...)
 	s.command = command
-	command.Stdout = s.Sout
-	command.Stderr = s.Serr
+	s.stdout.Reset()
+	s.stderr.Reset()
+	command.Stdout = io.MultiWriter(s.stdout, s.Sout)
+	command.Stderr = io.MultiWriter(s.stderr, s.Serr)
 	command.Env = os.Environ()
 	for key, value := range env {
 		command.Env = append(
@@ -101,7 +120,9 @@ func (s *Supervisor) Start(env map[string]string) error {
 		s.setState(STARTFAILED)
 		return ProcessError{Reason: "process failed to start", Inner: err}
 	}
-	s.setState(RUNNING)
+	s.stateMutex.Lock()
+	s.state, s.started, s.signalled = RUNNING, time.Now(), false
+	s.stateMutex.Unlock()
 	go func() {
 		err := command.Wait()
 		if err == nil {
@@ -118,17 +139,32 @@ func (s *Supervisor) Start(env map[string]string) error {
 			return
 		}
 		// It's an exit error, so the process ran but stopped for some reason.
-		if exitErr.ExitCode() == -1 {
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
 			// Terminated by a signal, so this is expected.
 			s.logger.Debugf("The process was terminated by a signal")
+			s.stateMutex.Lock()
+			signalled := s.signalled
+			s.stateMutex.Unlock()
 			s.setState(COMPLETE)
-			s.Terminated <- TerminatedBySignal
+			if signalled || !crashSignals[status.Signal()] {
+				s.Terminated <- TerminatedBySignal
+				return
+			}
+			// We didn't send it and it's one a crash would be killed with, so the process is
+			// still treated as stopped but the error carries a report of the crash.
+			report := s.crashReport(exitErr.Pid(), exitErr.ExitCode(), status.Signal().String())
+			s.Terminated <- ProcessError{
+				Reason: "process was killed",
+				Inner:  TerminatedBySignal,
+				Report: report,
+			}
 			return
 		}
 		// The users program crashed, they have a bug.
 		s.logger.Debugf("The process ended unexpectedly %+v", err)
+		report := s.crashReport(exitErr.Pid(), exitErr.ExitCode(), "")
 		s.setState(CRASHED)
-		s.Terminated <- ProcessError{Reason: "process crashed", Inner: err}
+		s.Terminated <- ProcessError{Reason: "process crashed", Inner: err, Report: report}
 		return
 	}()
 	return nil
@@ -150,7 +186,7 @@ func (s *Supervisor) Stop() error {
 	if s.State() != RUNNING {
 		return nil
 	}
-	return s.Signal(syscall.SIGTERM)
+	return s.Signal(s.StopSignal)
 }
 
 func (s *Supervisor) Kill() error {
@@ -158,6 +194,7 @@ func (s *Supervisor) Kill() error {
 	if s.State() != RUNNING {
 		return nil
 	}
+	s.setSignalled()
 	return s.command.Process.Kill()
 }
 
@@ -173,5 +210,12 @@ func (s *Supervisor) Signal(sig os.Signal) error {
 	if s.State() != RUNNING {
 		return nil
 	}
+	s.setSignalled()
 	return s.command.Process.Signal(sig)
 }
+
+func (s *Supervisor) setSignalled() {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	s.signalled = true
+}
@@ -3,10 +3,13 @@ package supervisor_test
 import (
 	"bytes"
 	"errors"
+	"os"
+	"strings"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/foldsh/fold/internal/testutils"
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/runtime/supervisor"
 )
@@ -263,3 +266,88 @@ func makeProcess(
 	s := supervisor.NewSupervisor(logging.NewTestLogger(), cmd, args, sout, serr)
 	return s, sout, serr
 }
+
+func TestStats(t *testing.T) {
+	s, _, _ := makeProcess("sleep", []string{"999"})
+	if _, err := s.Stats(); !errors.Is(err, supervisor.NotRunning) {
+		t.Errorf("Expected NotRunning but found %+v", err)
+	}
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if stats.PID <= 0 {
+		t.Errorf("Expected a PID but found %d", stats.PID)
+	}
+	if _, err := os.Stat("/proc"); err == nil {
+		// The RSS may still be zero if the process hasn't finished starting.
+		if stats.Threads <= 0 || stats.FDs <= 0 {
+			t.Errorf("Expected the resource usage to be read from /proc but found %+v", stats)
+		}
+	}
+	s.Kill()
+	s.Wait()
+	if _, err := s.Stats(); !errors.Is(err, supervisor.NotRunning) {
+		t.Errorf("Expected NotRunning but found %+v", err)
+	}
+}
+
+func TestCrashReport(t *testing.T) {
+	s, _, serr := makeProcess("bash", []string{"./testdata/panic.sh"})
+	s.CrashLines = 3
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	var pe supervisor.ProcessError
+	if err := s.Wait(); !errors.As(err, &pe) || pe.Report == nil {
+		t.Fatalf("Expected a ProcessError with a crash report but found %v", err)
+	}
+	report := pe.Report
+	if report.ExitCode != 2 || report.Signal != "" || report.PID <= 0 {
+		t.Errorf("Expected the process to exit with code 2 but found %+v", report)
+	}
+	testutils.Diff(
+		t,
+		[]string{"goroutine 1 [running]:", "main.main()", "\t/app/main.go:12 +0x1d"},
+		report.Stderr,
+		"Expected the last lines of stderr",
+	)
+	testutils.Diff(t, []string{"starting"}, report.Stdout, "Expected the last lines of stdout")
+	testutils.Diff(
+		t,
+		"panic: runtime error: index out of range [3] with length 3\n\n"+
+			"goroutine 1 [running]:\nmain.main()\n\t/app/main.go:12 +0x1d",
+		report.Panic,
+		"Expected the panic to be found",
+	)
+	// The output is still passed on as it is.
+	if !strings.HasPrefix(serr.String(), "handling request\npanic:") {
+		t.Errorf("Expected stderr to be passed on but found %s", serr.String())
+	}
+}
+
+func TestKilledBySignalHasACrashReport(t *testing.T) {
+	// The process wasn't sent the signal by the supervisor, e.g. the kernel ran out of memory.
+	s, _, _ := makeProcess("bash", []string{"-c", "kill -KILL $$"})
+	if err := s.Start(nil); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	err := s.Wait()
+	var pe supervisor.ProcessError
+	if !errors.As(err, &pe) || pe.Report == nil {
+		t.Fatalf("Expected a ProcessError with a crash report but found %v", err)
+	}
+	if pe.Report.ExitCode != -1 || pe.Report.Signal != "killed" {
+		t.Errorf("Expected the process to be killed but found %+v", pe.Report)
+	}
+	// It is still treated as being stopped by a signal.
+	if !errors.Is(err, supervisor.TerminatedBySignal) {
+		t.Errorf("Expected TerminatedBySignal but found %v", err)
+	}
+	if s.State() != supervisor.COMPLETE {
+		t.Errorf("Expected COMPLETE but found %v", s.State())
+	}
+}
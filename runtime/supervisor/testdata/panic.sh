@@ -0,0 +1,8 @@
+echo "starting"
+echo "handling request" >&2
+echo "panic: runtime error: index out of range [3] with length 3" >&2
+echo "" >&2
+echo "goroutine 1 [running]:" >&2
+echo "main.main()" >&2
+echo "	/app/main.go:12 +0x1d" >&2
+exit 2
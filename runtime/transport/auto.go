@@ -0,0 +1,98 @@
+package transport
+
+import (
+	"context"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/manifest"
+)
+
+// How long the SDK has to answer the HTTP probe before it is assumed to serve gRPC.
+const probeTimeout = time.Second
+
+// ingress is implemented by both Ingress and HTTPIngress.
+type ingress interface {
+	Start(string) error
+	Stop() error
+	Initialize(context.Context, Handshake) (*Session, error)
+	GetManifest(context.Context) (*manifest.Manifest, error)
+	DoRequest(context.Context, *Request) (*Response, error)
+	Check(context.Context) error
+}
+
+// AutoIngress works out which protocol the SDK serves when it starts and then behaves like an
+// Ingress or an HTTPIngress. It sends an HTTP/1.1 request to the socket, which a gRPC server
+// can't answer, so an SDK which responds at all serves the HTTP ingress protocol.
+type AutoIngress struct {
+	logger  logging.Logger
+	ingress ingress
+}
+
+func NewAutoIngress(logger logging.Logger) *AutoIngress {
+	return &AutoIngress{logger: logger}
+}
+
+func (a *AutoIngress) Start(socketAddress string) error {
+	a.logger.Debugf("Waiting for the SDK to listen on %s", socketAddress)
+	if err := waitForProcess(a.logger, "unix", socketAddress, 0); err != nil {
+		return err
+	}
+	if servesHTTP(socketAddress) {
+		a.logger.Debugf("The SDK serves the HTTP ingress protocol")
+		a.ingress = NewHTTPIngress(a.logger)
+	} else {
+		a.logger.Debugf("The SDK serves the gRPC ingress protocol")
+		a.ingress = NewIngress(a.logger)
+	}
+	return a.ingress.Start(socketAddress)
+}
+
+func (a *AutoIngress) Stop() error {
+	if a.ingress == nil {
+		return nil
+	}
+	return a.ingress.Stop()
+}
+
+// Restart detects the protocol again, as the SDK may have changed along with the service.
+func (a *AutoIngress) Restart(socketAddress string) error {
+	if err := a.Stop(); err != nil {
+		return err
+	}
+	return a.Start(socketAddress)
+}
+
+// HTTP returns true if the SDK serves the HTTP ingress protocol. It must be called after Start.
+func (a *AutoIngress) HTTP() bool {
+	_, ok := a.ingress.(*HTTPIngress)
+	return ok
+}
+
+func (a *AutoIngress) Initialize(ctx context.Context, handshake Handshake) (*Session, error) {
+	return a.ingress.Initialize(ctx, handshake)
+}
+
+func (a *AutoIngress) GetManifest(ctx context.Context) (*manifest.Manifest, error) {
+	return a.ingress.GetManifest(ctx)
+}
+
+func (a *AutoIngress) DoRequest(ctx context.Context, in *Request) (*Response, error) {
+	return a.ingress.DoRequest(ctx, in)
+}
+
+func (a *AutoIngress) Check(ctx context.Context) error {
+	return a.ingress.Check(ctx)
+}
+
+func servesHTTP(socketAddress string) bool {
+	client := newHTTPClient("unix", socketAddress)
+	client.Timeout = probeTimeout
+	defer client.CloseIdleConnections()
+	res, err := client.Get("http://localhost" + HTTPHealthPath)
+	if err != nil {
+		return false
+	}
+	res.Body.Close()
+	return true
+}
@@ -10,6 +10,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/backoff"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
@@ -22,6 +23,7 @@ import (
 type Ingress struct {
 	conn   *grpc.ClientConn
 	client pb.FoldIngressClient
+	health healthpb.HealthClient
 	logger logging.Logger
 }
 
@@ -64,6 +66,7 @@ func (i *Ingress) Start(socketAddress string) error {
 	}
 	i.conn = conn
 	i.client = pb.NewFoldIngressClient(conn)
+	i.health = healthpb.NewHealthClient(conn)
 	i.logger.Debugf("Connected")
 	return nil
 }
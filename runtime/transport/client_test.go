@@ -2,15 +2,20 @@ package transport_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"testing"
 	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/foldsh/fold/internal/grpctest"
+	"github.com/foldsh/fold/internal/testutils"
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/runtime/transport"
+	"github.com/foldsh/fold/runtime/transport/pb"
 )
 
 func TestIngressCanDoRequestAndGetManifest(t *testing.T) {
@@ -38,6 +43,76 @@ func TestIngressCanDoRequestAndGetManifest(t *testing.T) {
 	}
 }
 
+func TestIngressInitialize(t *testing.T) {
+	cases := []struct {
+		name     string
+		res      *pb.InitializeRes
+		expected *transport.Session
+		err      error
+	}{
+		{
+			"Features supported by both sides are enabled",
+			&pb.InitializeRes{
+				SdkName:            "fold-go",
+				SdkVersion:         "v1.0.0",
+				MinProtocolVersion: 1,
+				MaxProtocolVersion: 2,
+				Features:           []string{transport.Events, "other"},
+			},
+			&transport.Session{
+				SDKName:    "fold-go",
+				SDKVersion: "v1.0.0",
+				Features:   []string{transport.Events},
+			},
+			nil,
+		},
+		{
+			"Incompatible SDKs are refused",
+			&pb.InitializeRes{
+				SdkName:            "fold-go",
+				SdkVersion:         "v2.0.0",
+				MinProtocolVersion: 2,
+				MaxProtocolVersion: 3,
+			},
+			nil,
+			transport.IncompatibleSDK{SDK: "fold-go", Version: "v2.0.0", Min: 2, Max: 3},
+		},
+		{
+			"SDKs without the handshake speak the first version",
+			nil,
+			&transport.Session{SDKName: "unknown", SDKVersion: "unknown"},
+			nil,
+		},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := fmt.Sprintf("/tmp/fold.client.test-initialize-%d.sock", i)
+			client, server, _ := makeIngress(t, addr, 0)
+			server.InitializeRes = tc.res
+			defer server.Stop()
+			if err := client.Start(addr); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			defer client.Stop()
+
+			session, err := client.Initialize(
+				context.Background(),
+				transport.Handshake{
+					ServiceName: "test",
+					Stage:       "LOCAL",
+					Features:    []string{transport.Streaming, transport.Events},
+				},
+			)
+			testutils.Diff(t, tc.err, err, "Initialize error did not match")
+			testutils.Diff(t, tc.expected, session, "Session did not match")
+			sent := server.LastInitialize
+			if sent.ServiceName != "test" || sent.Stage != "LOCAL" {
+				t.Errorf("Expected the service name and stage to be sent but found %v", sent)
+			}
+		})
+	}
+}
+
 func TestIngressStop(t *testing.T) {
 	addr := "/tmp/fold.client.test-stop.sock"
 	client, server, _ := makeIngress(t, addr, 0)
@@ -154,6 +229,52 @@ func TestIngressRestart(t *testing.T) {
 	}
 }
 
+func TestIngressCheck(t *testing.T) {
+	cases := []struct {
+		name   string
+		status healthpb.HealthCheckResponse_ServingStatus
+		legacy bool
+		err    error
+	}{
+		{"Serving", healthpb.HealthCheckResponse_SERVING, false, nil},
+		{
+			"Not serving",
+			healthpb.HealthCheckResponse_NOT_SERVING,
+			false,
+			transport.NotServing{Status: "NOT_SERVING"},
+		},
+		{
+			"SDKs without health checks",
+			healthpb.HealthCheckResponse_SERVING,
+			true,
+			transport.HealthCheckUnsupported,
+		},
+	}
+	for i, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			addr := fmt.Sprintf("/tmp/fold.client.test-check-%d.sock", i)
+			logger := logging.NewTestLogger()
+			client := transport.NewIngress(logger)
+			server := grpctest.NewServer(t, logger, addr)
+			if tc.legacy {
+				server.Health = nil
+			} else {
+				server.Health.SetServingStatus("", tc.status)
+			}
+			go server.Start()
+			defer server.Stop()
+			if err := client.Start(addr); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			defer client.Stop()
+
+			if err := client.Check(context.Background()); !errors.Is(err, tc.err) {
+				t.Errorf("Expected Check to return %v but found %v", tc.err, err)
+			}
+		})
+	}
+}
+
 func makeIngress(
 	t *testing.T,
 	addr string,
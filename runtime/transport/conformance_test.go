@@ -0,0 +1,269 @@
+package transport_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/foldsh/fold/internal/grpctest"
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/transport"
+	"github.com/foldsh/fold/runtime/transport/pb"
+)
+
+// These tests make sure that every transport behaves in the same way, whichever protocol the SDK
+// serves.
+
+type client interface {
+	Start(string) error
+	Stop() error
+	Initialize(context.Context, transport.Handshake) (*transport.Session, error)
+	GetManifest(context.Context) (*manifest.Manifest, error)
+	DoRequest(context.Context, *transport.Request) (*transport.Response, error)
+	Check(context.Context) error
+}
+
+var transports = []struct {
+	name   string
+	client func(logging.Logger) client
+	serve  func(*grpctest.Server)
+}{
+	{
+		"gRPC",
+		func(logger logging.Logger) client { return transport.NewIngress(logger) },
+		(*grpctest.Server).Start,
+	},
+	{
+		"HTTP",
+		func(logger logging.Logger) client { return transport.NewHTTPIngress(logger) },
+		(*grpctest.Server).StartHTTP,
+	},
+	{
+		"Detected gRPC",
+		func(logger logging.Logger) client { return transport.NewAutoIngress(logger) },
+		(*grpctest.Server).Start,
+	},
+	{
+		"Detected HTTP",
+		func(logger logging.Logger) client { return transport.NewAutoIngress(logger) },
+		(*grpctest.Server).StartHTTP,
+	},
+}
+
+// conformance runs the test against every transport with a connected client. The server can be
+// set up before it starts.
+func conformance(
+	t *testing.T,
+	setup func(*grpctest.Server),
+	test func(*testing.T, client, *grpctest.Server),
+) {
+	for _, tr := range transports {
+		tr := tr
+		t.Run(tr.name, func(t *testing.T) {
+			addr := socketPath(t)
+			logger := logging.NewTestLogger()
+			server := grpctest.NewServer(t, logger, addr)
+			if setup != nil {
+				setup(server)
+			}
+			go tr.serve(server)
+			defer server.Stop()
+			c := tr.client(logger)
+			if err := c.Start(addr); err != nil {
+				t.Fatalf("%+v", err)
+			}
+			defer c.Stop()
+			test(t, c, server)
+		})
+	}
+}
+
+func TestConformanceInitialize(t *testing.T) {
+	handshake := transport.Handshake{
+		ServiceName: "test",
+		Stage:       "LOCAL",
+		Features:    []string{transport.Streaming, transport.Events},
+	}
+	conformance(
+		t,
+		func(s *grpctest.Server) {
+			s.InitializeRes.SdkName = "fold-test"
+			s.InitializeRes.Features = []string{transport.Events, "other"}
+		},
+		func(t *testing.T, c client, s *grpctest.Server) {
+			session, err := c.Initialize(context.Background(), handshake)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			testutils.Diff(
+				t,
+				&transport.Session{
+					SDKName:    "fold-test",
+					SDKVersion: "v0.0.0",
+					Features:   []string{transport.Events},
+				},
+				session,
+				"Session did not match",
+			)
+			sent := s.LastInitialize
+			if sent.ServiceName != "test" || sent.Stage != "LOCAL" {
+				t.Errorf("Expected the service name and stage to be sent but found %v", sent)
+			}
+			if sent.ProtocolVersion != transport.ProtocolVersion {
+				t.Errorf("Expected the protocol version to be sent but found %v", sent)
+			}
+		},
+	)
+
+	conformance(
+		t,
+		func(s *grpctest.Server) {
+			s.InitializeRes = &pb.InitializeRes{
+				SdkName:            "fold-test",
+				SdkVersion:         "v2.0.0",
+				MinProtocolVersion: 2,
+				MaxProtocolVersion: 3,
+			}
+		},
+		func(t *testing.T, c client, s *grpctest.Server) {
+			_, err := c.Initialize(context.Background(), handshake)
+			expected := transport.IncompatibleSDK{
+				SDK:     "fold-test",
+				Version: "v2.0.0",
+				Min:     2,
+				Max:     3,
+			}
+			testutils.Diff(t, expected, err, "Initialize error did not match")
+		},
+	)
+}
+
+func TestConformanceGetManifest(t *testing.T) {
+	conformance(t, nil, func(t *testing.T, c client, s *grpctest.Server) {
+		m, err := c.GetManifest(context.Background())
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		testutils.DiffManifest(
+			t,
+			&manifest.Manifest{Version: &manifest.Version{Major: 1}},
+			m,
+		)
+		if s.ManifestCalls != 1 {
+			t.Errorf("Expected to record 1 manifest call but found %d", s.ManifestCalls)
+		}
+	})
+}
+
+func TestConformanceDoRequest(t *testing.T) {
+	conformance(t, nil, func(t *testing.T, c client, s *grpctest.Server) {
+		// The body isn't valid UTF-8 to make sure it is sent exactly as it is.
+		body := []byte{0xff, 0x00, 'f', 'o', 'l', 'd'}
+		res, err := c.DoRequest(context.Background(), &transport.Request{
+			HTTPMethod:  "PUT",
+			Path:        "/items/1",
+			RawQuery:    "draft=true",
+			Body:        body,
+			Headers:     map[string][]string{"X-Item": {"1", "2"}},
+			PathParams:  map[string]string{"id": "1"},
+			QueryParams: map[string][]string{"draft": {"true"}},
+			Route:       "/items/:id",
+			Claims:      map[string]interface{}{"sub": "user"},
+		})
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		testutils.Diff(t, 200, res.Status, "Status did not match")
+		testutils.Diff(t, body, res.Body, "Body did not match")
+
+		sent := s.LastRequest
+		testutils.Diff(t, manifest.FoldHTTPMethod_PUT, sent.HttpMethod, "Method did not match")
+		testutils.Diff(t, "/items/1", sent.Path, "Path did not match")
+		testutils.Diff(t, "draft=true", sent.RawQuery, "Query did not match")
+		testutils.Diff(t, []string{"1", "2"}, sent.Headers["X-Item"].Values, "Headers")
+		testutils.Diff(t, "1", sent.PathParams["id"], "Path params did not match")
+		testutils.Diff(t, "/items/:id", sent.Route, "Route did not match")
+		testutils.Diff(t, `{"sub":"user"}`, string(sent.Claims), "Claims did not match")
+	})
+}
+
+func TestConformanceCheck(t *testing.T) {
+	cases := []struct {
+		name   string
+		status healthpb.HealthCheckResponse_ServingStatus
+		legacy bool
+		err    error
+	}{
+		{"Serving", healthpb.HealthCheckResponse_SERVING, false, nil},
+		{
+			"Not serving",
+			healthpb.HealthCheckResponse_NOT_SERVING,
+			false,
+			transport.NotServing{Status: "NOT_SERVING"},
+		},
+		{
+			"SDKs without health checks",
+			healthpb.HealthCheckResponse_SERVING,
+			true,
+			transport.HealthCheckUnsupported,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			setup := func(s *grpctest.Server) {
+				if tc.legacy {
+					s.Health = nil
+				} else {
+					s.Health.SetServingStatus("", tc.status)
+				}
+			}
+			conformance(t, setup, func(t *testing.T, c client, s *grpctest.Server) {
+				if err := c.Check(context.Background()); !errors.Is(err, tc.err) {
+					t.Errorf("Expected Check to return %v but found %v", tc.err, err)
+				}
+			})
+		})
+	}
+}
+
+func TestConformanceStop(t *testing.T) {
+	conformance(t, nil, func(t *testing.T, c client, s *grpctest.Server) {
+		if err := c.Stop(); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if err := c.Stop(); err != nil {
+			t.Fatalf("Expected Stop to be idempotent but got %v", err)
+		}
+		req := &transport.Request{HTTPMethod: "GET", Body: []byte(`fold`)}
+		if _, err := c.DoRequest(context.Background(), req); err == nil {
+			t.Errorf("Expected an error after stopping the client but no error was found")
+		}
+	})
+}
+
+func TestAutoIngressDetectsTheProtocol(t *testing.T) {
+	for _, serveHTTP := range []bool{false, true} {
+		addr := socketPath(t)
+		logger := logging.NewTestLogger()
+		server := grpctest.NewServer(t, logger, addr)
+		if serveHTTP {
+			go server.StartHTTP()
+		} else {
+			go server.Start()
+		}
+		c := transport.NewAutoIngress(logger)
+		if err := c.Start(addr); err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if c.HTTP() != serveHTTP {
+			t.Errorf("Expected the HTTP protocol to be detected: %v", serveHTTP)
+		}
+		c.Stop()
+		server.Stop()
+	}
+}
@@ -0,0 +1,130 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/transport/pb"
+	"github.com/foldsh/fold/version"
+)
+
+// ProtocolVersion is the version of the FoldIngress protocol spoken by the runtime. It must be
+// increased whenever a change is made which existing SDKs can't handle.
+const ProtocolVersion uint32 = 1
+
+// Optional features of the protocol. They are only used when both the runtime and the SDK
+// support them.
+const (
+	Streaming = "streaming"
+	Events    = "events"
+)
+
+// Features lists the optional features supported by the runtime. Neither streaming nor events
+// are implemented yet.
+var Features = []string{}
+
+// Handshake is what the runtime tells the service when it connects.
+type Handshake struct {
+	ServiceName string
+	Stage       string
+	Features    []string
+}
+
+// Session describes the SDK on the other side of the connection and what was agreed with it.
+type Session struct {
+	SDKName    string
+	SDKVersion string
+	// The optional features supported by both sides.
+	Features []string
+}
+
+// Supports returns true if both the runtime and the SDK support the feature.
+func (s *Session) Supports(feature string) bool {
+	for _, f := range s.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// IncompatibleSDK is returned when the SDK does not support the runtime's protocol version.
+type IncompatibleSDK struct {
+	SDK     string
+	Version string
+	Min     uint32
+	Max     uint32
+}
+
+func (is IncompatibleSDK) Error() string {
+	return fmt.Sprintf(
+		"%s %s supports protocol versions %d to %d but the runtime speaks version %d, "+
+			"please upgrade whichever is older",
+		is.SDK,
+		is.Version,
+		is.Min,
+		is.Max,
+		ProtocolVersion,
+	)
+}
+
+// Initialize performs the handshake with the service. It must be called after Start and before
+// any other call.
+func (i *Ingress) Initialize(ctx context.Context, handshake Handshake) (*Session, error) {
+	res, err := i.client.Initialize(ctx, initializeReq(handshake))
+	if status.Code(err) == codes.Unimplemented {
+		// SDKs from before the handshake existed speak the first version of the protocol.
+		i.logger.Warnf("The SDK does not support the Initialize handshake, please upgrade it")
+		return &Session{SDKName: "unknown", SDKVersion: "unknown"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return negotiate(i.logger, handshake, res)
+}
+
+func initializeReq(handshake Handshake) *pb.InitializeReq {
+	return &pb.InitializeReq{
+		RuntimeVersion:  version.FoldVersion.String(),
+		ProtocolVersion: ProtocolVersion,
+		Stage:           handshake.Stage,
+		ServiceName:     handshake.ServiceName,
+		Features:        handshake.Features,
+	}
+}
+
+// negotiate agrees on a session from the SDK's response to the handshake, whichever transport it
+// was sent over.
+func negotiate(
+	logger logging.Logger,
+	handshake Handshake,
+	res *pb.InitializeRes,
+) (*Session, error) {
+	if ProtocolVersion < res.MinProtocolVersion || ProtocolVersion > res.MaxProtocolVersion {
+		return nil, IncompatibleSDK{
+			SDK:     res.SdkName,
+			Version: res.SdkVersion,
+			Min:     res.MinProtocolVersion,
+			Max:     res.MaxProtocolVersion,
+		}
+	}
+	session := &Session{SDKName: res.SdkName, SDKVersion: res.SdkVersion}
+	for _, feature := range res.Features {
+		for _, supported := range handshake.Features {
+			if feature == supported {
+				session.Features = append(session.Features, feature)
+			}
+		}
+	}
+	logger.Debugf(
+		"Initialized %s %s with features %v",
+		res.SdkName,
+		res.SdkVersion,
+		session.Features,
+	)
+	return session, nil
+}
@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// HealthCheckUnsupported is returned by Check when the SDK does not implement the gRPC health
+// protocol, as is the case for SDKs which predate it.
+var HealthCheckUnsupported = errors.New("the SDK does not implement health checks")
+
+// NotServing is returned by Check when the service reports that it can't handle requests.
+type NotServing struct {
+	Status string
+}
+
+func (ns NotServing) Error() string {
+	return fmt.Sprintf("the service reported its health as %s", ns.Status)
+}
+
+// Check asks the service whether it is healthy using the standard grpc.health.v1 protocol. It
+// returns nil if the service is serving.
+func (i *Ingress) Check(ctx context.Context) error {
+	res, err := i.health.Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) == codes.Unimplemented {
+		return HealthCheckUnsupported
+	}
+	if err != nil {
+		return err
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		return NotServing{Status: res.Status.String()}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/transport/pb"
+)
+
+// The endpoints of the HTTP ingress protocol. Each one mirrors a call of the FoldIngress service,
+// or of the gRPC health service, and takes and returns the same messages encoded as JSON.
+const (
+	HTTPInitializePath = "/fold/initialize"
+	HTTPManifestPath   = "/fold/manifest"
+	HTTPRequestPath    = "/fold/request"
+	HTTPHealthPath     = "/fold/health"
+)
+
+var ClientStopped = errors.New("the client has been stopped")
+
+// HTTPIngressError is returned when the SDK responds to a call with an error status.
+type HTTPIngressError struct {
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e HTTPIngressError) Error() string {
+	return fmt.Sprintf("the SDK responded to %s with status %d: %s", e.Path, e.Status, e.Body)
+}
+
+var (
+	marshaler   = &jsonpb.Marshaler{EmitDefaults: true}
+	unmarshaler = &jsonpb.Unmarshaler{AllowUnknownFields: true}
+)
+
+// HTTPIngress is the counterpart of Ingress for SDKs which serve the HTTP ingress protocol, for
+// languages without good gRPC support. Calls are made over HTTP/1.1 on the unix domain socket,
+// with the protobuf messages encoded as JSON. See docs/sdks/sdk.md for the details.
+type HTTPIngress struct {
+	logger logging.Logger
+
+	mu      sync.RWMutex
+	client  *http.Client
+	stopped bool
+}
+
+func NewHTTPIngress(logger logging.Logger) *HTTPIngress {
+	return &HTTPIngress{logger: logger}
+}
+
+// Start waits for the SDK to listen on the socket. Like Ingress, it waits for as long as it
+// takes.
+func (h *HTTPIngress) Start(socketAddress string) error {
+	h.mu.Lock()
+	h.client = newHTTPClient("unix", socketAddress)
+	h.stopped = false
+	h.mu.Unlock()
+	h.logger.Debugf("Waiting for the SDK to listen on %s", socketAddress)
+	return waitForProcess(h.logger, "unix", socketAddress, 0)
+}
+
+func (h *HTTPIngress) Stop() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.client != nil {
+		h.client.CloseIdleConnections()
+	}
+	h.stopped = true
+	return nil
+}
+
+func (h *HTTPIngress) Restart(socketAddress string) error {
+	if err := h.Stop(); err != nil {
+		return err
+	}
+	return h.Start(socketAddress)
+}
+
+// Initialize performs the handshake with the service. Unlike with gRPC, the handshake has always
+// been part of the protocol so every SDK must support it.
+func (h *HTTPIngress) Initialize(ctx context.Context, handshake Handshake) (*Session, error) {
+	res := &pb.InitializeRes{}
+	if err := h.call(ctx, "POST", HTTPInitializePath, initializeReq(handshake), res); err != nil {
+		return nil, err
+	}
+	return negotiate(h.logger, handshake, res)
+}
+
+// Retrieve the service manifest.
+func (h *HTTPIngress) GetManifest(ctx context.Context) (*manifest.Manifest, error) {
+	m := &manifest.Manifest{}
+	if err := h.call(ctx, "GET", HTTPManifestPath, nil, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Submit a request to the service for processing.
+func (h *HTTPIngress) DoRequest(ctx context.Context, in *Request) (*Response, error) {
+	encoded, err := in.ToProto()
+	if err != nil {
+		return nil, err
+	}
+	res := &manifest.FoldHTTPResponse{}
+	if err := h.call(ctx, "POST", HTTPRequestPath, encoded, res); err != nil {
+		return nil, err
+	}
+	return ResFromProto(res), nil
+}
+
+// Check asks the service whether it is healthy. SDKs which don't serve the health endpoint
+// don't support health checks.
+func (h *HTTPIngress) Check(ctx context.Context) error {
+	res := &healthpb.HealthCheckResponse{}
+	err := h.call(ctx, "GET", HTTPHealthPath, nil, res)
+	var httpErr HTTPIngressError
+	if errors.As(err, &httpErr) {
+		if httpErr.Status == http.StatusNotFound {
+			return HealthCheckUnsupported
+		}
+		return NotServing{Status: http.StatusText(httpErr.Status)}
+	}
+	if err != nil {
+		return err
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		return NotServing{Status: res.Status.String()}
+	}
+	return nil
+}
+
+// call sends the message to the endpoint and decodes the response into out. The message is
+// optional as some endpoints take no arguments.
+func (h *HTTPIngress) call(ctx context.Context, method, path string, in, out proto.Message) error {
+	h.mu.RLock()
+	client, stopped := h.client, h.stopped
+	h.mu.RUnlock()
+	if client == nil || stopped {
+		return ClientStopped
+	}
+	body := &bytes.Buffer{}
+	if in != nil {
+		if err := marshaler.Marshal(body, in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://localhost"+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg, _ := ioutil.ReadAll(res.Body)
+		return HTTPIngressError{Path: path, Status: res.StatusCode, Body: string(msg)}
+	}
+	if err := unmarshaler.Unmarshal(res.Body, out); err != nil {
+		return fmt.Errorf("failed to decode the response to %s: %w", path, err)
+	}
+	return nil
+}
@@ -19,6 +19,10 @@ const _ = grpc.SupportPackageIsVersion7
 //
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type FoldIngressClient interface {
+	// Called by the runtime as soon as it connects, before any other call. The
+	// runtime and the SDK use it to agree on a protocol version and on which
+	// optional features to use.
+	Initialize(ctx context.Context, in *InitializeReq, opts ...grpc.CallOption) (*InitializeRes, error)
 	// Retrieve the manifest from the service.
 	GetManifest(ctx context.Context, in *ManifestReq, opts ...grpc.CallOption) (*manifest.Manifest, error)
 	// Ask the service to process an HTTP request.
@@ -33,6 +37,15 @@ func NewFoldIngressClient(cc grpc.ClientConnInterface) FoldIngressClient {
 	return &foldIngressClient{cc}
 }
 
+func (c *foldIngressClient) Initialize(ctx context.Context, in *InitializeReq, opts ...grpc.CallOption) (*InitializeRes, error) {
+	out := new(InitializeRes)
+	err := c.cc.Invoke(ctx, "/ingress.FoldIngress/Initialize", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *foldIngressClient) GetManifest(ctx context.Context, in *ManifestReq, opts ...grpc.CallOption) (*manifest.Manifest, error) {
 	out := new(manifest.Manifest)
 	err := c.cc.Invoke(ctx, "/ingress.FoldIngress/GetManifest", in, out, opts...)
@@ -55,6 +68,10 @@ func (c *foldIngressClient) DoRequest(ctx context.Context, in *manifest.FoldHTTP
 // All implementations must embed UnimplementedFoldIngressServer
 // for forward compatibility
 type FoldIngressServer interface {
+	// Called by the runtime as soon as it connects, before any other call. The
+	// runtime and the SDK use it to agree on a protocol version and on which
+	// optional features to use.
+	Initialize(context.Context, *InitializeReq) (*InitializeRes, error)
 	// Retrieve the manifest from the service.
 	GetManifest(context.Context, *ManifestReq) (*manifest.Manifest, error)
 	// Ask the service to process an HTTP request.
@@ -66,6 +83,9 @@ type FoldIngressServer interface {
 type UnimplementedFoldIngressServer struct {
 }
 
+func (UnimplementedFoldIngressServer) Initialize(context.Context, *InitializeReq) (*InitializeRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Initialize not implemented")
+}
 func (UnimplementedFoldIngressServer) GetManifest(context.Context, *ManifestReq) (*manifest.Manifest, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetManifest not implemented")
 }
@@ -85,6 +105,24 @@ func RegisterFoldIngressServer(s grpc.ServiceRegistrar, srv FoldIngressServer) {
 	s.RegisterService(&FoldIngress_ServiceDesc, srv)
 }
 
+func _FoldIngress_Initialize_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InitializeReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FoldIngressServer).Initialize(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ingress.FoldIngress/Initialize",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FoldIngressServer).Initialize(ctx, req.(*InitializeReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _FoldIngress_GetManifest_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ManifestReq)
 	if err := dec(in); err != nil {
@@ -128,6 +166,10 @@ var FoldIngress_ServiceDesc = grpc.ServiceDesc{
 	ServiceName: "ingress.FoldIngress",
 	HandlerType: (*FoldIngressServer)(nil),
 	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Initialize",
+			Handler:    _FoldIngress_Initialize_Handler,
+		},
 		{
 			MethodName: "GetManifest",
 			Handler:    _FoldIngress_GetManifest_Handler,
@@ -0,0 +1,302 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/manifest"
+)
+
+// ProxySDKName identifies the adapter in place of an SDK, e.g. in the logs.
+const ProxySDKName = "http-adapter"
+
+// ClaimsHeader carries the verified claims to services behind a Proxy, as JSON. It is removed
+// from every incoming request so that clients can't forge it.
+const ClaimsHeader = "X-Fold-Claims"
+
+var ProcessNotListening = errors.New("timed out waiting for the process to listen")
+
+// These headers only apply to a single connection so they aren't forwarded, see RFC 7230.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// The methods the catch-all manifest routes.
+var catchAllMethods = []manifest.FoldHTTPMethod{
+	manifest.FoldHTTPMethod_GET,
+	manifest.FoldHTTPMethod_HEAD,
+	manifest.FoldHTTPMethod_POST,
+	manifest.FoldHTTPMethod_PUT,
+	manifest.FoldHTTPMethod_PATCH,
+	manifest.FoldHTTPMethod_DELETE,
+}
+
+type ProxyOption func(*Proxy)
+
+// ProxyPort makes the proxy connect to the process on a port on localhost rather than on the
+// unix domain socket.
+func ProxyPort(port int) ProxyOption {
+	return func(p *Proxy) {
+		p.port = port
+	}
+}
+
+// ProxyManifest loads the manifest from a JSON file rather than routing every request to the
+// process.
+func ProxyManifest(path string) ProxyOption {
+	return func(p *Proxy) {
+		p.manifestPath = path
+	}
+}
+
+// ProxyHealthPath sets the path which is requested to check the health of the process. A check
+// fails unless it responds with a 2xx status. Without it, a check only ensures the process is
+// accepting connections.
+func ProxyHealthPath(path string) ProxyOption {
+	return func(p *Proxy) {
+		p.healthPath = path
+	}
+}
+
+// ProxyStartTimeout sets how long Start waits for the process to listen, 30 seconds by default.
+func ProxyStartTimeout(timeout time.Duration) ProxyOption {
+	return func(p *Proxy) {
+		p.startTimeout = timeout
+	}
+}
+
+// Proxy puts the runtime in front of a plain HTTP server which doesn't use an SDK. Requests are
+// forwarded to it as they are, over the unix domain socket or a port on localhost, and the
+// manifest is read from a file rather than asked for.
+type Proxy struct {
+	logger       logging.Logger
+	port         int
+	manifestPath string
+	healthPath   string
+	startTimeout time.Duration
+
+	network string
+	address string
+	client  *http.Client
+}
+
+func NewProxy(logger logging.Logger, options ...ProxyOption) *Proxy {
+	p := &Proxy{logger: logger, startTimeout: 30 * time.Second}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// Start waits for the process to listen, as there is no way of knowing when a plain HTTP server
+// is ready other than trying to connect to it.
+func (p *Proxy) Start(socketAddress string) error {
+	p.network, p.address = "unix", socketAddress
+	if p.port != 0 {
+		p.network, p.address = "tcp", fmt.Sprintf("127.0.0.1:%d", p.port)
+	}
+	p.client = newHTTPClient(p.network, p.address)
+	p.logger.Debugf("Waiting for the process to listen on %s", p.address)
+	return waitForProcess(p.logger, p.network, p.address, p.startTimeout)
+}
+
+// newHTTPClient returns a client which sends every request to the process, whatever the host in
+// the URL.
+func newHTTPClient(network, address string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			},
+			MaxIdleConnsPerHost: 100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		// Redirects are for the client to follow, not the runtime.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// waitForProcess returns once the process accepts connections on the address. It waits forever
+// if the timeout is zero.
+func waitForProcess(
+	logger logging.Logger,
+	network, address string,
+	timeout time.Duration,
+) error {
+	deadline := time.Now().Add(timeout)
+	wait := 500 * time.Microsecond
+	for {
+		conn, err := net.DialTimeout(network, address, time.Second)
+		if err == nil {
+			conn.Close()
+			logger.Debugf("Connected")
+			return nil
+		}
+		if timeout != 0 && time.Now().After(deadline) {
+			return fmt.Errorf("%w on %s: %v", ProcessNotListening, address, err)
+		}
+		time.Sleep(wait)
+		if wait < 100*time.Millisecond {
+			wait *= 2
+		}
+	}
+}
+
+func (p *Proxy) Stop() error {
+	if p.client != nil {
+		p.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (p *Proxy) Restart(socketAddress string) error {
+	if err := p.Stop(); err != nil {
+		return err
+	}
+	return p.Start(socketAddress)
+}
+
+// Initialize has nothing to agree with a plain HTTP server, so none of the optional features
+// are used.
+func (p *Proxy) Initialize(ctx context.Context, handshake Handshake) (*Session, error) {
+	return &Session{SDKName: ProxySDKName, Features: []string{}}, nil
+}
+
+// GetManifest reads the manifest from the file, or routes every request to the process if there
+// isn't one. The file is read every time so that changes to it are picked up by hot reloading.
+func (p *Proxy) GetManifest(ctx context.Context) (*manifest.Manifest, error) {
+	if p.manifestPath == "" {
+		m := &manifest.Manifest{}
+		for _, method := range catchAllMethods {
+			m.Routes = append(m.Routes, &manifest.Route{HttpMethod: method, Route: "/*path"})
+		}
+		return m, nil
+	}
+	file, err := os.Open(p.manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the manifest: %w", err)
+	}
+	defer file.Close()
+	m := &manifest.Manifest{}
+	if err := manifest.ReadJSON(file, m); err != nil {
+		return nil, fmt.Errorf("%w from %s", err, p.manifestPath)
+	}
+	return m, nil
+}
+
+// DoRequest forwards the request to the process.
+func (p *Proxy) DoRequest(ctx context.Context, in *Request) (*Response, error) {
+	u := &url.URL{Scheme: "http", Host: "localhost", Path: in.Path, RawQuery: in.RawQuery}
+	// The path is decoded, so the path as it was sent is used when there is one in order to
+	// keep e.g. an escaped slash.
+	if uri, err := url.ParseRequestURI(in.RequestURI); err == nil && uri.Path == in.Path {
+		u.RawPath = uri.RawPath
+	}
+	req, err := http.NewRequestWithContext(ctx, in.HTTPMethod, u.String(), bytes.NewReader(in.Body))
+	if err != nil {
+		return nil, err
+	}
+	// The headers are added one by one so that their names are canonicalised, otherwise a client
+	// could get past the removal of the claims header below by sending it in lower case.
+	for key, values := range in.Headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	removeHopByHopHeaders(req.Header)
+	// Responses are compressed by the runtime, so the process is left to negotiate it with the
+	// transport which decompresses the response again.
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del(ClaimsHeader)
+	if in.Claims != nil {
+		claims, err := json.Marshal(in.Claims)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(ClaimsHeader, string(claims))
+	}
+	req.Host = in.Host
+	if in.Host != "" {
+		req.Header.Set("X-Forwarded-Host", in.Host)
+	}
+	if host, _, err := net.SplitHostPort(in.RemoteAddr); err == nil {
+		if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+			host = fmt.Sprintf("%s, %s", prior, host)
+		}
+		req.Header.Set("X-Forwarded-For", host)
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	headers := res.Header.Clone()
+	removeHopByHopHeaders(headers)
+	// The runtime may compress the body, so the length is left for it to set.
+	headers.Del("Content-Length")
+	return &Response{Status: res.StatusCode, Body: body, Headers: headers}, nil
+}
+
+// Check requests the health path, or makes sure the process is still accepting connections if
+// there isn't one.
+func (p *Proxy) Check(ctx context.Context) error {
+	if p.healthPath == "" {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, p.network, p.address)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+	url := fmt.Sprintf("http://localhost%s", p.healthPath)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return NotServing{Status: res.Status}
+	}
+	return nil
+}
+
+func removeHopByHopHeaders(headers http.Header) {
+	for _, values := range headers.Values("Connection") {
+		for _, name := range strings.Split(values, ",") {
+			headers.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		headers.Del(name)
+	}
+}
@@ -0,0 +1,220 @@
+package transport_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/manifest"
+	"github.com/foldsh/fold/runtime/transport"
+)
+
+func TestProxyDoRequest(t *testing.T) {
+	var received *http.Request
+	var body []byte
+	addr := serveHTTP(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+		body, _ = ioutil.ReadAll(r.Body)
+		w.Header().Set("Connection", "close")
+		w.Header().Set("X-Item", "1")
+		w.WriteHeader(201)
+		w.Write([]byte("created"))
+	}))
+	proxy := transport.NewProxy(logging.NewTestLogger())
+	if err := proxy.Start(addr); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer proxy.Stop()
+
+	res, err := proxy.DoRequest(context.Background(), &transport.Request{
+		HTTPMethod: "POST",
+		Path:       "/items",
+		RawQuery:   "draft=true",
+		Host:       "api.fold.sh",
+		RemoteAddr: "10.0.0.1:1234",
+		Body:       []byte(`{"name":"fold"}`),
+		Headers: map[string][]string{
+			"Content-Type":  {"application/json"},
+			"Connection":    {"keep-alive"},
+			"x-fold-claims": {`{"sub":"forged"}`},
+		},
+		Claims: map[string]interface{}{"sub": "user"},
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	testutils.Diff(t, 201, res.Status, "Status did not match")
+	testutils.Diff(t, "created", string(res.Body), "Body did not match")
+	testutils.Diff(t, []string{"1"}, res.Headers["X-Item"], "Headers did not match")
+	if _, ok := res.Headers["Connection"]; ok {
+		t.Errorf("Expected hop by hop headers to be removed from the response")
+	}
+
+	testutils.Diff(t, "/items?draft=true", received.URL.RequestURI(), "URL did not match")
+	testutils.Diff(t, `{"name":"fold"}`, string(body), "Request body did not match")
+	testutils.Diff(t, "api.fold.sh", received.Host, "Host did not match")
+	testutils.Diff(t, "10.0.0.1", received.Header.Get("X-Forwarded-For"), "Forwarded for")
+	testutils.Diff(
+		t,
+		`{"sub":"user"}`,
+		received.Header.Get(transport.ClaimsHeader),
+		"Claims did not match",
+	)
+
+	// Clients can't forge the claims for routes without authentication either.
+	_, err = proxy.DoRequest(context.Background(), &transport.Request{
+		HTTPMethod: "GET",
+		Path:       "/items",
+		Headers:    map[string][]string{"x-fold-claims": {`{"sub":"forged"}`}},
+	})
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if claims := received.Header.Values(transport.ClaimsHeader); len(claims) > 0 {
+		t.Errorf("Expected the forged claims to be removed but found %v", claims)
+	}
+}
+
+func TestProxyEscapedPaths(t *testing.T) {
+	var received *http.Request
+	addr := serveHTTP(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}))
+	proxy := transport.NewProxy(logging.NewTestLogger())
+	if err := proxy.Start(addr); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer proxy.Stop()
+
+	cases := []struct {
+		name       string
+		path       string
+		requestURI string
+		expected   string
+	}{
+		{"Question marks stay in the path", "/a?b", "/a%3Fb", "/a%3Fb"},
+		{"Percent signs stay in the path", "/100%", "/100%25", "/100%25"},
+		{"Escaped slashes are kept", "/a/b", "/a%2Fb", "/a%2Fb"},
+		{"Paths are escaped without a request URI", "/a?b", "", "/a%3Fb"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := proxy.DoRequest(context.Background(), &transport.Request{
+				HTTPMethod: "GET",
+				Path:       tc.path,
+				RequestURI: tc.requestURI,
+			})
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			testutils.Diff(t, tc.path, received.URL.Path, "Path did not match")
+			testutils.Diff(t, tc.expected, received.URL.EscapedPath(), "Escaped path did not match")
+			testutils.Diff(t, "", received.URL.RawQuery, "Query did not match")
+		})
+	}
+}
+
+func TestProxyManifest(t *testing.T) {
+	proxy := transport.NewProxy(logging.NewTestLogger())
+	m, err := proxy.GetManifest(context.Background())
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := manifest.Validate(m); err != nil {
+		t.Errorf("Expected the catch-all manifest to be valid but got %v", err)
+	}
+	if m.Routes[0].Route != "/*path" {
+		t.Errorf("Expected a catch-all route but found %s", m.Routes[0].Route)
+	}
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	ioutil.WriteFile(path, []byte(`{"routes": [{"httpMethod": "GET", "route": "/items"}]}`), 0644)
+	proxy = transport.NewProxy(logging.NewTestLogger(), transport.ProxyManifest(path))
+	m, err = proxy.GetManifest(context.Background())
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	testutils.DiffManifest(t, &manifest.Manifest{
+		Routes: []*manifest.Route{{HttpMethod: manifest.FoldHTTPMethod_GET, Route: "/items"}},
+	}, m)
+
+	ioutil.WriteFile(path, []byte(`{"routes": "oops"}`), 0644)
+	if _, err := proxy.GetManifest(context.Background()); !errors.Is(err, manifest.FailedToReadJSON) {
+		t.Errorf("Expected an invalid manifest to be rejected but got %v", err)
+	}
+}
+
+func TestProxyCheck(t *testing.T) {
+	healthy := true
+	addr := serveHTTP(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" || !healthy {
+			w.WriteHeader(503)
+		}
+	}))
+	proxy := transport.NewProxy(logging.NewTestLogger(), transport.ProxyHealthPath("/health"))
+	if err := proxy.Start(addr); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := proxy.Check(context.Background()); err != nil {
+		t.Errorf("Expected the check to pass but got %v", err)
+	}
+	healthy = false
+	var notServing transport.NotServing
+	if err := proxy.Check(context.Background()); !errors.As(err, &notServing) {
+		t.Errorf("Expected the check to fail but got %v", err)
+	}
+
+	// Without a health path the check only connects to the process.
+	proxy = transport.NewProxy(logging.NewTestLogger())
+	if err := proxy.Start(addr); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := proxy.Check(context.Background()); err != nil {
+		t.Errorf("Expected the check to pass but got %v", err)
+	}
+}
+
+func TestProxyStartTimeout(t *testing.T) {
+	proxy := transport.NewProxy(
+		logging.NewTestLogger(),
+		transport.ProxyStartTimeout(10*time.Millisecond),
+	)
+	err := proxy.Start(filepath.Join(t.TempDir(), "missing.sock"))
+	if !errors.Is(err, transport.ProcessNotListening) {
+		t.Errorf("Expected the start to time out but got %v", err)
+	}
+}
+
+// serveHTTP serves the handler on a unix domain socket, as a process behind the proxy would.
+func serveHTTP(t *testing.T, handler http.Handler) string {
+	addr := socketPath(t)
+	listener, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	server := &http.Server{Handler: handler}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+	return addr
+}
+
+// socketPath returns a unique path for a unix domain socket. It isn't inside t.TempDir() as the
+// path would be too long for a socket.
+func socketPath(t *testing.T) string {
+	f, err := ioutil.TempFile("", "fold.*.sock")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
@@ -2,6 +2,7 @@ package transport
 
 import (
 	"bytes"
+	"encoding/json"
 	"net/http"
 
 	"github.com/foldsh/fold/manifest"
@@ -27,6 +28,8 @@ type Request struct {
 	PathParams    map[string]string
 	QueryParams   map[string][]string
 	Route         string
+	// The verified claims from the bearer token, if the route requires authentication.
+	Claims map[string]interface{}
 }
 
 func ReqFromHTTP(req *http.Request, route string, pathParams map[string]string) *Request {
@@ -58,6 +61,12 @@ func (req *Request) ToProto() (*manifest.FoldHTTPRequest, error) {
 	if err != nil {
 		return nil, err
 	}
+	var claims []byte
+	if req.Claims != nil {
+		if claims, err = json.Marshal(req.Claims); err != nil {
+			return nil, err
+		}
+	}
 	return &manifest.FoldHTTPRequest{
 		HttpMethod: httpMethod,
 		Path:       req.Path,
@@ -77,6 +86,7 @@ func (req *Request) ToProto() (*manifest.FoldHTTPRequest, error) {
 		PathParams:    req.PathParams,
 		QueryParams:   encodeMapRepeatedString(req.QueryParams),
 		Route:         req.Route,
+		Claims:        claims,
 	}, nil
 }
 
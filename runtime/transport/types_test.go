@@ -28,6 +28,7 @@ func TestRequestToProto(t *testing.T) {
 		PathParams:    map[string]string{"baz": "bar"},
 		QueryParams:   map[string][]string{"foo": []string{"bar"}},
 		Route:         "/foo/:baz",
+		Claims:        map[string]interface{}{"sub": "user"},
 	}
 	expectation := &manifest.FoldHTTPRequest{
 		HttpMethod: manifest.FoldHTTPMethod_GET,
@@ -51,7 +52,8 @@ func TestRequestToProto(t *testing.T) {
 		QueryParams: map[string]*manifest.StringArray{
 			"foo": &manifest.StringArray{Values: []string{"bar"}},
 		},
-		Route: "/foo/:baz",
+		Route:  "/foo/:baz",
+		Claims: []byte(`{"sub":"user"}`),
 	}
 	result, err := req.ToProto()
 	if err != nil {
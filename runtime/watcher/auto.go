@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+)
+
+// How long to wait for a file system event after polling has found a change.
+const eventGrace = 200 * time.Millisecond
+
+// autoWatcher uses file system events while polling in the background. If polling finds a change
+// which didn't produce any events then events aren't being delivered, so it stops using them and
+// relies on polling from then on.
+type autoWatcher struct {
+	logger   logging.Logger
+	onChange func()
+	notify   *NotifyWatcher
+	poll     *PollingWatcher
+
+	mutex     sync.Mutex
+	lastEvent time.Time
+	polling   bool
+}
+
+func newAutoWatcher(
+	logger logging.Logger,
+	f *filter,
+	onChange func(),
+	s *settings,
+) (*autoWatcher, error) {
+	a := &autoWatcher{logger: logger, onChange: onChange}
+	notify, err := newNotifyWatcher(logger, f, a.onEvent)
+	if err != nil {
+		return nil, err
+	}
+	a.notify = notify
+	a.poll = newPoller(logger, f, a.onPoll, s)
+	return a, nil
+}
+
+func (a *autoWatcher) Watch() error {
+	if err := a.notify.Watch(); err != nil {
+		return err
+	}
+	return a.poll.Watch()
+}
+
+func (a *autoWatcher) Close() {
+	a.poll.Close()
+	a.mutex.Lock()
+	polling := a.polling
+	a.mutex.Unlock()
+	if !polling {
+		a.notify.Close()
+	}
+}
+
+func (a *autoWatcher) onEvent() {
+	a.mutex.Lock()
+	a.lastEvent = time.Now()
+	polling := a.polling
+	a.mutex.Unlock()
+	if !polling {
+		a.onChange()
+	}
+}
+
+func (a *autoWatcher) onPoll(since time.Time) {
+	a.mutex.Lock()
+	polling := a.polling
+	a.mutex.Unlock()
+	if polling {
+		a.onChange()
+		return
+	}
+	// Events normally arrive well before the next scan but we give them a moment in case the scan
+	// ran at the same time as the change.
+	time.Sleep(eventGrace)
+	a.mutex.Lock()
+	missed := a.lastEvent.Before(since)
+	if missed {
+		a.polling = true
+	}
+	a.mutex.Unlock()
+	if missed {
+		a.logger.Infof("No file system events were received for a change, switching to polling")
+		a.notify.Close()
+		a.onChange()
+	}
+}
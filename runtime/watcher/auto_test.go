@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+)
+
+func TestAutoWatcherFallsBackToPolling(t *testing.T) {
+	calls := 0
+	s := &settings{interval: time.Hour, extensions: map[string]struct{}{}}
+	f := newFilter(logging.NewTestLogger(), []string{t.TempDir()}, s)
+	a, err := newAutoWatcher(logging.NewTestLogger(), f, func() { calls++ }, s)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := a.Watch(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer a.Close()
+
+	// An event arrived for the change, so the poller's change should be ignored.
+	since := time.Now()
+	a.onEvent()
+	a.onPoll(since)
+	if calls != 1 || a.polling {
+		t.Errorf("Expected 1 call from the event without polling, found %d %v", calls, a.polling)
+	}
+
+	// Polling found a change but no event arrived, so events must not be working.
+	a.onPoll(time.Now())
+	if calls != 2 || !a.polling {
+		t.Errorf("Expected 2 calls and to be polling, found %d %v", calls, a.polling)
+	}
+
+	// From now on events are ignored and polling is used instead.
+	a.onEvent()
+	a.onPoll(time.Now())
+	if calls != 3 {
+		t.Errorf("Expected 3 calls, found %d", calls)
+	}
+}
@@ -0,0 +1,60 @@
+package watcher
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/foldsh/fold/logging"
+)
+
+// The amount of the build output kept for the error when the build fails.
+const maxBuildOutput = 4096
+
+// BuildFailed is returned when the build command exits unsuccessfully. It includes the end of the
+// command's output, which is usually where the compiler reports the problem.
+type BuildFailed struct {
+	Cmd    string
+	Output string
+	Err    error
+}
+
+func (bf BuildFailed) Error() string {
+	return fmt.Sprintf("build command '%s' failed: %v\n%s", bf.Cmd, bf.Err, bf.Output)
+}
+
+func (bf BuildFailed) Unwrap() error {
+	return bf.Err
+}
+
+// Builder runs a shell command, such as `go build -o ./bin/service .`, before the process is
+// restarted so that compiled services pick up the changes.
+type Builder struct {
+	logger logging.Logger
+	cmd    string
+	out    io.Writer
+}
+
+func NewBuilder(logger logging.Logger, cmd string) *Builder {
+	return &Builder{logger: logger, cmd: cmd, out: os.Stdout}
+}
+
+// Build runs the build command and waits for it to finish. Its output is written to stdout as it
+// runs.
+func (b *Builder) Build() error {
+	b.logger.Infof("Running build command: %s", b.cmd)
+	var output bytes.Buffer
+	cmd := exec.Command("sh", "-c", b.cmd)
+	cmd.Stdout = io.MultiWriter(b.out, &output)
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Run(); err != nil {
+		out := output.Bytes()
+		if len(out) > maxBuildOutput {
+			out = out[len(out)-maxBuildOutput:]
+		}
+		return BuildFailed{Cmd: b.cmd, Output: string(out), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package watcher_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/watcher"
+)
+
+func TestBuild(t *testing.T) {
+	logger := logging.NewTestLogger()
+	if err := watcher.NewBuilder(logger, "true").Build(); err != nil {
+		t.Errorf("Expected the build to succeed but got %v", err)
+	}
+
+	err := watcher.NewBuilder(logger, "echo 'syntax error' && exit 2").Build()
+	var failed watcher.BuildFailed
+	if !errors.As(err, &failed) {
+		t.Fatalf("Expected a BuildFailed error but got %v", err)
+	}
+	if !strings.Contains(failed.Output, "syntax error") {
+		t.Errorf("Expected the error to include the build output but found %q", failed.Output)
+	}
+}
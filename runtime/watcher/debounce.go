@@ -1,11 +1,17 @@
 package watcher
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
+// Debouncer collapses a burst of changes into a single call. It is safe to use from several
+// goroutines so it can be shared by every kind of watcher.
 type Debouncer struct {
 	rate       time.Duration
 	onChangeFn func()
 
+	mutex      sync.Mutex
 	callQueued bool
 }
 
@@ -14,6 +20,8 @@ func NewDebouncer(rate time.Duration, onChange func()) *Debouncer {
 }
 
 func (d *Debouncer) OnChange() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
 	if d.callQueued {
 		return
 	}
@@ -22,7 +30,9 @@ func (d *Debouncer) OnChange() {
 		select {
 		case <-time.After(d.rate):
 			d.onChangeFn()
+			d.mutex.Lock()
 			d.callQueued = false
+			d.mutex.Unlock()
 		}
 	}()
 }
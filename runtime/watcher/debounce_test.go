@@ -23,7 +23,7 @@ func TestDebouncer(t *testing.T) {
 	logger := logging.NewTestLogger()
 	counter := &counter{}
 	debouncer := watcher.NewDebouncer(100*time.Millisecond, counter.increment)
-	watcher, err := watcher.NewWatcher(logger, testDir, debouncer.OnChange)
+	watcher, err := watcher.NewWatcher(logger, []string{testDir}, debouncer.OnChange)
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
@@ -40,8 +40,8 @@ func TestDebouncer(t *testing.T) {
 	// counter.increment actually called once, even though we know a load of
 	// events have been detected.
 	expectedNMutations := 1
-	if counter.count != expectedNMutations {
-		t.Errorf("Expected %d mutations but found %d", expectedNMutations, counter.count)
+	if counter.value() != expectedNMutations {
+		t.Errorf("Expected %d mutations but found %d", expectedNMutations, counter.value())
 	}
 	watcher.Close()
 }
@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultIgnore lists the paths which are never worth reloading for: version control, installed
+// dependencies and the files editors create while a file is being edited.
+var DefaultIgnore = []string{
+	".git/",
+	".hg/",
+	".svn/",
+	"node_modules/",
+	"*.swp",
+	"*.swo",
+	"*.swx",
+	"*~",
+	".#*",
+	"#*#",
+	"4913",
+	".DS_Store",
+}
+
+// Matcher decides which paths to ignore using patterns with the same syntax as a .gitignore file.
+// A pattern without a slash matches a file or directory at any depth, otherwise it is matched
+// against the whole path relative to the watched directory. A trailing slash only matches
+// directories, ** matches any number of directories and a leading ! includes a path which an
+// earlier pattern ignored.
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+func NewMatcher(patterns ...string) *Matcher {
+	m := &Matcher{}
+	m.Add(patterns...)
+	return m
+}
+
+// Add appends the patterns to the matcher. Blank patterns and comments are skipped.
+func (m *Matcher) Add(patterns ...string) {
+	for _, p := range patterns {
+		if parsed, ok := parsePattern(p); ok {
+			m.patterns = append(m.patterns, parsed)
+		}
+	}
+}
+
+// AddFile adds the patterns from a file such as .gitignore. It is not an error for the file not to
+// exist.
+func (m *Matcher) AddFile(file string) error {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m.Add(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+// Match returns true if the path, which is relative to the watched directory, should be ignored.
+// Everything underneath an ignored directory is ignored too.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+	for i := 1; i < len(segments); i++ {
+		if m.match(segments[:i], true) {
+			return true
+		}
+	}
+	return m.match(segments, isDir)
+}
+
+func (m *Matcher) match(segments []string, isDir bool) bool {
+	// Later patterns take precedence over earlier ones, as they do in a .gitignore.
+	ignored := false
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.matches(segments) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func parsePattern(p string) (pattern, bool) {
+	p = strings.TrimSpace(p)
+	if p == "" || strings.HasPrefix(p, "#") {
+		return pattern{}, false
+	}
+	var parsed pattern
+	if strings.HasPrefix(p, "!") {
+		parsed.negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		parsed.dirOnly = true
+		p = strings.TrimSuffix(p, "/")
+	}
+	parsed.anchored = strings.Contains(p, "/")
+	parsed.segments = strings.Split(strings.TrimPrefix(p, "/"), "/")
+	return parsed, p != ""
+}
+
+func (p pattern) matches(segments []string) bool {
+	if !p.anchored {
+		ok, _ := path.Match(p.segments[0], segments[len(segments)-1])
+		return ok
+	}
+	return matchSegments(p.segments, segments)
+}
+
+func matchSegments(glob, segments []string) bool {
+	if len(glob) == 0 {
+		return len(segments) == 0
+	}
+	if glob[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(glob[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	ok, _ := path.Match(glob[0], segments[0])
+	return ok && matchSegments(glob[1:], segments[1:])
+}
@@ -0,0 +1,49 @@
+package watcher_test
+
+import (
+	"testing"
+
+	"github.com/foldsh/fold/runtime/watcher"
+)
+
+func TestMatcher(t *testing.T) {
+	m := watcher.NewMatcher(watcher.DefaultIgnore...)
+	m.Add(
+		"# build output",
+		"/dist",
+		"*.log",
+		"!important.log",
+		"docs/**/*.md",
+		"tmp/",
+	)
+	cases := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{".git", true, true},
+		{".git/HEAD", false, true},
+		{"node_modules/express/index.js", false, true},
+		{"src/node_modules/express/index.js", false, true},
+		{"src/.index.ts.swp", false, true},
+		{"src/index.ts~", false, true},
+		{"src/index.ts", false, false},
+		{"dist", true, true},
+		{"dist/index.js", false, true},
+		{"src/dist/index.js", false, false},
+		{"server.log", false, true},
+		{"logs/server.log", false, true},
+		{"important.log", false, false},
+		{"docs/README.md", false, true},
+		{"docs/api/items.md", false, true},
+		{"README.md", false, false},
+		{"tmp", true, true},
+		{"tmp", false, false},
+		{"tmp/cache", false, true},
+	}
+	for _, c := range cases {
+		if ignored := m.Match(c.path, c.isDir); ignored != c.ignored {
+			t.Errorf("Expected Match(%s, %v) to be %v but found %v", c.path, c.isDir, c.ignored, ignored)
+		}
+	}
+}
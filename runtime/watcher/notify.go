@@ -0,0 +1,121 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/fsnotify/fsnotify"
+)
+
+// NotifyWatcher watches for changes using the file system events provided by the OS, e.g. inotify
+// on linux.
+type NotifyWatcher struct {
+	*filter
+	logger   logging.Logger
+	watcher  *fsnotify.Watcher
+	stop     chan struct{}
+	onChange func()
+	// The directories which are currently being watched.
+	watched map[string]struct{}
+}
+
+func newNotifyWatcher(logger logging.Logger, f *filter, onChange func()) (*NotifyWatcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.New("failed to initialise watcher")
+	}
+	return &NotifyWatcher{
+		filter:   f,
+		logger:   logger,
+		watcher:  watcher,
+		stop:     make(chan struct{}),
+		onChange: onChange,
+		watched:  map[string]struct{}{},
+	}, nil
+}
+
+func (w *NotifyWatcher) Close() {
+	w.stop <- struct{}{}
+	w.watcher.Close()
+}
+
+func (w *NotifyWatcher) Watch() error {
+	for _, r := range w.roots {
+		if _, err := w.addDir(r.dir); err != nil {
+			w.logger.Debugf("Error walking directory marked for watching %v", err)
+			return err
+		}
+	}
+	go func() {
+		for {
+			select {
+			case event := <-w.watcher.Events:
+				w.handle(event)
+			case err := <-w.watcher.Errors:
+				w.logger.Debugf("Watcher encountered error %v", err)
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (w *NotifyWatcher) handle(event fsnotify.Event) {
+	// The file may well have been removed, in which case it can't have been a directory that we
+	// care about.
+	fi, err := os.Stat(event.Name)
+	isDir := err == nil && fi.IsDir()
+	changed := w.relevant(event.Name, isDir)
+	if event.Op&fsnotify.Create == fsnotify.Create && isDir && !w.ignored(event.Name, true) {
+		// Files may already have been written to the new directory before it was watched, so
+		// they count as a change too.
+		found, err := w.addDir(event.Name)
+		if err != nil {
+			w.logger.Debugf("Failed to watch the new directory %s: %v", event.Name, err)
+		}
+		changed = changed || found
+	}
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		w.removeDir(event.Name)
+	}
+	if changed {
+		w.onChange()
+	}
+}
+
+// addDir watches the directory and every directory underneath it which isn't ignored. It returns
+// true if it found any files which would have triggered a reload.
+func (w *NotifyWatcher) addDir(dir string) (bool, error) {
+	found := false
+	err := w.walk(dir, func(path string, fi os.FileInfo) error {
+		if !fi.IsDir() {
+			found = found || w.relevant(path, false)
+			return nil
+		}
+		if _, ok := w.watched[path]; ok {
+			return nil
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return err
+		}
+		w.watched[path] = struct{}{}
+		return nil
+	})
+	return found, err
+}
+
+// removeDir stops watching the directory, and everything underneath it, if it was being watched.
+func (w *NotifyWatcher) removeDir(dir string) {
+	for path := range w.watched {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			// The watch has usually been removed already when the directory was deleted, so
+			// there is no need to report an error here.
+			w.watcher.Remove(path)
+			delete(w.watched, path)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"crypto/sha1"
+	"io"
+	"os"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+)
+
+// PollingWatcher finds changes by scanning the directories on an interval and comparing the size
+// and modification time, or the contents, of every file with the previous scan. It works where
+// file system events aren't delivered, such as bind mounts on some docker setups.
+type PollingWatcher struct {
+	*filter
+	logger   logging.Logger
+	interval time.Duration
+	hash     bool
+	stop     chan struct{}
+	// onChange is passed the time the previous scan started, the change happened after it.
+	onChange func(since time.Time)
+	snapshot map[string]fileState
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+	sum     [sha1.Size]byte
+}
+
+func newPollingWatcher(
+	logger logging.Logger,
+	f *filter,
+	onChange func(),
+	s *settings,
+) *PollingWatcher {
+	return newPoller(logger, f, func(time.Time) { onChange() }, s)
+}
+
+func newPoller(
+	logger logging.Logger,
+	f *filter,
+	onChange func(since time.Time),
+	s *settings,
+) *PollingWatcher {
+	return &PollingWatcher{
+		filter:   f,
+		logger:   logger,
+		interval: s.interval,
+		hash:     s.hash,
+		stop:     make(chan struct{}),
+		onChange: onChange,
+	}
+}
+
+func (w *PollingWatcher) Close() {
+	w.stop <- struct{}{}
+}
+
+func (w *PollingWatcher) Watch() error {
+	snapshot, err := w.scan()
+	if err != nil {
+		w.logger.Debugf("Error walking directory marked for watching %v", err)
+		return err
+	}
+	w.snapshot = snapshot
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		last := time.Now()
+		for {
+			select {
+			case <-ticker.C:
+				start := time.Now()
+				snapshot, err := w.scan()
+				if err != nil {
+					w.logger.Debugf("Watcher encountered error %v", err)
+					continue
+				}
+				if changed(w.snapshot, snapshot) {
+					w.onChange(last)
+				}
+				w.snapshot, last = snapshot, start
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (w *PollingWatcher) scan() (map[string]fileState, error) {
+	snapshot := map[string]fileState{}
+	for _, r := range w.roots {
+		err := w.walk(r.dir, func(path string, fi os.FileInfo) error {
+			if fi.IsDir() || !w.relevant(path, false) {
+				return nil
+			}
+			state := fileState{modTime: fi.ModTime(), size: fi.Size()}
+			if w.hash {
+				sum, err := hashFile(path)
+				if os.IsNotExist(err) {
+					// It was removed while we were scanning.
+					return nil
+				} else if err != nil {
+					return err
+				}
+				state.sum = sum
+			}
+			snapshot[path] = state
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+func changed(before, after map[string]fileState) bool {
+	if len(before) != len(after) {
+		return true
+	}
+	for path, state := range after {
+		if previous, ok := before[path]; !ok || previous != state {
+			return true
+		}
+	}
+	return false
+}
+
+func hashFile(path string) ([sha1.Size]byte, error) {
+	var sum [sha1.Size]byte
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer f.Close()
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
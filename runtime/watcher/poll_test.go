@@ -0,0 +1,96 @@
+package watcher_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/logging"
+	"github.com/foldsh/fold/runtime/watcher"
+)
+
+func TestPollingWatcher(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "foldPollingTest")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer os.RemoveAll(testDir)
+	createDir(t, filepath.Join(testDir, "node_modules"))
+	writeFile(t, filepath.Join(testDir, "main.go"), "package main")
+
+	logger := logging.NewTestLogger()
+	counter := &counter{}
+	watcher, err := watcher.NewWatcher(
+		logger,
+		[]string{testDir},
+		counter.increment,
+		watcher.Mode(watcher.POLL),
+		watcher.Interval(10*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := watcher.Watch(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer watcher.Close()
+
+	// Ignored paths aren't scanned at all.
+	writeFile(t, filepath.Join(testDir, "node_modules", "index.js"), "")
+	time.Sleep(50 * time.Millisecond)
+	if counter.value() != 0 {
+		t.Errorf("Expected 0 mutations but found %d", counter.value())
+	}
+
+	// New directories are picked up by the next scan.
+	createDir(t, filepath.Join(testDir, "pkg"))
+	writeFile(t, filepath.Join(testDir, "pkg", "pkg.go"), "package pkg")
+	time.Sleep(50 * time.Millisecond)
+	if counter.value() != 1 {
+		t.Errorf("Expected 1 mutation but found %d", counter.value())
+	}
+}
+
+func TestPollingWatcherHashes(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "foldPollingTest")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer os.RemoveAll(testDir)
+	file := filepath.Join(testDir, "main.go")
+	writeFile(t, file, "hello")
+	modTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	logger := logging.NewTestLogger()
+	counter := &counter{}
+	watcher, err := watcher.NewWatcher(
+		logger,
+		[]string{testDir},
+		counter.increment,
+		watcher.Mode(watcher.POLL),
+		watcher.Interval(10*time.Millisecond),
+		watcher.Hash(true),
+	)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := watcher.Watch(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer watcher.Close()
+
+	// The size and modification time are the same, only the contents differ.
+	writeFile(t, file, "world")
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if counter.value() != 1 {
+		t.Errorf("Expected 1 mutation but found %d", counter.value())
+	}
+}
@@ -1,58 +1,216 @@
 package watcher
 
 import (
-	"errors"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/foldsh/fold/logging"
-	"github.com/fsnotify/fsnotify"
 )
 
-type Watcher struct {
-	dir      string
-	logger   logging.Logger
-	watcher  *fsnotify.Watcher
-	stop     chan struct{}
-	onChange func()
+// Watcher calls a function whenever something changes underneath any of its root directories.
+// Directories created after Watch has been called are watched too.
+type Watcher interface {
+	Watch() error
+	Close()
 }
 
-func NewWatcher(logger logging.Logger, dir string, onChange func()) (*Watcher, error) {
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return nil, errors.New("failed to initialise watcher")
+type ModeT uint8
+
+const (
+	// AUTO uses file system events but falls back to polling if changes are found which didn't
+	// produce any events, as happens with bind mounts on some docker setups.
+	AUTO ModeT = iota + 1
+	// NOTIFY only uses file system events.
+	NOTIFY
+	// POLL only uses polling.
+	POLL
+)
+
+type settings struct {
+	mode       ModeT
+	interval   time.Duration
+	hash       bool
+	ignore     []string
+	gitIgnore  bool
+	extensions map[string]struct{}
+}
+
+type Option func(*settings)
+
+// Mode sets how changes are detected, it is NOTIFY by default.
+func Mode(mode ModeT) Option {
+	return func(s *settings) {
+		s.mode = mode
+	}
+}
+
+// Interval sets how often the directories are scanned for changes when polling. It defaults to
+// one second.
+func Interval(interval time.Duration) Option {
+	return func(s *settings) {
+		s.interval = interval
+	}
+}
+
+// Hash makes polling compare the contents of files rather than just their size and modification
+// time. This is slower but works when modification times aren't reliable.
+func Hash(enabled bool) Option {
+	return func(s *settings) {
+		s.hash = enabled
 	}
-	return &Watcher{dir, logger, watcher, make(chan struct{}), onChange}, nil
 }
 
-func (w *Watcher) Close() {
-	w.stop <- struct{}{}
-	w.watcher.Close()
+// Ignore adds patterns for paths which should not trigger a reload, on top of DefaultIgnore. See
+// Matcher for the syntax.
+func Ignore(patterns ...string) Option {
+	return func(s *settings) {
+		s.ignore = append(s.ignore, patterns...)
+	}
 }
 
-func (w *Watcher) Watch() error {
-	if err := filepath.Walk(w.dir, w.watchDir); err != nil {
-		w.logger.Debugf("Error walking directory marked for watching %v", err)
-		return err
+// GitIgnore sets whether the .gitignore in each root directory is used to ignore paths. It is
+// enabled by default.
+func GitIgnore(enabled bool) Option {
+	return func(s *settings) {
+		s.gitIgnore = enabled
 	}
-	go func() {
-		for {
-			select {
-			case <-w.watcher.Events:
-				w.onChange()
-			case err := <-w.watcher.Errors:
-				w.logger.Debugf("Watcher encountered error %v", err)
-			case <-w.stop:
-				return
+}
+
+// Extensions restricts reloads to changes to files with one of the given extensions, e.g. ".go".
+// Changes to any file trigger a reload when no extensions are given.
+func Extensions(extensions ...string) Option {
+	return func(s *settings) {
+		for _, ext := range extensions {
+			if ext == "" {
+				continue
 			}
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			s.extensions[ext] = struct{}{}
 		}
-	}()
-	return nil
+	}
+}
+
+// NewWatcher creates a watcher for the directories using the configured Mode.
+func NewWatcher(
+	logger logging.Logger,
+	dirs []string,
+	onChange func(),
+	options ...Option,
+) (Watcher, error) {
+	s := &settings{
+		mode:       NOTIFY,
+		interval:   time.Second,
+		ignore:     append([]string{}, DefaultIgnore...),
+		gitIgnore:  true,
+		extensions: map[string]struct{}{},
+	}
+	for _, option := range options {
+		option(s)
+	}
+	f := newFilter(logger, dirs, s)
+	switch s.mode {
+	case POLL:
+		return newPollingWatcher(logger, f, onChange, s), nil
+	case AUTO:
+		return newAutoWatcher(logger, f, onChange, s)
+	default:
+		return newNotifyWatcher(logger, f, onChange)
+	}
+}
+
+// filter decides which paths are watched and which changes are relevant. It is shared by every
+// kind of watcher so that they all agree.
+type filter struct {
+	roots      []*root
+	extensions map[string]struct{}
 }
 
-func (w *Watcher) watchDir(path string, fi os.FileInfo, err error) error {
-	if fi.Mode().IsDir() {
-		return w.watcher.Add(path)
+// root is one of the directories passed to the watcher, along with the patterns for the paths
+// ignored underneath it.
+type root struct {
+	dir    string
+	ignore *Matcher
+}
+
+func newFilter(logger logging.Logger, dirs []string, s *settings) *filter {
+	f := &filter{extensions: s.extensions}
+	for _, dir := range dirs {
+		r := &root{dir: filepath.Clean(dir), ignore: NewMatcher(s.ignore...)}
+		if s.gitIgnore {
+			// The .gitignore patterns come after the ones passed in so that they can be
+			// overridden with a negated pattern in the .gitignore.
+			if err := r.ignore.AddFile(filepath.Join(dir, ".gitignore")); err != nil {
+				logger.Warnf("Failed to read the .gitignore in %s: %v", dir, err)
+			}
+		}
+		f.roots = append(f.roots, r)
+	}
+	return f
+}
+
+// walk calls fn for every file and directory underneath dir, skipping ignored directories.
+func (f *filter) walk(dir string, fn func(path string, fi os.FileInfo) error) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if os.IsNotExist(err) && !f.isRoot(path) {
+			// It was removed while we were walking.
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if fi.IsDir() && !f.isRoot(path) && f.ignored(path, true) {
+			return filepath.SkipDir
+		}
+		return fn(path, fi)
+	})
+}
+
+// relevant returns true if a change to the path should trigger a reload.
+func (f *filter) relevant(path string, isDir bool) bool {
+	if f.ignored(path, isDir) {
+		return false
+	}
+	if len(f.extensions) > 0 {
+		if isDir {
+			return false
+		}
+		_, ok := f.extensions[filepath.Ext(path)]
+		return ok
+	}
+	return true
+}
+
+func (f *filter) isRoot(path string) bool {
+	for _, r := range f.roots {
+		if r.dir == path {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored checks the path against the patterns of the root it is in. When roots are nested the
+// innermost one is used.
+func (f *filter) ignored(path string, isDir bool) bool {
+	var (
+		matcher *Matcher
+		rel     string
+	)
+	for _, r := range f.roots {
+		candidate, err := filepath.Rel(r.dir, path)
+		if err != nil || strings.HasPrefix(candidate, "..") {
+			// The path isn't underneath this root.
+			continue
+		}
+		if matcher == nil || len(candidate) < len(rel) {
+			matcher, rel = r.ignore, candidate
+		}
+	}
+	if matcher == nil || rel == "." {
+		return false
 	}
-	return nil
+	return matcher.Match(rel, isDir)
 }
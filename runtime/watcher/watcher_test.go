@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -28,7 +29,7 @@ func TestWatcher(t *testing.T) {
 
 	logger := logging.NewTestLogger()
 	counter := &counter{}
-	watcher, err := watcher.NewWatcher(logger, testDir, counter.increment)
+	watcher, err := watcher.NewWatcher(logger, []string{testDir}, counter.increment)
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
@@ -42,8 +43,102 @@ func TestWatcher(t *testing.T) {
 	// just hacked it like this.
 	time.Sleep(100 * time.Millisecond)
 	expectedNMutations := N*N + (N * N * N)
-	if counter.count != expectedNMutations {
-		t.Errorf("Expected %d mutations but found %d", expectedNMutations, counter.count)
+	if counter.value() != expectedNMutations {
+		t.Errorf("Expected %d mutations but found %d", expectedNMutations, counter.value())
+	}
+	watcher.Close()
+}
+
+func TestWatcherIgnoresPaths(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "foldWatcherTest")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer os.RemoveAll(testDir)
+
+	for _, dir := range []string{".git", "node_modules", "dist", "src"} {
+		createDir(t, filepath.Join(testDir, dir))
+	}
+	writeFile(t, filepath.Join(testDir, ".gitignore"), "dist/\n")
+
+	logger := logging.NewTestLogger()
+	counter := &counter{}
+	watcher, err := watcher.NewWatcher(
+		logger,
+		[]string{testDir},
+		counter.increment,
+		watcher.Ignore("*.log"),
+		watcher.Extensions("go"),
+	)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := watcher.Watch(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	writeFile(t, filepath.Join(testDir, ".git", "index.go"), "")
+	writeFile(t, filepath.Join(testDir, "node_modules", "index.go"), "")
+	writeFile(t, filepath.Join(testDir, "dist", "main.go"), "")
+	writeFile(t, filepath.Join(testDir, "src", "server.log"), "")
+	writeFile(t, filepath.Join(testDir, "src", "main.ts"), "")
+	writeFile(t, filepath.Join(testDir, "src", ".main.go.swp"), "")
+	writeFile(t, filepath.Join(testDir, "src", "main.go"), "")
+	time.Sleep(100 * time.Millisecond)
+	// Only the write to src/main.go should have been noticed.
+	if counter.value() != 1 {
+		t.Errorf("Expected 1 mutation but found %d", counter.value())
+	}
+	watcher.Close()
+}
+
+func TestWatcherWatchesNewDirectories(t *testing.T) {
+	testDir, err := ioutil.TempDir("", "foldWatcherTest")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer os.RemoveAll(testDir)
+	otherDir, err := ioutil.TempDir("", "foldWatcherTest")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	defer os.RemoveAll(otherDir)
+
+	logger := logging.NewTestLogger()
+	counter := &counter{}
+	watcher, err := watcher.NewWatcher(
+		logger,
+		[]string{testDir, otherDir},
+		counter.increment,
+		watcher.Extensions(".go"),
+	)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if err := watcher.Watch(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	// Creating the directory isn't a change by itself, as it doesn't have the right extension.
+	pkg := filepath.Join(testDir, "pkg")
+	createDir(t, pkg)
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(pkg, "pkg.go"), "")
+	writeFile(t, filepath.Join(otherDir, "main.go"), "")
+	time.Sleep(50 * time.Millisecond)
+	if counter.value() != 2 {
+		t.Errorf("Expected 2 mutations but found %d", counter.value())
+	}
+
+	// Once the directory is removed it is no longer watched, recreating it should still work.
+	if err := os.RemoveAll(pkg); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	createDir(t, pkg)
+	time.Sleep(50 * time.Millisecond)
+	writeFile(t, filepath.Join(pkg, "pkg.go"), "")
+	time.Sleep(50 * time.Millisecond)
+	// Removing pkg.go is a change too.
+	if counter.value() != 4 {
+		t.Errorf("Expected 4 mutations but found %d", counter.value())
 	}
 	watcher.Close()
 }
@@ -74,10 +169,21 @@ func mutateDir(t *testing.T, path string) {
 	}
 }
 
+func writeFile(t *testing.T, path, contents string) {
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("%+v", err)
+	}
+}
+
+// counter is incremented by the watcher's goroutines, so it must be read with value.
 type counter struct {
-	count int
+	count int32
 }
 
 func (c *counter) increment() {
-	c.count++
+	atomic.AddInt32(&c.count, 1)
+}
+
+func (c *counter) value() int {
+	return int(atomic.LoadInt32(&c.count))
 }
@@ -6,37 +6,57 @@ import (
 	"net"
 	"os"
 
-	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
 	"github.com/foldsh/fold/runtime/transport/pb"
+	"github.com/foldsh/fold/version"
 	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 type grpcServer struct {
 	pb.UnimplementedFoldIngressServer
 	server  *grpc.Server
 	service *service
-	logger  logging.Logger
 }
 
 func (gs *grpcServer) start() {
 	foldSockAddr := os.Getenv("FOLD_SOCK_ADDR")
 	lis, err := net.Listen("unix", foldSockAddr)
 	if err != nil {
-		gs.logger.Fatalf("gRPC server failed to listen: %v", err)
+		gs.service.Logger().Fatalf("gRPC server failed to listen: %v", err)
 	}
 	gs.server = grpc.NewServer()
 	pb.RegisterFoldIngressServer(gs.server, gs)
+	healthpb.RegisterHealthServer(gs.server, &healthServer{service: gs.service})
 	if err := gs.server.Serve(lis); err != nil {
-		gs.logger.Fatalf("gRPC server failed to serve: %v", err)
+		gs.service.Logger().Fatalf("gRPC server failed to serve: %v", err)
 	}
 }
 
+// The range of FoldIngress protocol versions this SDK supports.
+const (
+	minProtocolVersion uint32 = 1
+	maxProtocolVersion uint32 = 1
+)
+
+func (gs *grpcServer) Initialize(
+	ctx context.Context,
+	in *pb.InitializeReq,
+) (*pb.InitializeRes, error) {
+	gs.service.initialize(in.ServiceName, in.Stage)
+	return &pb.InitializeRes{
+		SdkName:            "fold-go",
+		SdkVersion:         version.FoldVersion.String(),
+		MinProtocolVersion: minProtocolVersion,
+		MaxProtocolVersion: maxProtocolVersion,
+	}, nil
+}
+
 func (gs *grpcServer) GetManifest(
 	ctx context.Context,
 	in *pb.ManifestReq,
 ) (*manifest.Manifest, error) {
-	return gs.service.manifest, nil
+	return gs.service.getManifest(), nil
 }
 
 func (gs *grpcServer) DoRequest(
@@ -50,6 +70,11 @@ func (gs *grpcServer) DoRequest(
 		QueryParams: decodeMapStringArray(in.QueryParams),
 		Route:       in.Route,
 	}
+	if len(in.Claims) > 0 {
+		if err := json.Unmarshal(in.Claims, &req.Claims); err != nil {
+			gs.service.Logger().Errorf("failed to decode the token claims: %v", err)
+		}
+	}
 	if req.HTTPMethod == "PUT" || req.HTTPMethod == "POST" {
 		var body map[string]interface{}
 		err := json.Unmarshal(in.Body, &body)
@@ -67,7 +92,7 @@ func (gs *grpcServer) DoRequest(
 	if err != nil {
 		// There is a bug in the service code, panicking is the best course of action here
 		// so that this (hopefully) never makes it into production.
-		gs.logger.Panicf("failed to marshal json: %v", err)
+		gs.service.Logger().Panicf("failed to marshal json: %v", err)
 	}
 	return &manifest.FoldHTTPResponse{
 		Status:  int32(res.StatusCode),
@@ -97,3 +122,23 @@ TODO
 How to AWS implement the type conversion for lambbda handlers? That' is quite nice.
 It would be good to do the same thing for handler.
 */
+
+// healthServer implements the grpc.health.v1 protocol, which the runtime uses to check that the
+// service is still responsive.
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+	service *service
+}
+
+func (hs *healthServer) Check(
+	ctx context.Context,
+	in *healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	if err := hs.service.checkHealth(ctx); err != nil {
+		hs.service.Logger().Warnf("Health check failed: %v", err)
+		return &healthpb.HealthCheckResponse{
+			Status: healthpb.HealthCheckResponse_NOT_SERVING,
+		}, nil
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
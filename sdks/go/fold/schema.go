@@ -0,0 +1,169 @@
+package fold
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/foldsh/fold/manifest"
+)
+
+// BodySchema requires the body of requests to the route to satisfy the JSON Schema. Requests
+// which don't are rejected by the runtime with a 400 before they reach the handler.
+func BodySchema(schema string) RouteOption {
+	return func(r *manifest.Route) {
+		r.BodySchema = schema
+	}
+}
+
+// QuerySchema requires the query parameters of requests to the route to satisfy the JSON
+// Schema. The parameters are validated as an object whose values are strings, unless the schema
+// gives a property another type.
+func QuerySchema(schema string) RouteOption {
+	return func(r *manifest.Route) {
+		r.QuerySchema = schema
+	}
+}
+
+// BodyType requires the body of requests to the route to match the type of v, which should be a
+// struct. The schema is generated with SchemaFor.
+func BodyType(v interface{}) RouteOption {
+	return BodySchema(SchemaFor(v))
+}
+
+// QueryType requires the query parameters of requests to the route to match the fields of v,
+// which should be a struct. The schema is generated with SchemaFor.
+func QueryType(v interface{}) RouteOption {
+	return QuerySchema(SchemaFor(v))
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaFor generates a JSON Schema for the type of v. The names of struct fields follow the
+// encoding/json rules, and fields are required unless their json tag has the omitempty option.
+// Types which marshal themselves to JSON, including json.RawMessage, accept any value, and
+// recursive types accept any value where they recur as they can't be described without $ref.
+func SchemaFor(v interface{}) string {
+	schema := schemaFor(reflect.TypeOf(v), map[reflect.Type]bool{})
+	bs, err := json.Marshal(schema)
+	if err != nil {
+		// The schema is built from maps and slices of strings so this can't fail.
+		panic(fmt.Sprintf("failed to marshal JSON schema: %v", err))
+	}
+	return string(bs)
+}
+
+func schemaFor(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		// The runtime doesn't support the format keyword, so times are only checked to be strings.
+		return map[string]interface{}{"type": "string"}
+	}
+	if t == rawMessageType || implements(t, marshalerType) {
+		return map[string]interface{}{}
+	}
+	if implements(t, textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		if seen[t] {
+			return map[string]interface{}{}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes byte slices as base64 strings.
+			return map[string]interface{}{"type": "string"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaFor(t.Elem(), seen),
+		}
+	case reflect.Struct:
+		properties := map[string]interface{}{}
+		required := []string{}
+		addStructFields(t, seen, properties, &required)
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// implements reports whether values of the type, or pointers to them, implement the interface.
+// encoding/json uses the pointer's methods when the value is addressable, e.g. a struct field.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+func addStructFields(
+	t reflect.Type,
+	seen map[reflect.Type]bool,
+	properties map[string]interface{},
+	required *[]string,
+) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx != -1 {
+			name, opts = tag[:idx], tag[idx:]
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				// Embedded structs are flattened by encoding/json.
+				addStructFields(ft, seen, properties, required)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			// Unexported
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type, seen)
+		if !strings.Contains(opts, ",omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package fold
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/foldsh/fold/internal/testutils"
+	"github.com/foldsh/fold/runtime/schema"
+)
+
+type base struct {
+	ID string `json:"id"`
+}
+
+type item struct {
+	base
+	Name     string            `json:"name"`
+	Price    float64           `json:"price,omitempty"`
+	Quantity *int              `json:"quantity"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Created  time.Time         `json:"created"`
+	Parent   *item             `json:"parent,omitempty"`
+	Internal string            `json:"-"`
+	private  string
+}
+
+func TestSchemaFor(t *testing.T) {
+	var actual map[string]interface{}
+	if err := json.Unmarshal([]byte(SchemaFor(item{})), &actual); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expected := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":       map[string]interface{}{"type": "string"},
+			"name":     map[string]interface{}{"type": "string"},
+			"price":    map[string]interface{}{"type": "number"},
+			"quantity": map[string]interface{}{"type": "integer"},
+			"tags": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+			"labels": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{"type": "string"},
+			},
+			"created": map[string]interface{}{"type": "string"},
+			"parent":  map[string]interface{}{},
+		},
+		"required": []interface{}{"id", "name", "quantity", "tags", "created"},
+	}
+	testutils.Diff(t, expected, actual, "Schema did not match expectation")
+}
+
+type tree map[string]tree
+
+type list []list
+
+type money struct {
+	cents int
+}
+
+func (m money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%d.%02d", m.cents/100, m.cents%100))
+}
+
+type level int
+
+func (l *level) MarshalText() ([]byte, error) {
+	return []byte("info"), nil
+}
+
+type order struct {
+	Extra json.RawMessage `json:"extra"`
+	Total money           `json:"total"`
+	Level level           `json:"level"`
+	Tree  tree            `json:"tree"`
+	List  list            `json:"list"`
+}
+
+func TestSchemaForSpecialTypes(t *testing.T) {
+	var actual map[string]interface{}
+	if err := json.Unmarshal([]byte(SchemaFor(order{})), &actual); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	expected := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"extra": map[string]interface{}{},
+			"total": map[string]interface{}{},
+			"level": map[string]interface{}{"type": "string"},
+			"tree": map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": map[string]interface{}{},
+			},
+			"list": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{},
+			},
+		},
+		"required": []interface{}{"extra", "total", "level", "tree", "list"},
+	}
+	testutils.Diff(t, expected, actual, "Schema did not match expectation")
+}
+
+func TestSchemaForCompiles(t *testing.T) {
+	// The runtime rejects schemas with keywords it doesn't support, so the generated schemas
+	// must only use the ones it does.
+	for _, v := range []interface{}{item{}, order{}} {
+		if _, err := schema.Compile(SchemaFor(v)); err != nil {
+			t.Errorf("Expected the generated schema for %T to compile but got %v", v, err)
+		}
+	}
+}
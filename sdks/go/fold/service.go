@@ -1,8 +1,13 @@
 package fold
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
 
 	"github.com/foldsh/fold/logging"
 	"github.com/foldsh/fold/manifest"
@@ -15,6 +20,9 @@ type Request struct {
 	PathParams  map[string]string
 	QueryParams map[string][]string
 	Route       string
+	// The claims from the caller's verified token. It is only set for routes registered with
+	// RequireAuth.
+	Claims map[string]interface{}
 }
 
 type Response struct {
@@ -25,25 +33,172 @@ type Response struct {
 
 type Handler func(*Request, *Response)
 
+// RouteOption configures how the fold runtime handles requests to a route.
+type RouteOption func(*manifest.Route)
+
+// RequireAuth rejects requests to the route unless they carry a valid bearer token which grants
+// all of the given scopes. The token is verified by the runtime and its claims are available
+// on the Request.
+func RequireAuth(scopes ...string) RouteOption {
+	return func(r *manifest.Route) {
+		r.Auth = &manifest.AuthPolicy{Scopes: scopes}
+	}
+}
+
+// Cache lets the runtime cache successful GET responses from the route for the given time. A
+// separate response is cached for each value of the vary headers, e.g. Accept-Language.
+func Cache(ttl time.Duration, varyBy ...string) RouteOption {
+	return func(r *manifest.Route) {
+		r.Cache = &manifest.CachePolicy{Ttl: int32(ttl / time.Second), VaryBy: varyBy}
+	}
+}
+
+// Summary sets a short summary of what the route does. It is used in the generated OpenAPI
+// document for the service.
+func Summary(summary string) RouteOption {
+	return func(r *manifest.Route) {
+		r.Summary = summary
+	}
+}
+
+// Description sets a longer description of the route for the generated OpenAPI document. It may
+// contain markdown.
+func Description(description string) RouteOption {
+	return func(r *manifest.Route) {
+		r.Description = description
+	}
+}
+
+// Tags groups the route with others in the generated OpenAPI document.
+func Tags(tags ...string) RouteOption {
+	return func(r *manifest.Route) {
+		r.Tags = tags
+	}
+}
+
+// RateLimitKey determines how clients are told apart when applying a rate limit.
+type RateLimitKey struct {
+	key    manifest.RateLimit_Key
+	header string
+}
+
+var (
+	// ByIP gives every client IP address its own limit.
+	ByIP = RateLimitKey{key: manifest.RateLimit_IP}
+	// Global shares a single limit between every client.
+	Global = RateLimitKey{key: manifest.RateLimit_GLOBAL}
+)
+
+// ByHeader gives every value of the header its own limit, e.g. ByHeader("X-Api-Key"). Requests
+// without the header are limited by their IP address.
+func ByHeader(header string) RateLimitKey {
+	return RateLimitKey{key: manifest.RateLimit_HEADER, header: header}
+}
+
+// RateLimit allows each client, as determined by the key, to make the given number of requests
+// per period. Requests over the limit are rejected by the runtime with a 429.
+func RateLimit(requests int, period time.Duration, key RateLimitKey) RouteOption {
+	return func(r *manifest.Route) {
+		// The manifest only has a resolution of one second.
+		seconds := (period + time.Second - 1) / time.Second
+		r.RateLimit = &manifest.RateLimit{
+			Key:      key.key,
+			Header:   key.header,
+			Requests: uint32(requests),
+			Period:   uint32(seconds),
+		}
+	}
+}
+
+// Idempotent lets clients safely retry requests to a POST or PATCH route by sending an
+// Idempotency-Key header. The runtime stores the response to the first request with a key for
+// the given time, or a day if it is zero, and sends it in response to any retries.
+func Idempotent(ttl time.Duration) RouteOption {
+	return func(r *manifest.Route) {
+		r.Idempotency = &manifest.IdempotencyPolicy{Ttl: int32(ttl / time.Second)}
+	}
+}
+
+// CORS describes the Cross-Origin Resource Sharing policy for the service. The fold runtime uses
+// it to answer preflight requests and to add the CORS headers to responses.
+type CORS struct {
+	// The origins allowed to make requests, e.g. https://fold.sh. A '*' can be used in place of
+	// a subdomain, e.g. https://*.fold.sh, or on its own to allow any origin.
+	AllowedOrigins []string
+	// The allowed methods. If empty, the methods registered for the route are allowed.
+	AllowedMethods []string
+	// The allowed request headers. A single '*' allows any header.
+	AllowedHeaders []string
+	// The response headers that browsers are allowed to access.
+	ExposedHeaders []string
+	// Whether requests may include credentials such as cookies.
+	AllowCredentials bool
+	// How long browsers may cache the result of a preflight request.
+	MaxAge time.Duration
+}
+
+// Compression controls how the fold runtime compresses responses. Responses are compressed with
+// gzip or deflate, depending on what the client accepts, unless it is disabled.
+type Compression struct {
+	// Disabled turns compression off for the service.
+	Disabled bool
+	// Responses smaller than this many bytes are not compressed, it defaults to 1024.
+	MinSize int
+	// Content types which should not be compressed, e.g. application/x-protobuf. Images, audio,
+	// video and archives are never compressed.
+	SkipContentTypes []string
+}
+
+// Static describes a directory of static files which the fold runtime serves itself, e.g. the
+// build of a single page app. Only GET and HEAD requests are served from it, and the routes of the
+// service take precedence over the files.
+type Static struct {
+	// The URL prefix the files are served under, e.g. /assets.
+	Prefix string
+	// The directory the files are served from, in the image.
+	Dir string
+	// A file in the directory which is served in place of missing files, e.g. index.html.
+	Fallback string
+	// The Cache-Control header sent with the files, e.g. public, max-age=3600.
+	CacheControl string
+}
+
 type Service interface {
 	Start()
 	Version(major, minor, patch int)
-	Get(string, Handler)
-	Put(string, Handler)
-	Post(string, Handler)
-	Delete(string, Handler)
+	CORS(CORS)
+	Compression(Compression)
+	Static(Static)
+	HealthCheck(func(context.Context) error)
+	Get(string, Handler, ...RouteOption)
+	Put(string, Handler, ...RouteOption)
+	Post(string, Handler, ...RouteOption)
+	Delete(string, Handler, ...RouteOption)
 	Logger() logging.Logger
 }
 
 func NewService() Service {
-	stage := os.Getenv("FOLD_STAGE")
+	// The runtime sends the name and stage in the handshake, and the logger is replaced once it
+	// has. Older runtimes don't send a handshake but set them in the environment instead.
 	name := os.Getenv("FOLD_SERVICE_NAME")
+	s := &service{
+		name:     name,
+		handlers: make(map[string]map[string]Handler),
+		logger:   newLogger(os.Getenv("FOLD_STAGE")),
+		manifest: &manifest.Manifest{Name: name},
+	}
+	grpcServer := &grpcServer{service: s}
+	s.server = grpcServer
+	return s
+}
+
+func newLogger(stage string) logging.Logger {
 	var (
 		logger logging.Logger
 		err    error
 	)
 	switch stage {
-	case "PRODUCTION":
+	case "PROD":
 		logger, err = logging.NewLogger(logging.Info, true)
 	case "LOCAL":
 		logger, err = logging.NewLogger(logging.Debug, false)
@@ -53,30 +208,50 @@ func NewService() Service {
 	if err != nil {
 		panic(fmt.Sprintf("failed to start fold logger: %v", err))
 	}
-	s := &service{
-		name:     name,
-		handlers: make(map[string]map[string]Handler),
-		logger:   logger,
-		manifest: &manifest.Manifest{Name: name},
-	}
-	grpcServer := &grpcServer{service: s, logger: logger}
-	s.server = grpcServer
-	return s
+	return logger
 }
 
 type service struct {
+	// Guards the name, the manifest's name and the logger, which are set by the handshake while
+	// the gRPC server is already serving.
+	mutex    sync.RWMutex
 	name     string
 	server   *grpcServer
 	manifest *manifest.Manifest
 	handlers map[string]map[string]Handler
 	logger   logging.Logger
+	health   func(context.Context) error
 }
 
 func (s *service) Start() {
-	s.logger.Infof("Starting fold service %v", s.name)
 	s.server.start()
 }
 
+// initialize applies the settings the runtime sends in the handshake.
+func (s *service) initialize(name, stage string) {
+	// The logger is built before taking the lock so that it isn't held any longer than needed.
+	logger := newLogger(stage)
+	s.mutex.Lock()
+	if name != "" {
+		s.name = name
+		s.manifest.Name = name
+	}
+	if stage != "" {
+		s.logger = logger
+	}
+	logger, name = s.logger, s.name
+	s.mutex.Unlock()
+	logger.Infof("Starting fold service %v", name)
+}
+
+// getManifest returns a copy of the manifest which is safe to send while the handshake is
+// applied.
+func (s *service) getManifest() *manifest.Manifest {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return proto.Clone(s.manifest).(*manifest.Manifest)
+}
+
 func (s *service) Version(major, minor, patch int) {
 	s.manifest.Version = &manifest.Version{
 		Major: int32(major),
@@ -85,53 +260,108 @@ func (s *service) Version(major, minor, patch int) {
 	}
 }
 
-func (s *service) Get(route string, handler Handler) {
-	s.registerHandler("GET", route, handler)
+func (s *service) CORS(cors CORS) {
+	s.manifest.Cors = &manifest.CorsPolicy{
+		AllowedOrigins:   cors.AllowedOrigins,
+		AllowedMethods:   cors.AllowedMethods,
+		AllowedHeaders:   cors.AllowedHeaders,
+		ExposedHeaders:   cors.ExposedHeaders,
+		AllowCredentials: cors.AllowCredentials,
+		MaxAge:           int32(cors.MaxAge / time.Second),
+	}
+}
+
+func (s *service) Compression(compression Compression) {
+	s.manifest.Compression = &manifest.CompressionPolicy{
+		Disabled:         compression.Disabled,
+		MinSize:          int32(compression.MinSize),
+		SkipContentTypes: compression.SkipContentTypes,
+	}
+}
+
+func (s *service) Static(static Static) {
+	s.manifest.Static = append(s.manifest.Static, &manifest.StaticMount{
+		Prefix:       static.Prefix,
+		Dir:          static.Dir,
+		Fallback:     static.Fallback,
+		CacheControl: static.CacheControl,
+	})
+}
+
+// HealthCheck sets a function which the runtime calls periodically to check that the service is
+// working, e.g. that it can still reach its database. If it returns an error, or doesn't return
+// in time, too many times in a row then the runtime restarts the service. The runtime only checks
+// the service when health-check.enabled is set. Without a check the service is reported healthy
+// as long as the sdk responds, even if its handlers are stuck.
+func (s *service) HealthCheck(check func(context.Context) error) {
+	s.health = check
+}
+
+func (s *service) checkHealth(ctx context.Context) error {
+	if s.health == nil {
+		return nil
+	}
+	return s.health(ctx)
+}
+
+func (s *service) Get(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("GET", route, handler, options...)
 }
 
-func (s *service) Head(route string, handler Handler) {
-	s.registerHandler("HEAD", route, handler)
+func (s *service) Head(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("HEAD", route, handler, options...)
 }
 
-func (s *service) Post(route string, handler Handler) {
-	s.registerHandler("POST", route, handler)
+func (s *service) Post(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("POST", route, handler, options...)
 }
 
-func (s *service) Put(route string, handler Handler) {
-	s.registerHandler("PUT", route, handler)
+func (s *service) Put(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("PUT", route, handler, options...)
 }
 
-func (s *service) Delete(route string, handler Handler) {
-	s.registerHandler("DELETE", route, handler)
+func (s *service) Delete(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("DELETE", route, handler, options...)
 }
 
-func (s *service) Connect(route string, handler Handler) {
-	s.registerHandler("CONNECT", route, handler)
+func (s *service) Connect(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("CONNECT", route, handler, options...)
 }
 
-func (s *service) Options(route string, handler Handler) {
-	s.registerHandler("OPTIONS", route, handler)
+func (s *service) Options(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("OPTIONS", route, handler, options...)
 }
 
-func (s *service) Trace(route string, handler Handler) {
-	s.registerHandler("TRACE", route, handler)
+func (s *service) Trace(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("TRACE", route, handler, options...)
 }
 
-func (s *service) Patch(route string, handler Handler) {
-	s.registerHandler("PATCH", route, handler)
+func (s *service) Patch(route string, handler Handler, options ...RouteOption) {
+	s.registerHandler("PATCH", route, handler, options...)
 }
 
 func (s *service) Logger() logging.Logger {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
 	return s.logger
 }
 
-func (s *service) registerHandler(method, route string, handler Handler) {
+func (s *service) registerHandler(
+	method, route string,
+	handler Handler,
+	options ...RouteOption,
+) {
 	// We can safely ignore the error because we control which strings it is possible to pass in .
 	httpMethod, _ := manifest.HTTPMethodFromString(method)
-	s.manifest.Routes = append(s.manifest.Routes, &manifest.Route{
-		HttpMethod: httpMethod,
-		Route:      route,
-	})
+	r := &manifest.Route{HttpMethod: httpMethod, Route: route}
+	for _, option := range options {
+		option(r)
+	}
+	s.manifest.Routes = append(s.manifest.Routes, r)
+	// The runtime would refuse to serve the service, so it's better to fail straight away.
+	if err := manifest.Validate(s.manifest); err != nil {
+		panic(fmt.Sprintf("fold: %v", err))
+	}
 	if _, exists := s.handlers[route]; !exists {
 		s.handlers[route] = make(map[string]Handler)
 	}
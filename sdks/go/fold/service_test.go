@@ -1 +1,107 @@
 package fold
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/foldsh/fold/runtime/transport/pb"
+)
+
+func TestInvalidRoutesPanicAtRegistration(t *testing.T) {
+	handler := func(*Request, *Response) {}
+	cases := map[string]func(Service){
+		"duplicate route": func(svc Service) {
+			svc.Get("/items", handler)
+			svc.Get("/items", handler)
+		},
+		"conflicting wildcards": func(svc Service) {
+			svc.Get("/items/:id", handler)
+			svc.Get("/items/:name", handler)
+		},
+		"reserved path": func(svc Service) {
+			svc.Get("/_foldadmin/manifest", handler)
+		},
+	}
+	for name, register := range cases {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), "invalid route") {
+					t.Errorf("Expected registration to panic with the invalid route but got %v", r)
+				}
+			}()
+			register(NewService())
+		})
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	svc := NewService().(*service)
+	res, err := svc.server.Initialize(
+		context.Background(),
+		&pb.InitializeReq{ServiceName: "items", Stage: "LOCAL", ProtocolVersion: 1},
+	)
+	if err != nil {
+		t.Fatalf("Expected the handshake to succeed but got %v", err)
+	}
+	if res.SdkName != "fold-go" || res.MinProtocolVersion > 1 || res.MaxProtocolVersion < 1 {
+		t.Errorf("Expected the SDK to support protocol version 1 but got %v", res)
+	}
+	if svc.manifest.Name != "items" {
+		t.Errorf("Expected the service name to be set on the manifest but found %s", svc.manifest.Name)
+	}
+}
+
+func TestNameFromTheEnvironment(t *testing.T) {
+	// Older runtimes don't send a handshake, so the name is read from the environment.
+	os.Setenv("FOLD_SERVICE_NAME", "items")
+	defer os.Unsetenv("FOLD_SERVICE_NAME")
+	svc := NewService().(*service)
+	if name := svc.getManifest().Name; name != "items" {
+		t.Errorf("Expected the service name to be set on the manifest but found %s", name)
+	}
+}
+
+func TestInitializeWhileServing(t *testing.T) {
+	// The handshake is applied by the gRPC server while other requests may be handled, this is
+	// only meaningful with -race.
+	svc := NewService().(*service)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.server.Initialize(
+			context.Background(),
+			&pb.InitializeReq{ServiceName: "items", Stage: "LOCAL", ProtocolVersion: 1},
+		)
+	}()
+	svc.Logger().Debugf("Logging during the handshake")
+	svc.server.GetManifest(context.Background(), &pb.ManifestReq{})
+	<-done
+	if name := svc.getManifest().Name; name != "items" {
+		t.Errorf("Expected the service name to be set on the manifest but found %s", name)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	svc := NewService().(*service)
+	hs := &healthServer{service: svc}
+	expectStatus := func(expected healthpb.HealthCheckResponse_ServingStatus) {
+		t.Helper()
+		res, err := hs.Check(context.Background(), &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatalf("Expected the health check to succeed but got %v", err)
+		}
+		if res.Status != expected {
+			t.Errorf("Expected the service to be %v but found %v", expected, res.Status)
+		}
+	}
+
+	expectStatus(healthpb.HealthCheckResponse_SERVING)
+	svc.HealthCheck(func(context.Context) error { return errors.New("database unreachable") })
+	expectStatus(healthpb.HealthCheckResponse_NOT_SERVING)
+}
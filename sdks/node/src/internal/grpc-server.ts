@@ -19,6 +19,12 @@ import { FoldHTTPRequestWrapper } from "./req-wrapper";
 import { FoldHTTPResponseWrapper } from "./res-wrapper";
 import { makeManifest, ManifestSpec } from "./utils";
 import { RouteTable } from "./route-table";
+import { HealthService, newHealthServer } from "./health";
+import {
+  HandshakeService,
+  InitializeReq,
+  newHandshakeServer,
+} from "./handshake";
 
 /**
  * THe gRPC server implementation that backs a service.
@@ -30,17 +36,20 @@ export class GrpcServer {
   private router: RouteTable;
   private server!: Server;
   private socket: string;
+  private onInitialize: (req: InitializeReq) => void;
 
   constructor(
     logger: Logger,
     router: RouteTable,
     manifest: ManifestSpec,
-    socket: string
+    socket: string,
+    onInitialize: (req: InitializeReq) => void = () => {}
   ) {
     this.router = router;
     this.manifest = makeManifest(manifest);
     this.logger = logger;
     this.socket = socket;
+    this.onInitialize = onInitialize;
   }
 
   /**
@@ -61,7 +70,20 @@ export class GrpcServer {
     const socketAddr = `unix://${this.socket}`;
     this.logger.debug(`starting server on socket ${socketAddr}`);
     this.server = new Server();
-    this.server.addService(FoldIngressService, newFoldIngressServer(this));
+    // Initialize is part of the FoldIngress service but is implemented by hand, see handshake.ts.
+    this.server.addService(
+      { ...FoldIngressService, ...HandshakeService } as any,
+      {
+        ...newFoldIngressServer(this),
+        ...newHandshakeServer((req) => {
+          this.logger.debug(
+            `initializing with protocol version ${req.protocolVersion}`
+          );
+          this.onInitialize(req);
+        }),
+      }
+    );
+    this.server.addService(HealthService, newHealthServer());
     this.server.bindAsync(
       socketAddr,
       credentials.createInsecure() as any,
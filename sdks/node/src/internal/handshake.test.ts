@@ -0,0 +1,56 @@
+import {
+  decodeInitializeReq,
+  decodeInitializeRes,
+  encodeInitializeReq,
+  encodeInitializeRes,
+  InitializeReq,
+} from "./handshake";
+
+describe("InitializeReq", () => {
+  const req: InitializeReq = {
+    runtimeVersion: "v0.1.3",
+    protocolVersion: 1,
+    stage: "LOCAL",
+    serviceName: "items",
+    features: ["streaming"],
+  };
+  test("should decode what the runtime sends", () => {
+    // Encoded by the runtime's generated Go code.
+    const buf = Buffer.from(
+      "0a0676302e312e3310011a054c4f43414c22056974656d732a0973747265616d696e67",
+      "hex"
+    );
+    expect(decodeInitializeReq(buf)).toEqual(req);
+    expect(encodeInitializeReq(req)).toEqual(buf);
+  });
+  test("should skip unknown fields", () => {
+    const buf = Buffer.concat([
+      Buffer.from([0x30, 0x2a, 0x3a, 0x02, 0x68, 0x69]),
+      encodeInitializeReq(req),
+    ]);
+    expect(decodeInitializeReq(buf)).toEqual(req);
+  });
+});
+
+describe("InitializeRes", () => {
+  test("should decode what it encodes", () => {
+    const res = {
+      sdkName: "fold-node",
+      sdkVersion: "v0.1.3",
+      minProtocolVersion: 1,
+      maxProtocolVersion: 300,
+      features: [],
+    };
+    expect(decodeInitializeRes(encodeInitializeRes(res))).toEqual(res);
+  });
+  test("should omit the default values", () => {
+    const res = {
+      sdkName: "",
+      sdkVersion: "",
+      minProtocolVersion: 0,
+      maxProtocolVersion: 0,
+      features: [],
+    };
+    expect(encodeInitializeRes(res)).toEqual(Buffer.alloc(0));
+  });
+});
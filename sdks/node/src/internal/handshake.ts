@@ -0,0 +1,282 @@
+import {
+  ServiceDefinition,
+  ServerUnaryCall,
+  sendUnaryData,
+  UntypedServiceImplementation,
+} from "@grpc/grpc-js";
+
+/**
+ * The range of FoldIngress protocol versions this SDK supports.
+ */
+export const MIN_PROTOCOL_VERSION = 1;
+export const MAX_PROTOCOL_VERSION = 1;
+
+export const SDK_NAME = "fold-node";
+// The version is kept in sync with the runtime's by the update-version script.
+export const SDK_VERSION = `v${require("../../package.json").version}`;
+
+/**
+ * The settings the runtime sends when it connects, see InitializeReq in ingress.proto.
+ */
+export interface InitializeReq {
+  runtimeVersion: string;
+  protocolVersion: number;
+  stage: string;
+  serviceName: string;
+  features: string[];
+}
+
+/**
+ * The SDK's reply to the handshake, see InitializeRes in ingress.proto.
+ */
+export interface InitializeRes {
+  sdkName: string;
+  sdkVersion: string;
+  minProtocolVersion: number;
+  maxProtocolVersion: number;
+  features: string[];
+}
+
+/**
+ * The definition of the Initialize call of the FoldIngress service. It is served alongside the
+ * generated service, and like the health check its messages are encoded by hand rather than
+ * generated from the proto.
+ */
+export const HandshakeService: ServiceDefinition<UntypedServiceImplementation> = {
+  initialize: {
+    path: "/ingress.FoldIngress/Initialize",
+    requestStream: false,
+    responseStream: false,
+    requestSerialize: encodeInitializeReq,
+    requestDeserialize: decodeInitializeReq,
+    responseSerialize: encodeInitializeRes,
+    responseDeserialize: decodeInitializeRes,
+  },
+};
+
+/**
+ * Calls onInitialize with the settings from the runtime and replies with what the SDK supports.
+ */
+export function newHandshakeServer(
+  onInitialize: (req: InitializeReq) => void
+): UntypedServiceImplementation {
+  return {
+    initialize(
+      call: ServerUnaryCall<InitializeReq, InitializeRes>,
+      callback: sendUnaryData<InitializeRes>
+    ): void {
+      onInitialize(call.request);
+      callback(null, {
+        sdkName: SDK_NAME,
+        sdkVersion: SDK_VERSION,
+        minProtocolVersion: MIN_PROTOCOL_VERSION,
+        maxProtocolVersion: MAX_PROTOCOL_VERSION,
+        features: [],
+      });
+    },
+  };
+}
+
+// The protobuf wire types used by the handshake messages.
+const VARINT = 0;
+const FIXED64 = 1;
+const LENGTH_DELIMITED = 2;
+const FIXED32 = 5;
+
+export function encodeInitializeReq(req: InitializeReq): Buffer {
+  const w = new Writer();
+  w.string(1, req.runtimeVersion);
+  w.varint(2, req.protocolVersion);
+  w.string(3, req.stage);
+  w.string(4, req.serviceName);
+  req.features.forEach((feature) => w.string(5, feature, true));
+  return w.finish();
+}
+
+export function decodeInitializeReq(buf: Buffer): InitializeReq {
+  const req: InitializeReq = {
+    runtimeVersion: "",
+    protocolVersion: 0,
+    stage: "",
+    serviceName: "",
+    features: [],
+  };
+  decode(buf, (field, r) => {
+    switch (field) {
+      case 1:
+        req.runtimeVersion = r.string();
+        return true;
+      case 2:
+        req.protocolVersion = r.varint();
+        return true;
+      case 3:
+        req.stage = r.string();
+        return true;
+      case 4:
+        req.serviceName = r.string();
+        return true;
+      case 5:
+        req.features.push(r.string());
+        return true;
+    }
+    return false;
+  });
+  return req;
+}
+
+export function encodeInitializeRes(res: InitializeRes): Buffer {
+  const w = new Writer();
+  w.string(1, res.sdkName);
+  w.string(2, res.sdkVersion);
+  w.varint(3, res.minProtocolVersion);
+  w.varint(4, res.maxProtocolVersion);
+  res.features.forEach((feature) => w.string(5, feature, true));
+  return w.finish();
+}
+
+export function decodeInitializeRes(buf: Buffer): InitializeRes {
+  const res: InitializeRes = {
+    sdkName: "",
+    sdkVersion: "",
+    minProtocolVersion: 0,
+    maxProtocolVersion: 0,
+    features: [],
+  };
+  decode(buf, (field, r) => {
+    switch (field) {
+      case 1:
+        res.sdkName = r.string();
+        return true;
+      case 2:
+        res.sdkVersion = r.string();
+        return true;
+      case 3:
+        res.minProtocolVersion = r.varint();
+        return true;
+      case 4:
+        res.maxProtocolVersion = r.varint();
+        return true;
+      case 5:
+        res.features.push(r.string());
+        return true;
+    }
+    return false;
+  });
+  return res;
+}
+
+/**
+ * Writes fields in the protobuf encoding. Fields with the default value are omitted, as proto3
+ * requires, unless they are elements of a repeated field.
+ */
+class Writer {
+  private bytes: number[] = [];
+
+  string(field: number, value: string, repeated: boolean = false): void {
+    if (value === "" && !repeated) {
+      return;
+    }
+    const encoded = Buffer.from(value, "utf8");
+    this.tag(field, LENGTH_DELIMITED);
+    this.rawVarint(encoded.length);
+    encoded.forEach((b) => this.bytes.push(b));
+  }
+
+  varint(field: number, value: number): void {
+    if (value === 0) {
+      return;
+    }
+    this.tag(field, VARINT);
+    this.rawVarint(value);
+  }
+
+  finish(): Buffer {
+    return Buffer.from(this.bytes);
+  }
+
+  private tag(field: number, wireType: number): void {
+    this.rawVarint(field * 8 + wireType);
+  }
+
+  private rawVarint(value: number): void {
+    while (value > 0x7f) {
+      this.bytes.push((value % 0x80) | 0x80);
+      value = Math.floor(value / 0x80);
+    }
+    this.bytes.push(value);
+  }
+}
+
+class Reader {
+  public pos: number = 0;
+
+  constructor(private buf: Buffer) {}
+
+  done(): boolean {
+    return this.pos >= this.buf.length;
+  }
+
+  varint(): number {
+    let result = 0;
+    let shift = 1;
+    for (;;) {
+      if (this.done()) {
+        throw new Error("truncated varint");
+      }
+      const b = this.buf[this.pos++];
+      result += (b & 0x7f) * shift;
+      if (b < 0x80) {
+        return result;
+      }
+      shift *= 0x80;
+    }
+  }
+
+  string(): string {
+    return this.bytes().toString("utf8");
+  }
+
+  bytes(): Buffer {
+    const length = this.varint();
+    if (this.pos + length > this.buf.length) {
+      throw new Error("truncated field");
+    }
+    const bytes = this.buf.subarray(this.pos, this.pos + length);
+    this.pos += length;
+    return bytes;
+  }
+
+  skip(wireType: number): void {
+    switch (wireType) {
+      case VARINT:
+        this.varint();
+        return;
+      case FIXED64:
+        this.pos += 8;
+        return;
+      case LENGTH_DELIMITED:
+        this.bytes();
+        return;
+      case FIXED32:
+        this.pos += 4;
+        return;
+    }
+    throw new Error(`unsupported wire type ${wireType}`);
+  }
+}
+
+/**
+ * Calls read for every field in the message. Fields it doesn't read, e.g. ones added by a newer
+ * runtime, are skipped.
+ */
+function decode(buf: Buffer, read: (field: number, r: Reader) => boolean) {
+  const r = new Reader(buf);
+  while (!r.done()) {
+    const tag = r.varint();
+    const field = Math.floor(tag / 8);
+    const wireType = tag % 8;
+    if (!read(field, r)) {
+      r.skip(wireType);
+    }
+  }
+}
@@ -0,0 +1,32 @@
+import {
+  decodeHealthCheckResponse,
+  encodeHealthCheckResponse,
+  ServingStatus,
+} from "./health";
+
+describe("HealthCheckResponse", () => {
+  test("should encode the status as field 1", () => {
+    expect(
+      encodeHealthCheckResponse({ status: ServingStatus.SERVING })
+    ).toEqual(Buffer.from([0x08, 0x01]));
+    expect(
+      encodeHealthCheckResponse({ status: ServingStatus.NOT_SERVING })
+    ).toEqual(Buffer.from([0x08, 0x02]));
+  });
+  test("should omit the default status", () => {
+    expect(
+      encodeHealthCheckResponse({ status: ServingStatus.UNKNOWN })
+    ).toEqual(Buffer.alloc(0));
+  });
+  test("should decode what it encodes", () => {
+    for (const status of [
+      ServingStatus.UNKNOWN,
+      ServingStatus.SERVING,
+      ServingStatus.NOT_SERVING,
+    ]) {
+      expect(
+        decodeHealthCheckResponse(encodeHealthCheckResponse({ status }))
+      ).toEqual({ status });
+    }
+  });
+});
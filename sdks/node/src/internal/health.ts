@@ -0,0 +1,71 @@
+import {
+  ServiceDefinition,
+  ServerUnaryCall,
+  sendUnaryData,
+  UntypedServiceImplementation,
+} from "@grpc/grpc-js";
+
+/**
+ * The serving statuses from grpc.health.v1.HealthCheckResponse.
+ */
+export enum ServingStatus {
+  UNKNOWN = 0,
+  SERVING = 1,
+  NOT_SERVING = 2,
+}
+
+export interface HealthCheckResponse {
+  status: ServingStatus;
+}
+
+/**
+ * The definition of the standard grpc.health.v1 Health service, which the runtime uses to check
+ * that the service is still responsive. Both messages are tiny, so they are encoded by hand
+ * rather than generated from the proto.
+ */
+export const HealthService: ServiceDefinition<UntypedServiceImplementation> = {
+  check: {
+    path: "/grpc.health.v1.Health/Check",
+    requestStream: false,
+    responseStream: false,
+    // The request only names the service to check, and the runtime always checks the whole
+    // service, so it isn't decoded.
+    requestSerialize: (_: {}) => Buffer.alloc(0),
+    requestDeserialize: (_: Buffer) => ({}),
+    responseSerialize: encodeHealthCheckResponse,
+    responseDeserialize: decodeHealthCheckResponse,
+  },
+};
+
+/**
+ * Encodes the response as protobuf. The status is field 1, a varint, and is omitted when it is
+ * the default value as proto3 requires.
+ */
+export function encodeHealthCheckResponse(res: HealthCheckResponse): Buffer {
+  if (res.status === ServingStatus.UNKNOWN) {
+    return Buffer.alloc(0);
+  }
+  return Buffer.from([0x08, res.status]);
+}
+
+export function decodeHealthCheckResponse(buf: Buffer): HealthCheckResponse {
+  if (buf.length >= 2 && buf[0] === 0x08) {
+    return { status: buf[1] };
+  }
+  return { status: ServingStatus.UNKNOWN };
+}
+
+/**
+ * The service is healthy as long as it can answer. A blocked event loop means the check doesn't
+ * get answered in time, which the runtime treats as a failure.
+ */
+export function newHealthServer(): UntypedServiceImplementation {
+  return {
+    check(
+      _: ServerUnaryCall<{}, HealthCheckResponse>,
+      callback: sendUnaryData<HealthCheckResponse>
+    ): void {
+      callback(null, { status: ServingStatus.SERVING });
+    },
+  };
+}
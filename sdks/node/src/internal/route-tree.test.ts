@@ -3,6 +3,22 @@ import { RouteTree } from "./route-tree";
 import { ServiceImpl } from "./service-impl";
 
 describe("RouteTree", () => {
+  test("it should reject duplicate routes", () => {
+    const tree = new RouteTree();
+    tree.addHandler("GET", "/foo", jest.fn());
+    tree.addHandler("POST", "/foo", jest.fn());
+    expect(() => tree.addHandler("GET", "/foo/", jest.fn())).toThrow(
+      "the route is registered more than once"
+    );
+  });
+
+  test("it should reject routes under the admin prefix", () => {
+    const tree = new RouteTree();
+    expect(() => tree.addHandler("GET", "/_foldadmin/healthz", jest.fn())).toThrow(
+      "paths under /_foldadmin are reserved"
+    );
+  });
+
   test("it should flatten a simple tree", () => {
     const tree = new RouteTree();
     tree.addHandler("GET", "/foo", jest.fn());
@@ -5,6 +5,8 @@ export type HandlerTable = Map<string, Map<string, HandlerFn>>;
 export type MiddlewareTable = Map<string, MiddlewareObj[]>;
 
 const TRAILING_SLASH = /\/$/;
+// Reserved by the fold runtime for its admin routes.
+const ADMIN_PREFIX = "/_foldadmin";
 
 /**
  * The RouteTree exists purely to build up a tree like structure
@@ -21,11 +23,21 @@ export class RouteTree {
 
   public addHandler(method: string, route: string, handler: HandlerFn): void {
     const r = this.validateAndCleanRoute(route);
+    if (r === ADMIN_PREFIX || r.startsWith(`${ADMIN_PREFIX}/`)) {
+      throw new Error(
+        `Invalid route ${method} ${r}: paths under ${ADMIN_PREFIX} are reserved for the runtime`
+      );
+    }
     let handlers = this.handlers.get(r);
     if (!handlers) {
       this.handlers.set(r, new Map());
       handlers = this.handlers.get(r);
     }
+    if (handlers!.has(method)) {
+      throw new Error(
+        `Invalid route ${method} ${r}: the route is registered more than once`
+      );
+    }
     handlers!.set(method, handler);
   }
 
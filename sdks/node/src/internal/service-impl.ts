@@ -2,6 +2,7 @@ import { getLogger, Logger } from "../logging";
 import { HTTPMethod, Request, Response } from "../http";
 import { Version } from "../version";
 import { GrpcServer } from "./grpc-server";
+import { InitializeReq } from "./handshake";
 import {
   Middleware,
   MiddlewareFn,
@@ -34,6 +35,8 @@ export class ServiceImpl implements Service {
   routes: RouteTree;
 
   constructor(version?: Version) {
+    // The runtime sends the name and stage in the handshake. Older runtimes set them in the
+    // environment instead, so they are used until the handshake has completed.
     this.name = process.env.FOLD_SERVICE_NAME!;
     this.version = version ? version : { major: 0, minor: 0, patch: 1 };
     this.settings = new ServiceSettings();
@@ -54,7 +57,13 @@ export class ServiceImpl implements Service {
       routes: router.routeManifest(),
     };
     this.logger.debug(manifest);
-    this.grpcServer = new GrpcServer(this.logger, router, manifest, socket);
+    this.grpcServer = new GrpcServer(
+      this.logger,
+      router,
+      manifest,
+      socket,
+      (req) => this.initialize(req)
+    );
     this.grpcServer.serve();
     this.running = true;
   }
@@ -168,6 +177,21 @@ export class ServiceImpl implements Service {
     throw new Error("not yet implemented");
   }
 
+  /**
+   * Applies the settings the runtime sends in the handshake.
+   */
+  public initialize(req: InitializeReq): void {
+    if (req.serviceName) {
+      this.name = req.serviceName;
+      this.grpcServer.manifest.setName(this.name);
+    }
+    if (req.stage) {
+      this.logger = getLogger(this.name, req.stage);
+      this.grpcServer.logger = this.logger;
+    }
+    this.logger.info(`Starting fold service ${this.name}`);
+  }
+
   public shutdown(): void {
     this.grpcServer.shutdown();
     this.running = false;
@@ -12,8 +12,14 @@ export interface Logger {
 
 const { combine, timestamp, prettyPrint, errors, json } = winston.format;
 
-export function getLogger(service: string): Logger {
-  const foldStage = process.env.FOLD_STAGE!;
+/**
+ * The stage is sent by the runtime in the handshake. Older runtimes set FOLD_STAGE instead, so it
+ * is used until the handshake has completed.
+ */
+export function getLogger(
+  service: string,
+  foldStage: string = process.env.FOLD_STAGE!
+): Logger {
   let baseConfig = {
     level: "debug",
     format: combine(
@@ -5,10 +5,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/foldsh/fold/runtime"
+	"github.com/foldsh/fold/runtime/watcher"
 )
 
 func TestBasicService(t *testing.T) {
@@ -48,7 +50,13 @@ func TestHotReload(t *testing.T) {
 	defer os.Remove(testFile)
 	writeService(t, testFile, "hello")
 
-	tc := NewRuntimeTestCase(t, testFile, runtime.WatchDir(5*time.Millisecond, testDir))
+	// The generated service is in the .gitignore so that it isn't committed, so the watcher has
+	// to be told not to use it.
+	tc := NewRuntimeTestCase(
+		t,
+		testFile,
+		runtime.WatchDir(5*time.Millisecond, testDir, watcher.GitIgnore(false)),
+	)
 
 	q := tc.query("GET", "/greeting", "")
 	q.expectStatus(200).expectBody(`{"msg":"hello"}`)
@@ -67,6 +75,39 @@ func TestHotReload(t *testing.T) {
 	q.expectStatus(200).expectBody(`{"msg":"goodbye"}`)
 }
 
+func TestBlueGreenHotReload(t *testing.T) {
+	testDir := "./testdata/blue_green"
+	testFile := filepath.Join(testDir, "main.go")
+	defer os.Remove(testFile)
+	writeService(t, testFile, "hello")
+
+	tc := NewRuntimeTestCase(
+		t,
+		testFile,
+		runtime.WatchDir(5*time.Millisecond, testDir, watcher.GitIgnore(false)),
+		runtime.ReloadStrategy(runtime.BLUE_GREEN),
+	)
+
+	q := tc.query("GET", "/greeting", "")
+	q.expectStatus(200).expectBody(`{"msg":"hello"}`)
+
+	writeService(t, testFile, "goodbye")
+
+	// Unlike a restart, the previous process keeps serving requests until the new one is ready so
+	// there is no need to wait before querying.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		q = tc.query("GET", "/greeting", "")
+		q.expectStatus(200)
+		if strings.Contains(q.w.String(), "goodbye") || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	q.expectBody(`{"msg":"goodbye"}`)
+	tc.Done()
+}
+
 func writeService(t *testing.T, path, msg string) {
 	code := fmt.Sprintf(`package main
 
@@ -29,6 +29,9 @@ func NewRuntimeTestCase(t *testing.T, bin string, options ...runtime.Option) *Ru
 	serr := &bytes.Buffer{}
 	defaults := []runtime.Option{
 		runtime.WithSupervisor(supervisor.NewSupervisor(logger, cmd, args, sout, serr)),
+		runtime.WithSupervisorFactory(func() runtime.Supervisor {
+			return supervisor.NewSupervisor(logger, cmd, args, &bytes.Buffer{}, &bytes.Buffer{})
+		}),
 		runtime.CrashPolicy(runtime.KEEP_ALIVE),
 	}
 	rt := runtime.NewRuntime(